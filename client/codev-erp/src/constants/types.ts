@@ -47,18 +47,29 @@ export type Lesson = {
     tasks: LessonTasks[];    // список заданий
 };
 
+export type Attachment = {
+    id: number;
+    key: string;             // имя в хранилище
+    originalName: string;
+    size: number;
+    mimeType: string;
+    checksum: string;
+    uploadedBy?: number;
+    createdAt: string;
+};
+
 export type LessonTasks = {
     id: number;
     lessonID: number;
-    homework: string[];      // JSON массив
-    classwork: string[];     // JSON массив
+    homeworkFiles: Attachment[];
+    classworkFiles: Attachment[];
 };
 
 export type UserHomeworkResponse = {
     id: number;
     userID: number;
     lessonID: number;
-    homework: string[];      // ответы студента
+    files: Attachment[];     // ответы студента
     startDate: string;
     user?: UserResponse;
     lesson?: Lesson;
@@ -2,7 +2,7 @@ import { useState, useEffect } from "react";
 import {AnimatePresence, motion } from "framer-motion";
 import {Link, useParams} from "wouter";
 import { Constants } from "../constants/constants.ts";
-import type {Course, Lesson, UserHomeworkResponse} from "../constants/types.ts";
+import type {Attachment, Course, Lesson, UserHomeworkResponse} from "../constants/types.ts";
 import {useAuth} from "../providers/AuthProvider.tsx";
 import StudentSubmissionsList from "./StudentSubmissionList.tsx";
 
@@ -285,8 +285,8 @@ function LessonDetailModal({ lesson, onClose, onUpdate }: {
     const [userHwFiles, setUserHwFiles] = useState<File[]>([]);
 
     const [recording, setRecording] = useState<boolean>(false);
-    const [homeworkFileNames, setHomeworkFileNames] = useState<string[]>([]);
-    const [classworkFileNames, setClassworkFileNames] = useState<string[]>([]);
+    const [homeworkFileNames, setHomeworkFileNames] = useState<Attachment[]>([]);
+    const [classworkFileNames, setClassworkFileNames] = useState<Attachment[]>([]);
     // @ts-ignore
     const [userHw, setUserHw] = useState<UserHomeworkResponse>(null);
 
@@ -304,8 +304,8 @@ function LessonDetailModal({ lesson, onClose, onUpdate }: {
                 console.log("Arrived data")
                 console.log(data)
                 if (data) {
-                    setHomeworkFileNames(data["homework"]);
-                    setClassworkFileNames(data["classwork"]);
+                    setHomeworkFileNames(data["homeworkFiles"]);
+                    setClassworkFileNames(data["classworkFiles"]);
                 }
         })}, [])
 
@@ -696,17 +696,17 @@ function LessonDetailModal({ lesson, onClose, onUpdate }: {
                                         <>
                                             <h3 className="font-bold text-green-700 mb-3">📝 Homework Files</h3>
                                             {homeworkFileNames && homeworkFileNames.map(file => (
-                                                <div key={file} className="bg-blue-50 p-3 rounded flex justify-between">
-                                                    <span className="text-blue-700">📘 Homework: {file}</span>
-                                                    <a href={`${Constants.SERVER_URL}/lesson_tasks/download/${file}`}>Download</a>
+                                                <div key={file.key} className="bg-blue-50 p-3 rounded flex justify-between">
+                                                    <span className="text-blue-700">📘 Homework: {file.originalName}</span>
+                                                    <a href={`${Constants.SERVER_URL}/lesson_tasks/download/${file.key}`}>Download</a>
                                                 </div>
                                             ))}
 
                                             <h3 className="font-bold text-green-700 mb-3">📝 Classwork Files</h3>
                                             {classworkFileNames && classworkFileNames.map(file => (
-                                                <div key={file} className="bg-yellow-50 p-3 rounded flex justify-between">
-                                                    <span className="text-yellow-700">🏫 Classwork: {file}</span>
-                                                    <a href={`${Constants.SERVER_URL}/lesson_tasks/download/${file}`}>Download</a>
+                                                <div key={file.key} className="bg-yellow-50 p-3 rounded flex justify-between">
+                                                    <span className="text-yellow-700">🏫 Classwork: {file.originalName}</span>
+                                                    <a href={`${Constants.SERVER_URL}/lesson_tasks/download/${file.key}`}>Download</a>
                                                 </div>
                                             ))}
                                         </>
@@ -20,7 +20,7 @@ export const Header = () => {
                 <img
                     alt={"Logo"}
                     className={"w-auto h-16"}
-                    src={`${Constants.SERVER_URL}/static/codev_logo.png`}
+                    src={`${Constants.SERVER_URL}/static/branding/codev_logo.png`}
 
                 />
 
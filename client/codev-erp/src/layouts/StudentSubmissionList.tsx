@@ -139,15 +139,15 @@ function SubmissionCard({ submission, expanded, onToggle, onUpdate }: {
                             <div>
                                 <h4 className="font-semibold text-green-700 mb-2">Student Files:</h4>
                                 <div className="space-y-2">
-                                    {submission.homework && submission.homework.length > 0 ? (
-                                        submission.homework.map((file, index) => (
+                                    {submission.files && submission.files.length > 0 ? (
+                                        submission.files.map((file) => (
                                             <div
-                                                key={index}
+                                                key={file.key}
                                                 className="flex items-center justify-between bg-white p-2 rounded"
                                             >
-                                                <span className="text-green-700 text-sm">📎 {file}</span>
+                                                <span className="text-green-700 text-sm">📎 {file.originalName}</span>
                                                 <a
-                                                    href={`${Constants.SERVER_URL}/static/${file}`}
+                                                    href={`${Constants.SERVER_URL}/lesson_tasks/submissions/${submission.id}/files/${file.key}`}
                                                     target="_blank"
                                                     rel="noopener noreferrer"
                                                     className="px-2 py-1 bg-green-500 text-white rounded hover:bg-green-600 text-xs"
@@ -0,0 +1,96 @@
+package attachment
+
+import (
+	"codev_erp/db/models"
+	"codev_erp/storage"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CleanName is the upload's file name as it is used in storage keys.
+func CleanName(filename string) string {
+	return strings.ReplaceAll(strings.TrimSpace(filepath.Base(filename)), " ", "_")
+}
+
+// UniqueKey names an upload so that files with the same name, e.g. two teachers'
+// "task.pdf", never overwrite each other. The key stays flat to fit the download routes.
+func UniqueKey(filename string) string {
+	return uuid.New().String() + "_" + CleanName(filename)
+}
+
+// MimeType guesses the type from the extension, falling back to what the client sent.
+func MimeType(name string, declared string) string {
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); byExt != "" {
+		return byExt
+	}
+	if declared != "" {
+		return declared
+	}
+	return "application/octet-stream"
+}
+
+// Save writes r to storage under key and records its metadata. Saving the same key
+// again replaces the file and refreshes the record.
+func Save(tx *gorm.DB, key string, originalName string, r io.Reader, size int64, declaredType string, uploadedBy *uint) (models.Attachment, error) {
+	hash := sha256.New()
+	if err := storage.Files.Put(key, io.TeeReader(r, hash), size); err != nil {
+		return models.Attachment{}, err
+	}
+
+	a := models.Attachment{
+		Key:          key,
+		OriginalName: originalName,
+		Size:         size,
+		MimeType:     MimeType(originalName, declaredType),
+		Checksum:     hex.EncodeToString(hash.Sum(nil)),
+		UploadedBy:   uploadedBy,
+	}
+
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"original_name", "size", "mime_type", "checksum", "uploaded_by"}),
+	}).Create(&a).Error
+	return a, err
+}
+
+// Describe builds the record of a file that is already in storage; the backfill uses
+// it for uploads made before attachments were tracked.
+func Describe(key string, originalName string) (models.Attachment, error) {
+	r, err := storage.Files.Open(key)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	return models.Attachment{
+		Key:          key,
+		OriginalName: originalName,
+		Size:         size,
+		MimeType:     MimeType(originalName, ""),
+		Checksum:     hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// ByKey returns the record of a stored file, nil if it is not tracked.
+func ByKey(tx *gorm.DB, key string) (*models.Attachment, error) {
+	var a models.Attachment
+	err := tx.Where("key = ?", key).Limit(1).Find(&a).Error
+	if err != nil || a.ID == 0 {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,129 @@
+package attachment
+
+import (
+	"codev_erp/db/models"
+	"codev_erp/storage"
+	"errors"
+	"regexp"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// errMissing marks a key whose file is gone from storage.
+var errMissing = errors.New("file is missing from storage")
+
+// storedPrefix matches what legacy stored names add to the uploaded one: a random
+// key prefix, or the "hw<lesson>_<user>_v<n>_" of older submissions.
+var storedPrefix = regexp.MustCompile(`^([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}_|hw\d+_\d+_v\d+_)`)
+
+// Result counts what a backfill did. Missing lists keys that are referenced by a
+// record but not found in storage; they are left unlinked.
+type Result struct {
+	Created int
+	Linked  int
+	Missing []string
+}
+
+// Backfill creates records for the files listed in the legacy name columns of lesson
+// tasks, homework submissions and their versions and links them, skipping what is
+// already linked, so it can run again. With dryRun nothing is written.
+func Backfill(tx *gorm.DB, dryRun bool) (Result, error) {
+	var result Result
+
+	var tasks []models.LessonTasks
+	if err := tx.Preload("HomeworkFiles").Preload("ClassworkFiles").Order("id").Find(&tasks).Error; err != nil {
+		return result, err
+	}
+
+	for _, task := range tasks {
+		if err := link(tx, &task, "HomeworkFiles", task.LegacyHomework, task.HomeworkFiles, &result, dryRun); err != nil {
+			return result, err
+		}
+		if err := link(tx, &task, "ClassworkFiles", task.LegacyClasswork, task.ClassworkFiles, &result, dryRun); err != nil {
+			return result, err
+		}
+	}
+
+	var submissions []models.UsersHomework
+	if err := tx.Preload("Files").Order("id").Find(&submissions).Error; err != nil {
+		return result, err
+	}
+
+	for _, submission := range submissions {
+		if err := link(tx, &submission, "Files", submission.LegacyHomework, submission.Files, &result, dryRun); err != nil {
+			return result, err
+		}
+	}
+
+	var versions []models.HomeworkVersion
+	if err := tx.Preload("Files").Order("id").Find(&versions).Error; err != nil {
+		return result, err
+	}
+
+	for _, version := range versions {
+		if err := link(tx, &version, "Files", version.LegacyFiles, version.Files, &result, dryRun); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// link attaches the keys that owner does not have linked yet.
+func link(tx *gorm.DB, owner interface{}, association string, keys []string, linked []models.Attachment, result *Result, dryRun bool) error {
+	var add []models.Attachment
+
+	for _, key := range keys {
+		if slices.ContainsFunc(linked, func(a models.Attachment) bool { return a.Key == key }) {
+			continue
+		}
+
+		a, err := ensure(tx, key, result, dryRun)
+		if errors.Is(err, errMissing) {
+			result.Missing = append(result.Missing, key)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		add = append(add, a)
+		linked = append(linked, a)
+	}
+
+	if len(add) == 0 {
+		return nil
+	}
+	result.Linked += len(add)
+	if dryRun {
+		return nil
+	}
+	return tx.Model(owner).Association(association).Append(add)
+}
+
+// ensure returns the record of key, describing the stored file if there is none yet.
+func ensure(tx *gorm.DB, key string, result *Result, dryRun bool) (models.Attachment, error) {
+	existing, err := ByKey(tx, key)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if existing != nil {
+		return *existing, nil
+	}
+
+	// у файлов ДЗ в ключе префикс версии, у материалов занятия ключ и есть исходное имя
+	a, err := Describe(key, storedPrefix.ReplaceAllString(key, ""))
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidName) {
+		return a, errMissing
+	}
+	if err != nil {
+		return a, err
+	}
+
+	result.Created++
+	if dryRun {
+		return a, nil
+	}
+	return a, tx.Create(&a).Error
+}
@@ -0,0 +1,206 @@
+package attendance
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/logger"
+	"codev_erp/notify"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusPresent = "present"
+	StatusLate    = "late"
+	StatusAbsent  = "absent"
+	StatusExcused = "excused"
+
+	MethodManual  = "manual"
+	MethodCheckIn = "check_in"
+)
+
+var (
+	ErrLessonCancelled = errors.New("lesson is cancelled")
+	ErrNotEnrolled     = errors.New("student is not enrolled in the course on the lesson date")
+	ErrInvalidStatus   = errors.New("status must be present, late, absent or excused")
+)
+
+// Entry is one student's mark in a bulk update.
+type Entry struct {
+	UserID uint   `json:"userID"`
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+// Counts aggregates attendance marks. Rate is the share of attended lessons in percent;
+// excused absences are left out of it.
+type Counts struct {
+	Present int64   `json:"present"`
+	Late    int64   `json:"late"`
+	Absent  int64   `json:"absent"`
+	Excused int64   `json:"excused"`
+	Rate    float64 `json:"rate"`
+}
+
+func ValidStatus(status string) bool {
+	return status == StatusPresent || status == StatusLate || status == StatusAbsent || status == StatusExcused
+}
+
+// AlertThreshold is the number of consecutive absences after which the teacher and
+// admins are alerted. Configured with ATTENDANCE_ALERT_AFTER, 3 by default.
+func AlertThreshold() int {
+	if n, err := strconv.Atoi(os.Getenv("ATTENDANCE_ALERT_AFTER")); err == nil && n > 0 {
+		return n
+	}
+	return 3
+}
+
+// EnrolledStudents lists the students whose enrollment covers the lesson date.
+func EnrolledStudents(tx *gorm.DB, lesson models.Lesson) ([]uint, error) {
+	var ids []uint
+	err := tx.Model(&models.EnrolledCourse{}).
+		Where("course_id = ? AND start_date <= ? AND end_date >= ?", lesson.CourseID, lesson.StartDate, lesson.StartDate).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// Mark stores the entries for the lesson, replacing earlier marks of the same students,
+// and alerts about students who have just reached the absence threshold.
+func Mark(lesson models.Lesson, entries []Entry, markedBy uint, method string, now time.Time) error {
+	if lesson.Status == "cancelled" {
+		return ErrLessonCancelled
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		enrolled, err := EnrolledStudents(tx, lesson)
+		if err != nil {
+			return err
+		}
+
+		allowed := map[uint]bool{}
+		for _, id := range enrolled {
+			allowed[id] = true
+		}
+
+		records := make([]models.Attendance, 0, len(entries))
+		for _, entry := range entries {
+			if !ValidStatus(entry.Status) {
+				return ErrInvalidStatus
+			}
+			if !allowed[entry.UserID] {
+				return ErrNotEnrolled
+			}
+
+			records = append(records, models.Attendance{
+				LessonID: lesson.ID,
+				UserID:   entry.UserID,
+				Status:   entry.Status,
+				Method:   method,
+				Note:     entry.Note,
+				MarkedBy: markedBy,
+				MarkedAt: now,
+			})
+		}
+
+		if len(records) == 0 {
+			return nil
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "lesson_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"status", "method", "note", "marked_by", "marked_at"}),
+		}).Create(&records).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Status == StatusAbsent {
+			checkStreak(lesson, entry.UserID)
+		}
+	}
+
+	return nil
+}
+
+// ConsecutiveAbsences counts the student's absences in the course since the last
+// attended lesson. Excused absences neither count nor break the streak.
+func ConsecutiveAbsences(userID uint, courseID uint) (int, error) {
+	var statuses []string
+
+	err := db.DB.Model(&models.Attendance{}).
+		Joins("JOIN lessons ON lessons.id = attendances.lesson_id").
+		Where("attendances.user_id = ? AND lessons.course_id = ? AND lessons.status <> ?", userID, courseID, "cancelled").
+		Order("lessons.start_date DESC").
+		Pluck("attendances.status", &statuses).Error
+	if err != nil {
+		return 0, err
+	}
+
+	streak := 0
+	for _, status := range statuses {
+		if status == StatusPresent || status == StatusLate {
+			break
+		}
+		if status == StatusAbsent {
+			streak++
+		}
+	}
+
+	return streak, nil
+}
+
+// checkStreak alerts once, when the streak reaches the threshold exactly, so that
+// re-marking the same lesson or further absences do not repeat the alert.
+func checkStreak(lesson models.Lesson, userID uint) {
+	streak, err := ConsecutiveAbsences(userID, lesson.CourseID)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Failed to count absences of user %d: %s", userID, err.Error()), slog.LevelError)
+		return
+	}
+
+	if streak != AlertThreshold() {
+		return
+	}
+
+	var student models.User
+	var course models.Course
+	if db.DB.Where("id = ?", userID).First(&student).Error != nil || db.DB.Where("id = ?", lesson.CourseID).First(&course).Error != nil {
+		return
+	}
+
+	msg := notify.Message{
+		Kind:  "attendance_alert",
+		Title: "Repeated absences",
+		Body:  fmt.Sprintf("%s %s has missed %d lessons of %s in a row.", student.FirstName, student.LastName, streak, course.Name),
+	}
+
+	if course.TeacherID != nil {
+		notify.Send(*course.TeacherID, msg)
+	}
+	notify.SendToRole("admin", msg)
+}
+
+// Summarize folds per-status counts into Counts and computes the rate.
+func Summarize(byStatus map[string]int64) Counts {
+	counts := Counts{
+		Present: byStatus[StatusPresent],
+		Late:    byStatus[StatusLate],
+		Absent:  byStatus[StatusAbsent],
+		Excused: byStatus[StatusExcused],
+	}
+
+	if counted := counts.Present + counts.Late + counts.Absent; counted > 0 {
+		counts.Rate = float64(counts.Present+counts.Late) * 100 / float64(counted)
+	}
+
+	return counts
+}
@@ -0,0 +1,155 @@
+package attendance
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// CodeStep is how long one check-in code is shown before it rotates.
+	CodeStep = 30 * time.Second
+	// OpenBefore lets students check in a little before the lesson starts.
+	OpenBefore = 15 * time.Minute
+	// LateAfter is when a check-in turns from present into late.
+	LateAfter = 10 * time.Minute
+)
+
+var (
+	ErrOutsideWindow  = errors.New("check-in is only possible around the lesson time")
+	ErrCheckInClosed  = errors.New("check-in is not open for this lesson")
+	ErrInvalidCode    = errors.New("invalid or expired code")
+	ErrAlreadyChecked = errors.New("already checked in")
+)
+
+// LessonWindow is the span in which the lesson accepts check-ins.
+func LessonWindow(lesson models.Lesson) (time.Time, time.Time) {
+	return lesson.StartDate.Add(-OpenBefore), lesson.StartDate.Add(time.Duration(lesson.Duration) * time.Minute)
+}
+
+// OpenCheckIn starts (or restarts with a fresh secret) the lesson's self check-in.
+func OpenCheckIn(lesson models.Lesson, openedBy uint, now time.Time) (models.CheckInWindow, error) {
+	var window models.CheckInWindow
+
+	if lesson.Status == "cancelled" {
+		return window, ErrLessonCancelled
+	}
+
+	opens, closes := LessonWindow(lesson)
+	if !now.Before(closes) {
+		return window, ErrOutsideWindow
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return window, err
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("lesson_id = ?", lesson.ID).Delete(&models.CheckInWindow{}).Error; err != nil {
+			return err
+		}
+
+		window = models.CheckInWindow{
+			LessonID: lesson.ID,
+			Secret:   hex.EncodeToString(secret),
+			OpenedBy: openedBy,
+			OpensAt:  opens,
+			ClosesAt: closes,
+		}
+		return tx.Create(&window).Error
+	})
+
+	return window, err
+}
+
+// IsOpen reports whether the window accepts check-ins at the moment.
+func IsOpen(window models.CheckInWindow, now time.Time) bool {
+	return window.ClosedAt == nil && !now.Before(window.OpensAt) && now.Before(window.ClosesAt)
+}
+
+// Code is the 6-digit code valid at the moment, and when it rotates.
+func Code(window models.CheckInWindow, now time.Time) (string, time.Time) {
+	step := now.Unix() / int64(CodeStep/time.Second)
+	return codeAt(window, step), time.Unix((step+1)*int64(CodeStep/time.Second), 0)
+}
+
+// QRPayload is what the QR code encodes; the student app posts both parts back.
+func QRPayload(window models.CheckInWindow, code string) string {
+	return fmt.Sprintf("codev-checkin:%d:%s", window.LessonID, code)
+}
+
+// CheckIn marks the student present (or late) if the code matches the current or the
+// previous step, so that a code scanned just before it rotated still works. Tries are
+// paced per student and lesson, and MaxWrongCodes wrong codes lock the student out of
+// the window.
+func CheckIn(lessonID uint, userID uint, code string, now time.Time) (models.Attendance, error) {
+	var record models.Attendance
+	var window models.CheckInWindow
+
+	if err := db.DB.Preload("Lesson").Where("lesson_id = ?", lessonID).First(&window).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return record, ErrCheckInClosed
+		}
+		return record, err
+	}
+
+	if !IsOpen(window, now) {
+		return record, ErrCheckInClosed
+	}
+
+	if err := admit(window, userID, now); err != nil {
+		return record, err
+	}
+
+	step := now.Unix() / int64(CodeStep/time.Second)
+	if !hmac.Equal([]byte(code), []byte(codeAt(window, step))) && !hmac.Equal([]byte(code), []byte(codeAt(window, step-1))) {
+		if err := missed(window, userID); err != nil {
+			return record, err
+		}
+		return record, ErrInvalidCode
+	}
+
+	// повторное сканирование не должно превращать "present" в "late"
+	err := db.DB.Where("lesson_id = ? AND user_id = ? AND status IN ?", lessonID, userID, []string{StatusPresent, StatusLate}).
+		First(&record).Error
+	if err == nil {
+		return record, ErrAlreadyChecked
+	}
+
+	status := StatusPresent
+	if now.After(window.Lesson.StartDate.Add(LateAfter)) {
+		status = StatusLate
+	}
+
+	if err := Mark(window.Lesson, []Entry{{UserID: userID, Status: status}}, userID, MethodCheckIn, now); err != nil {
+		return record, err
+	}
+
+	err = db.DB.Where("lesson_id = ? AND user_id = ?", lessonID, userID).First(&record).Error
+	return record, err
+}
+
+// CloseCheckIn stops accepting codes for the lesson.
+func CloseCheckIn(lessonID uint, now time.Time) error {
+	return db.DB.Model(&models.CheckInWindow{}).
+		Where("lesson_id = ? AND closed_at IS NULL", lessonID).
+		Update("closed_at", now).Error
+}
+
+func codeAt(window models.CheckInWindow, step int64) string {
+	mac := hmac.New(sha256.New, []byte(window.Secret))
+	_ = binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+
+	return fmt.Sprintf("%06d", binary.BigEndian.Uint32(sum[:4])%1000000)
+}
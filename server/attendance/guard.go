@@ -0,0 +1,91 @@
+package attendance
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MaxWrongCodes is how many wrong codes a student may send for one check-in
+	// window before they have to ask the teacher to mark them.
+	MaxWrongCodes = 5
+	// attemptEvery and attemptBurst pace one student's tries on one lesson.
+	attemptEvery = time.Second
+	attemptBurst = 3
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many check-in attempts, try again in a moment")
+	ErrLockedOut       = errors.New("too many wrong codes, ask the teacher to mark you")
+)
+
+type attemptKey struct {
+	lessonID uint
+	userID   uint
+}
+
+type pacing struct {
+	limiter  *rate.Limiter
+	windowID uint
+	expires  time.Time
+}
+
+// pacer only smooths out bursts, so it is kept in memory and every server instance paces
+// on its own. The wrong-code count that locks a student out is stored with the window
+// (models.CheckInAttempts) and holds across instances and restarts.
+var pacer = struct {
+	sync.Mutex
+	entries map[attemptKey]*pacing
+}{entries: map[attemptKey]*pacing{}}
+
+// admit takes one try of the student for the window, or explains why it is refused.
+func admit(window models.CheckInWindow, userID uint, now time.Time) error {
+	var attempts models.CheckInAttempts
+	err := db.DB.Where("window_id = ? AND user_id = ?", window.ID, userID).First(&attempts).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if attempts.Wrong >= MaxWrongCodes {
+		return ErrLockedOut
+	}
+
+	pacer.Lock()
+	defer pacer.Unlock()
+
+	for key, entry := range pacer.entries {
+		if !now.Before(entry.expires) {
+			delete(pacer.entries, key)
+		}
+	}
+
+	key := attemptKey{lessonID: window.LessonID, userID: userID}
+	entry := pacer.entries[key]
+	if entry == nil || entry.windowID != window.ID {
+		entry = &pacing{
+			limiter:  rate.NewLimiter(rate.Every(attemptEvery), attemptBurst),
+			windowID: window.ID,
+			expires:  window.ClosesAt,
+		}
+		pacer.entries[key] = entry
+	}
+
+	if !entry.limiter.AllowN(now, 1) {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// missed counts a wrong code sent for the window.
+func missed(window models.CheckInWindow, userID uint) error {
+	return db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "window_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"wrong": gorm.Expr("check_in_attempts.wrong + 1")}),
+	}).Create(&models.CheckInAttempts{WindowID: window.ID, UserID: userID, Wrong: 1}).Error
+}
@@ -0,0 +1,324 @@
+package autograde
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/logger"
+	"codev_erp/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LanguagePython     = "python"
+	LanguageJavaScript = "javascript"
+
+	RunQueued  = "queued"
+	RunRunning = "running"
+	RunDone    = "done"
+	RunError   = "error"
+
+	ResultPassed  = "passed"
+	ResultFailed  = "failed"
+	ResultTimeout = "timeout"
+	ResultMemory  = "memory"
+	ResultError   = "error"
+
+	// staleAfter requeues runs left "running" by a crashed or restarted server.
+	staleAfter = 15 * time.Minute
+	// keepOutput is how much output of a test is stored for the teacher to review.
+	keepOutput = 2048
+)
+
+var (
+	ErrNoEntrypoint  = errors.New("the submission has no file the tests can run")
+	ErrDuplicateName = errors.New("the submission has several files with the same name")
+)
+
+var extensions = map[string]string{
+	LanguagePython:     ".py",
+	LanguageJavaScript: ".js",
+}
+
+func ValidLanguage(language string) bool {
+	_, ok := extensions[language]
+	return ok
+}
+
+// command builds the interpreter invocation for the entry file.
+func command(language string, entry string, memoryMB uint) []string {
+	if language == LanguageJavaScript {
+		return []string{"node", fmt.Sprintf("--max-old-space-size=%d", memoryMB), entry}
+	}
+	return []string{"python3", entry}
+}
+
+// Enqueue queues a run of the lesson's test suite against one homework version.
+// It does nothing when the lesson has no suite.
+func Enqueue(tx *gorm.DB, homeworkID uint, lessonID uint, version uint) (*models.TestRun, error) {
+	var suites int64
+	if err := tx.Model(&models.TestSuite{}).Where("lesson_id = ?", lessonID).Count(&suites).Error; err != nil {
+		return nil, err
+	}
+	if suites == 0 {
+		return nil, nil
+	}
+
+	run := models.TestRun{HomeworkID: homeworkID, Version: version, Status: RunQueued, QueuedAt: time.Now()}
+	if err := tx.Create(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Latest returns the most recent finished run of a homework version, nil if none.
+func Latest(tx *gorm.DB, homeworkID uint, version uint) (*models.TestRun, error) {
+	var run models.TestRun
+	err := tx.Where("homework_id = ? AND version = ? AND status = ?", homeworkID, version, RunDone).
+		Order("id DESC").
+		First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ProcessQueue works through the queued runs one by one. Runs are claimed with
+// SKIP LOCKED, so several server instances can share the queue.
+func ProcessQueue(now time.Time) {
+	err := db.DB.Model(&models.TestRun{}).
+		Where("status = ? AND started_at < ?", RunRunning, now.Add(-staleAfter)).
+		Updates(map[string]interface{}{"status": RunQueued, "started_at": nil}).Error
+	if err != nil {
+		logger.Log("Autograde: failed to requeue stale runs: "+err.Error(), slog.LevelError)
+	}
+
+	sandbox, err := FromEnv()
+	if err != nil {
+		logger.Log("Autograde: "+err.Error(), slog.LevelError)
+		return
+	}
+
+	for {
+		run, ok := claim()
+		if !ok {
+			return
+		}
+		results, points, outOf, err := runSuite(sandbox, run)
+		finish(run, results, points, outOf, err)
+	}
+}
+
+func claim() (models.TestRun, bool) {
+	var run models.TestRun
+
+	err := db.DB.Raw(`UPDATE test_runs SET status = ?, started_at = ?
+		WHERE id = (SELECT id FROM test_runs WHERE status = ? ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING *`, RunRunning, time.Now(), RunQueued).
+		Scan(&run).Error
+	if err != nil {
+		logger.Log("Autograde: failed to claim a run: "+err.Error(), slog.LevelError)
+		return run, false
+	}
+
+	return run, run.ID != 0
+}
+
+func finish(run models.TestRun, results []models.TestResult, points uint, outOf uint, runErr error) {
+	finished := time.Now()
+	update := models.TestRun{
+		Status:          RunDone,
+		Results:         results,
+		SuggestedPoints: points,
+		MaxPoints:       outOf,
+		FinishedAt:      &finished,
+	}
+
+	if runErr != nil {
+		logger.Log(fmt.Sprintf("Autograde: run %d failed: %s", run.ID, runErr.Error()), slog.LevelWarn)
+		update.Status = RunError
+		update.Error = runErr.Error()
+	}
+
+	// Select нужен, чтобы нулевые баллы и пустая ошибка тоже записались
+	err := db.DB.Model(&run).
+		Select("status", "results", "suggested_points", "max_points", "finished_at", "error").
+		Updates(update).Error
+	if err != nil {
+		logger.Log(fmt.Sprintf("Autograde: failed to save run %d: %s", run.ID, err.Error()), slog.LevelError)
+	}
+}
+
+// runSuite copies the version's files into a scratch directory and runs every test
+// case against the entry file in the sandbox.
+func runSuite(sandbox Sandbox, run models.TestRun) ([]models.TestResult, uint, uint, error) {
+	var submission models.UsersHomework
+	if err := db.DB.Where("id = ?", run.HomeworkID).First(&submission).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	var version models.HomeworkVersion
+	if err := db.DB.Preload("Files").Where("homework_id = ? AND number = ?", run.HomeworkID, run.Version).First(&version).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	var suite models.TestSuite
+	err := db.DB.
+		Preload("Cases", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Where("lesson_id = ?", submission.LessonID).
+		First(&suite).Error
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	dir, err := os.MkdirTemp("", "autograde-")
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	entry, err := prepare(dir, suite, version.Files)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var results []models.TestResult
+	var points, outOf uint
+
+	for _, testCase := range suite.Cases {
+		outcome, err := sandbox.Run(context.Background(), Spec{
+			Dir:       dir,
+			Language:  suite.Language,
+			Command:   command(suite.Language, entry, suite.MemoryLimitMB),
+			Stdin:     testCase.Stdin,
+			TimeLimit: time.Duration(suite.TimeLimitMs) * time.Millisecond,
+			MemoryMB:  suite.MemoryLimitMB,
+		})
+		if err != nil {
+			return results, points, outOf, err
+		}
+
+		result := judge(testCase, outcome)
+		points += result.Points
+		outOf += result.MaxPoints
+		results = append(results, result)
+	}
+
+	return results, points, outOf, nil
+}
+
+// prepare copies the submitted files under their original names and returns the
+// name of the file to run. Two files with the same name are refused rather than one
+// overwriting the other. The directory must be readable by the sandbox user.
+func prepare(dir string, suite models.TestSuite, files []models.Attachment) (string, error) {
+	if err := os.Chmod(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	entry := ""
+	seen := map[string]bool{}
+	for _, file := range files {
+		name := filepath.Base(file.OriginalName)
+		if seen[name] {
+			return "", fmt.Errorf("%w: %s", ErrDuplicateName, name)
+		}
+		seen[name] = true
+
+		if err := copyFile(file.Key, filepath.Join(dir, name)); err != nil {
+			return "", err
+		}
+
+		switch {
+		case suite.Entrypoint != "":
+			if name == suite.Entrypoint {
+				entry = name
+			}
+		case entry == "" && strings.EqualFold(filepath.Ext(name), extensions[suite.Language]):
+			entry = name
+		}
+	}
+
+	if entry == "" {
+		return "", ErrNoEntrypoint
+	}
+	return entry, nil
+}
+
+// copyFile copies a stored file into the working directory of a run.
+func copyFile(stored string, to string) error {
+	src, err := storage.Files.Open(stored)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// judge compares the program's output with the expected one, ignoring trailing
+// whitespace and line-ending differences.
+func judge(testCase models.TestCase, outcome Outcome) models.TestResult {
+	result := models.TestResult{
+		TestCaseID: testCase.ID,
+		Name:       testCase.Name,
+		Hidden:     testCase.Hidden,
+		MaxPoints:  testCase.Points,
+		DurationMs: outcome.Duration.Milliseconds(),
+		Output:     clip(outcome.Stdout),
+		Stderr:     clip(outcome.Stderr),
+	}
+
+	switch {
+	case outcome.TimedOut:
+		result.Status = ResultTimeout
+	case outcome.OutOfMem:
+		result.Status = ResultMemory
+	case outcome.ExitCode != 0:
+		result.Status = ResultError
+	case NormalizeOutput(outcome.Stdout) == NormalizeOutput(testCase.ExpectedOutput):
+		result.Status = ResultPassed
+		result.Points = testCase.Points
+	default:
+		result.Status = ResultFailed
+	}
+
+	return result
+}
+
+// NormalizeOutput prepares program output for comparison: line endings and trailing
+// whitespace don't matter.
+func NormalizeOutput(output string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+func clip(output string) string {
+	if len(output) <= keepOutput {
+		return output
+	}
+	return output[:keepOutput] + "\n…"
+}
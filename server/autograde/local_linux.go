@@ -0,0 +1,64 @@
+//go:build linux
+
+package autograde
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Local runs the program directly on the host under prlimit(1): address space, CPU
+// time, file size, open files and processes are capped and the whole process group is
+// killed on timeout. The process cap counts every process of the server's user, so the
+// server should run as a user of its own. There is no filesystem or network isolation, so it is not suitable for
+// production use with untrusted students.
+type Local struct{}
+
+func (Local) Run(ctx context.Context, spec Spec) (Outcome, error) {
+	cpuSeconds := int(math.Ceil(spec.TimeLimit.Seconds()))
+
+	args := []string{
+		fmt.Sprintf("--cpu=%d", cpuSeconds),
+		"--fsize=1048576",
+		"--nofile=64",
+		"--nproc=64", // как --pids-limit у Docker: форк-бомба упирается в лимит
+		"--core=0",
+	}
+	// V8 резервирует много виртуальной памяти, для node лимит задаётся через его флаг
+	if spec.Language != LanguageJavaScript {
+		args = append(args, fmt.Sprintf("--as=%d", uint64(spec.MemoryMB)*1024*1024))
+	}
+	args = append(args, "--")
+	args = append(args, spec.Command...)
+
+	ctx, cancel := context.WithTimeout(ctx, spec.TimeLimit)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "prlimit", args...)
+	cmd.Dir = spec.Dir
+	cmd.Env = []string{"PATH=/usr/local/bin:/usr/bin:/bin", "HOME=" + spec.Dir, "LANG=C.UTF-8"}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	outcome, err := execute(ctx, cmd, spec.Stdin)
+	if err != nil {
+		return outcome, err
+	}
+
+	// SIGXCPU после исчерпания лимита процессорного времени — тоже таймаут
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGXCPU {
+		outcome.TimedOut = true
+	}
+
+	// без контейнера нехватку памяти видно только по сообщению интерпретатора
+	outcome.OutOfMem = outcome.ExitCode != 0 &&
+		(strings.Contains(outcome.Stderr, "MemoryError") || strings.Contains(outcome.Stderr, "heap out of memory"))
+
+	return outcome, nil
+}
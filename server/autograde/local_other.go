@@ -0,0 +1,15 @@
+//go:build !linux
+
+package autograde
+
+import (
+	"context"
+	"errors"
+)
+
+// Local is only available on Linux, where prlimit and process groups are used.
+type Local struct{}
+
+func (Local) Run(ctx context.Context, spec Spec) (Outcome, error) {
+	return Outcome{}, errors.New("the local sandbox is only supported on linux")
+}
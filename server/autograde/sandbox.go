@@ -0,0 +1,173 @@
+package autograde
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxCapture caps how much of a program's stdout and stderr is kept.
+	maxCapture = 64 * 1024
+	// dockerStartup is added to the time limit to cover starting the container.
+	dockerStartup = 2 * time.Second
+)
+
+// Spec describes one sandboxed execution: Command runs inside Dir, which holds a
+// copy of the submission, and Stdin is fed to it.
+type Spec struct {
+	Dir       string
+	Language  string
+	Command   []string
+	Stdin     string
+	TimeLimit time.Duration
+	MemoryMB  uint
+}
+
+type Outcome struct {
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	TimedOut  bool
+	OutOfMem  bool
+	Truncated bool
+	Duration  time.Duration
+}
+
+// Sandbox runs untrusted submission code. An error means the sandbox itself failed,
+// not the program; a crashing program is reported through the exit code.
+type Sandbox interface {
+	Run(ctx context.Context, spec Spec) (Outcome, error)
+}
+
+var ErrUnknownBackend = errors.New("unknown SANDBOX_BACKEND, expected docker or local")
+
+// images are the container images used by the docker backend per language.
+var images = map[string]string{
+	LanguagePython:     "python:3.12-alpine",
+	LanguageJavaScript: "node:20-alpine",
+}
+
+// FromEnv picks the sandbox configured by SANDBOX_BACKEND. Docker is the default:
+// it gives each run its own filesystem, no network and a hard memory cap. The local
+// backend only applies rlimits and is meant for development machines.
+func FromEnv() (Sandbox, error) {
+	switch os.Getenv("SANDBOX_BACKEND") {
+	case "", "docker":
+		return Docker{}, nil
+	case "local":
+		return Local{}, nil
+	}
+	return nil, ErrUnknownBackend
+}
+
+// Docker runs every test in a fresh container with the submission mounted read-only.
+type Docker struct{}
+
+func (Docker) Run(ctx context.Context, spec Spec) (Outcome, error) {
+	image, ok := images[spec.Language]
+	if !ok {
+		return Outcome{}, fmt.Errorf("no image for language %q", spec.Language)
+	}
+	if override := os.Getenv("SANDBOX_IMAGE_" + strings.ToUpper(spec.Language)); override != "" {
+		image = override
+	}
+
+	name := "autograde-" + uuid.NewString()
+	memory := strconv.FormatUint(uint64(spec.MemoryMB), 10) + "m"
+
+	args := []string{"run", "--rm", "-i", "--name", name,
+		"--network", "none",
+		"--memory", memory, "--memory-swap", memory,
+		"--pids-limit", "64",
+		"--cpus", "1",
+		"--read-only", "--tmpfs", "/tmp:size=16m",
+		"--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"--user", "65534:65534",
+		"-v", spec.Dir + ":/code:ro", "-w", "/code",
+		image}
+	args = append(args, spec.Command...)
+
+	ctx, cancel := context.WithTimeout(ctx, spec.TimeLimit+dockerStartup)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	// убиваем контейнер, а не только docker CLI, иначе программа продолжит работать
+	cmd.Cancel = func() error {
+		_ = exec.Command("docker", "rm", "-f", name).Run()
+		return cmd.Process.Kill()
+	}
+
+	outcome, err := execute(ctx, cmd, spec.Stdin)
+	if err != nil {
+		return outcome, err
+	}
+
+	// 137 — SIGKILL от OOM killer внутри контейнера
+	outcome.OutOfMem = !outcome.TimedOut && outcome.ExitCode == 137
+	// 125 означает, что docker не смог запустить контейнер
+	if outcome.ExitCode == 125 {
+		return outcome, fmt.Errorf("docker failed to start the container: %s", outcome.Stderr)
+	}
+	return outcome, nil
+}
+
+// execute runs the prepared command with captured, size-limited output.
+func execute(ctx context.Context, cmd *exec.Cmd, stdin string) (Outcome, error) {
+	var outcome Outcome
+
+	stdout := &limitedBuffer{limit: maxCapture}
+	stderr := &limitedBuffer{limit: maxCapture}
+	cmd.Stdin = bytes.NewBufferString(stdin)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	started := time.Now()
+	err := cmd.Run()
+	outcome.Duration = time.Since(started)
+
+	outcome.Stdout = stdout.String()
+	outcome.Stderr = stderr.String()
+	outcome.Truncated = stdout.truncated || stderr.truncated
+	outcome.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		outcome.ExitCode = exitErr.ExitCode()
+	case outcome.TimedOut:
+		outcome.ExitCode = -1
+	default:
+		return outcome, err
+	}
+
+	return outcome, nil
+}
+
+// limitedBuffer keeps the first limit bytes and silently drops the rest, so a
+// program printing in a loop cannot exhaust the server's memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,70 @@
+package billing
+
+import (
+	"codev_erp/db/models"
+	"codev_erp/money"
+	"time"
+)
+
+const (
+	PeriodMonthly  = "monthly"
+	PeriodFullTerm = "full_term"
+)
+
+func ValidPeriod(period string) bool {
+	return period == PeriodMonthly || period == PeriodFullTerm
+}
+
+// TotalPrice returns what a student owes for the whole enrollment span.
+// Monthly courses are charged once per month, full-term courses once.
+func TotalPrice(course models.Course, months uint) money.Amount {
+	if course.BillingPeriod == PeriodMonthly {
+		return course.Price.Mul(int64(months))
+	}
+	return course.Price
+}
+
+// BuildSchedule generates the installment plan for a freshly created enrollment.
+// Monthly courses get one installment per month; full-term courses are split
+// into the requested number of monthly parts (one payment by default).
+func BuildSchedule(enrollment models.EnrolledCourse, period string, months uint, parts uint) []models.Installment {
+	if period == PeriodMonthly {
+		parts = months
+	}
+	if parts == 0 {
+		parts = 1
+	}
+
+	var installments []models.Installment
+	for i, amount := range enrollment.Price.Split(int(parts)) {
+		installments = append(installments, models.Installment{
+			EnrollmentID: enrollment.ID,
+			Number:       uint(i + 1),
+			DueDate:      dueDate(enrollment.StartDate, i),
+			Amount:       amount,
+			Currency:     enrollment.Currency,
+		})
+	}
+
+	return installments
+}
+
+// dueDate is the same day of the month the given number of months after start. A
+// day the month doesn't have moves to its last day, so Jan 31 is followed by Feb 28.
+func dueDate(start time.Time, month int) time.Time {
+	y, m, d := start.Date()
+	// нулевой день следующего месяца — последний день нужного
+	last := time.Date(y, m+time.Month(month)+1, 0, 0, 0, 0, 0, start.Location()).Day()
+	return time.Date(y, m+time.Month(month), min(d, last), 0, 0, 0, 0, start.Location())
+}
+
+// Outstanding sums the unpaid installments of an enrollment.
+func Outstanding(installments []models.Installment) money.Amount {
+	var total money.Amount
+	for _, installment := range installments {
+		if !installment.Paid {
+			total += installment.Amount
+		}
+	}
+	return total
+}
@@ -0,0 +1,95 @@
+package billing
+
+import (
+	"codev_erp/db/models"
+	"codev_erp/money"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+var (
+	ErrDiscountNotFound  = errors.New("discount not found")
+	ErrDiscountInactive  = errors.New("discount is not active")
+	ErrDiscountExpired   = errors.New("discount is outside its validity window")
+	ErrDiscountCourse    = errors.New("discount does not apply to this course")
+	ErrDiscountCurrency  = errors.New("discount currency does not match the course")
+	ErrDiscountExhausted = errors.New("discount usage limit reached")
+)
+
+// CheckDiscount reports why a discount cannot be applied to the course right now.
+func CheckDiscount(d models.Discount, course models.Course, now time.Time) error {
+	if !d.Active {
+		return ErrDiscountInactive
+	}
+	if d.ValidFrom != nil && now.Before(*d.ValidFrom) {
+		return ErrDiscountExpired
+	}
+	if d.ValidUntil != nil && now.After(*d.ValidUntil) {
+		return ErrDiscountExpired
+	}
+	if d.CourseID != nil && *d.CourseID != course.ID {
+		return ErrDiscountCourse
+	}
+	if d.Kind == DiscountFixed && d.Currency != course.Currency {
+		return ErrDiscountCurrency
+	}
+	if d.MaxUses != nil && d.Uses >= *d.MaxUses {
+		return ErrDiscountExhausted
+	}
+	return nil
+}
+
+// ApplyDiscount returns the effective price after the discount. The result never drops below zero.
+func ApplyDiscount(price money.Amount, d models.Discount) money.Amount {
+	var effective money.Amount
+
+	switch d.Kind {
+	case DiscountPercent:
+		// округляем скидку до ближайшей копейки
+		off := (int64(price)*int64(d.Percent) + 50) / 100
+		effective = price - money.Amount(off)
+	case DiscountFixed:
+		effective = price - d.Amount
+	default:
+		effective = price
+	}
+
+	if effective < 0 {
+		return 0
+	}
+	return effective
+}
+
+// FindDiscountByCode looks up an active promo code.
+func FindDiscountByCode(tx *gorm.DB, code string) (models.Discount, error) {
+	var discount models.Discount
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if err := tx.Where("code = ?", code).First(&discount).Error; err != nil {
+		return discount, ErrDiscountNotFound
+	}
+	return discount, nil
+}
+
+// ClaimDiscount counts one use of the discount. The update is conditional so that
+// concurrent enrollments cannot push a discount past its usage cap.
+func ClaimDiscount(tx *gorm.DB, d models.Discount) error {
+	res := tx.Model(&models.Discount{}).
+		Where("id = ? AND (max_uses IS NULL OR uses < max_uses)", d.ID).
+		Update("uses", gorm.Expr("uses + 1"))
+
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDiscountExhausted
+	}
+	return nil
+}
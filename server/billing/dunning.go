@@ -0,0 +1,65 @@
+package billing
+
+import (
+	"codev_erp/db/models"
+	"time"
+)
+
+// Reminder stages of an unpaid installment, in escalation order.
+const (
+	StageNone        = ""
+	StageDueSoon     = "due_soon"
+	StageOverdue     = "overdue"
+	StageFinalNotice = "final_notice"
+)
+
+const (
+	// DueSoonWindow is how long before the due date the first reminder goes out.
+	DueSoonWindow = 3 * 24 * time.Hour
+	// FinalNoticeAfter is how long after the due date the final notice goes out.
+	FinalNoticeAfter = 14 * 24 * time.Hour
+)
+
+var stageRank = map[string]int{
+	StageNone:        0,
+	StageDueSoon:     1,
+	StageOverdue:     2,
+	StageFinalNotice: 3,
+}
+
+// DunningStage returns the stage an installment should be at on the given moment.
+// An installment becomes overdue once its due day is over.
+func DunningStage(installment models.Installment, now time.Time) string {
+	if installment.Paid {
+		return StageNone
+	}
+
+	switch due := installment.DueDate; {
+	case !now.Before(due.Add(FinalNoticeAfter)):
+		return StageFinalNotice
+	case !now.Before(due.AddDate(0, 0, 1)):
+		return StageOverdue
+	case !now.Before(due.Add(-DueSoonWindow)):
+		return StageDueSoon
+	}
+
+	return StageNone
+}
+
+// Escalates reports whether moving from the current stage to next needs a new reminder.
+func Escalates(next string, current string) bool {
+	return stageRank[next] > stageRank[current]
+}
+
+// IsOverdueStage reports whether the stage means the due date has passed.
+func IsOverdueStage(stage string) bool {
+	return stageRank[stage] >= stageRank[StageOverdue]
+}
+
+// DaysOverdue counts whole days since the due date, zero if not yet due.
+func DaysOverdue(installment models.Installment, now time.Time) int {
+	if now.Before(installment.DueDate) {
+		return 0
+	}
+	return int(now.Sub(installment.DueDate).Hours() / 24)
+}
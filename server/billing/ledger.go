@@ -0,0 +1,113 @@
+package billing
+
+import (
+	"codev_erp/db/models"
+	"codev_erp/money"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LedgerPayment = "payment"
+	LedgerManual  = "manual"
+	LedgerRefund  = "refund"
+)
+
+// Remaining is the unpaid part of an installment.
+func Remaining(installment models.Installment) money.Amount {
+	if installment.Paid {
+		return 0
+	}
+	return installment.Amount - installment.PaidAmount
+}
+
+// DueAmount is what a checkout should charge now: every unpaid installment that is
+// overdue or due soon, or the next installment when nothing is due yet.
+func DueAmount(installments []models.Installment, now time.Time) money.Amount {
+	var due money.Amount
+	var next *models.Installment
+
+	for i, installment := range installments {
+		if installment.Paid {
+			continue
+		}
+		if DunningStage(installment, now) != StageNone {
+			due += Remaining(installment)
+		} else if next == nil || installment.DueDate.Before(next.DueDate) {
+			next = &installments[i]
+		}
+	}
+
+	if due == 0 && next != nil {
+		return Remaining(*next)
+	}
+	return due
+}
+
+// RecordPayment books the ledger entry and applies it to the enrollment's unpaid
+// installments, oldest first. It must run inside a transaction. Anything paid
+// beyond the schedule stays in the ledger as credit.
+func RecordPayment(tx *gorm.DB, entry *models.LedgerEntry, now time.Time) error {
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+
+	var installments []models.Installment
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("enrollment_id = ? AND paid = ?", entry.EnrollmentID, false).
+		Order("number").
+		Find(&installments).Error
+	if err != nil {
+		return err
+	}
+
+	remaining := entry.Amount
+	for _, installment := range installments {
+		if remaining <= 0 {
+			break
+		}
+
+		applied := Remaining(installment)
+		if applied > remaining {
+			applied = remaining
+		}
+		remaining -= applied
+
+		updates := map[string]interface{}{"paid_amount": installment.PaidAmount + applied}
+		if installment.PaidAmount+applied >= installment.Amount {
+			updates["paid"] = true
+			updates["paid_date"] = now
+			updates["overdue"] = false
+		}
+
+		if err := tx.Model(&models.Installment{}).Where("id = ?", installment.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	return syncEnrollmentStatus(tx, entry.EnrollmentID, now)
+}
+
+// syncEnrollmentStatus keeps the enrollment's Paid and Overdue flags in line with its installments.
+func syncEnrollmentStatus(tx *gorm.DB, enrollmentID uint, now time.Time) error {
+	var unpaid, overdue int64
+
+	base := tx.Model(&models.Installment{}).Where("enrollment_id = ? AND paid = ?", enrollmentID, false)
+	if err := base.Session(&gorm.Session{}).Count(&unpaid).Error; err != nil {
+		return err
+	}
+	if err := base.Session(&gorm.Session{}).Where("overdue = ?", true).Count(&overdue).Error; err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"overdue": overdue > 0}
+	if unpaid == 0 {
+		updates["paid"] = true
+		updates["paid_date"] = now
+	}
+
+	return tx.Model(&models.EnrolledCourse{}).Where("id = ?", enrollmentID).Updates(updates).Error
+}
@@ -0,0 +1,108 @@
+package calendar
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const (
+	// PastWindow keeps recent lessons in the feed so that clients do not drop them.
+	PastWindow = 30 * 24 * time.Hour
+	// FutureWindow limits how far ahead the feed lists lessons.
+	FutureWindow = 180 * 24 * time.Hour
+)
+
+// NewToken returns a fresh random feed token.
+func NewToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CourseIDs lists the courses the user is currently enrolled in.
+func CourseIDs(user models.User, now time.Time) ([]uint, error) {
+	var ids []uint
+	err := db.DB.Model(&models.EnrolledCourse{}).
+		Where("user_id = ? AND end_date >= ?", user.ID, now.Add(-PastWindow)).
+		Pluck("course_id", &ids).Error
+	return ids, err
+}
+
+// Feed builds the user's events within the feed window.
+func Feed(user models.User, now time.Time) ([]Event, error) {
+	courseIDs, err := CourseIDs(user, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// свои занятия: как студент, как основной преподаватель без замены и как замена
+	taught := db.DB.Model(&models.Course{}).Select("id").Where("teacher_id = ?", user.ID)
+
+	mine := db.DB.Where("lessons.substitute_id = ?", user.ID).
+		Or("lessons.course_id IN (?) AND lessons.substitute_id IS NULL", taught)
+	if len(courseIDs) > 0 {
+		mine = mine.Or("lessons.course_id IN ?", courseIDs)
+	}
+
+	var lessons []models.Lesson
+
+	err = db.DB.Preload("Course").Preload("Room").
+		Where(mine).
+		Where("start_date >= ? AND start_date < ?", now.Add(-PastWindow), now.Add(FutureWindow)).
+		Order("start_date").
+		Find(&lessons).Error
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(lessons))
+	for _, lesson := range lessons {
+		summary := lesson.Course.Name + ": " + lesson.Name
+		if lesson.SubstituteID != nil && *lesson.SubstituteID == user.ID {
+			summary += " (substitute)"
+		}
+
+		location := ""
+		if lesson.Room != nil {
+			location = lesson.Room.Name
+		}
+
+		events = append(events, Event{
+			UID:         fmt.Sprintf("lesson-%d@codev-erp", lesson.ID),
+			Start:       lesson.StartDate,
+			End:         lesson.StartDate.Add(time.Duration(lesson.Duration) * time.Minute),
+			Summary:     summary,
+			Description: lesson.Description,
+			Location:    location,
+			Cancelled:   lesson.Status == "cancelled",
+			Updated:     now,
+		})
+	}
+
+	return append(events, homeworkDeadlines(lessons, now)...), nil
+}
+
+// homeworkDeadlines adds a zero-length event at each due date of the lessons' homework.
+func homeworkDeadlines(lessons []models.Lesson, now time.Time) []Event {
+	var events []Event
+	for _, lesson := range lessons {
+		if lesson.DueDate == nil {
+			continue
+		}
+		events = append(events, Event{
+			UID:       fmt.Sprintf("homework-%d@codev-erp", lesson.ID),
+			Start:     *lesson.DueDate,
+			End:       *lesson.DueDate,
+			Summary:   "Homework due: " + lesson.Course.Name + ": " + lesson.Name,
+			Cancelled: lesson.Status == "cancelled",
+			Updated:   now,
+		})
+	}
+	return events
+}
@@ -0,0 +1,90 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// Event is one VEVENT of a feed.
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	Cancelled   bool
+	Updated     time.Time
+}
+
+// Render writes an RFC 5545 calendar. Times are emitted in UTC so that no VTIMEZONE
+// block is needed; clients show them in the user's zone.
+func Render(name string, events []Event) []byte {
+	var buf bytes.Buffer
+
+	line := func(s string) {
+		buf.WriteString(fold(s))
+		buf.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Codev ERP//Calendar//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escape(name))
+	line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+
+	for _, event := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + event.UID)
+		line("DTSTAMP:" + stamp(event.Updated))
+		line("DTSTART:" + stamp(event.Start))
+		line("DTEND:" + stamp(event.End))
+		line("SUMMARY:" + escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:" + escape(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION:" + escape(event.Location))
+		}
+		if event.Cancelled {
+			line("STATUS:CANCELLED")
+		} else {
+			line("STATUS:CONFIRMED")
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+func stamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold splits content lines longer than 75 octets, never inside a UTF-8 sequence.
+func fold(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
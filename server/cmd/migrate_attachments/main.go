@@ -0,0 +1,56 @@
+// Command migrate_attachments backfills the attachments table from the file name
+// columns of lesson tasks, homework submissions and homework versions made before
+// uploads were tracked. The server only lists linked attachments, so older files stay
+// hidden until this has run. It reads every file once to record its size and
+// checksum, using the same storage settings as the server (see storage.FromEnv):
+//
+//	go run ./cmd/migrate_attachments -dry-run
+//	go run ./cmd/migrate_attachments
+//
+// Files already linked are skipped, so an interrupted run can simply be started again.
+package main
+
+import (
+	"codev_erp/attachment"
+	"codev_erp/db"
+	"codev_erp/storage"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only report what would be created")
+	flag.Parse()
+
+	if err := storage.Setup(); err != nil {
+		fail(err)
+	}
+
+	db.Connect()
+	if db.DB == nil {
+		fail(errors.New("no database connection"))
+	}
+	db.GenerateTables()
+
+	result, err := attachment.Backfill(db.DB, *dryRun)
+	if err != nil {
+		fail(err)
+	}
+
+	for _, key := range result.Missing {
+		fmt.Fprintf(os.Stderr, "missing from storage: %s\n", key)
+	}
+
+	verb := "created"
+	if *dryRun {
+		verb = "would create"
+	}
+	fmt.Printf("%s %d attachments, %d links; %d files missing\n", verb, result.Created, result.Linked, len(result.Missing))
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrate_attachments:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,94 @@
+// Command migrate_storage copies the files of the local upload area into the storage
+// configured by the environment (see storage.FromEnv), e.g. a MinIO bucket:
+//
+//	STORAGE_BACKEND=s3 S3_ENDPOINT=http://localhost:9000 S3_BUCKET=codev \
+//	S3_ACCESS_KEY=minioadmin S3_SECRET_KEY=minioadmin go run ./cmd/migrate_storage
+//
+// Files already present in the target are skipped unless -overwrite is given, so an
+// interrupted run can simply be started again.
+package main
+
+import (
+	"codev_erp/storage"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	from := flag.String("from", storage.LocalDir, "local upload directory to copy from")
+	overwrite := flag.Bool("overwrite", false, "copy files that already exist in the target")
+	dryRun := flag.Bool("dry-run", false, "only list the files that would be copied")
+	flag.Parse()
+
+	target, err := storage.FromEnv()
+	if err != nil {
+		fail(err)
+	}
+
+	if local, ok := target.(*storage.Local); ok && filepath.Clean(local.Dir) == filepath.Clean(*from) {
+		fail(fmt.Errorf("the target is the source directory %s; set STORAGE_BACKEND=s3 or STORAGE_DIR", *from))
+	}
+
+	if bucket, ok := target.(*storage.S3); ok && !*dryRun {
+		if err := bucket.EnsureBucket(); err != nil {
+			fail(err)
+		}
+	}
+
+	source := &storage.Local{Dir: *from}
+	var copied, skipped, failed int
+
+	err = source.Walk(func(name string, size int64) error {
+		if !*overwrite {
+			exists, err := target.Exists(name)
+			if err != nil {
+				return err
+			}
+			if exists {
+				skipped++
+				return nil
+			}
+		}
+
+		if *dryRun {
+			fmt.Printf("would copy %s (%d bytes)\n", name, size)
+			copied++
+			return nil
+		}
+
+		if err := copyFile(source, target, name, size); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to copy %s: %v\n", name, err)
+			failed++
+			return nil
+		}
+
+		fmt.Printf("copied %s\n", name)
+		copied++
+		return nil
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	fmt.Printf("copied %d, skipped %d, failed %d\n", copied, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func copyFile(source storage.Storage, target storage.Storage, name string, size int64) error {
+	r, err := source.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return target.Put(name, r, size)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrate_storage:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,13 @@
+// Package config holds settings shared by several parts of the server.
+package config
+
+import "os"
+
+// PublicURL is the externally reachable base URL of the server, used wherever a link
+// leaves the app: payment checkouts and webhooks, calendar feeds.
+func PublicURL() string {
+	if url := os.Getenv("PUBLIC_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:8080"
+}
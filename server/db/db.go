@@ -3,6 +3,7 @@ package db
 import (
 	"codev_erp/db/models"
 	"codev_erp/logger"
+	"fmt"
 	"log/slog"
 
 	"gorm.io/driver/postgres"
@@ -28,12 +29,213 @@ func Connect() {
 func GenerateTables() {
 	if DB != nil {
 		logger.Log("Generating tables...", slog.LevelInfo)
+		migrateCoursePricing()
+		dedupeHomework()
+
+		// AutoMigrate не обновляет существующие CHECK-ограничения; пересоздаём после добавления вида 'quiz'
+		DB.Exec("ALTER TABLE IF EXISTS grade_records DROP CONSTRAINT IF EXISTS chk_grade_records_kind")
+
 		err := DB.AutoMigrate(&models.User{}, &models.Course{}, &models.EnrolledCourse{},
 			&models.Lesson{}, &models.LessonTasks{}, &models.UsersHomework{},
-			&models.Lead{}, &models.Sales{})
+			&models.Lead{}, &models.Sales{}, &models.Installment{}, &models.Discount{},
+			&models.Document{}, &models.DocumentCounter{}, &models.Branding{}, &models.Notification{},
+			&models.Payment{}, &models.LedgerEntry{},
+			&models.PayRate{}, &models.PayrollStatement{}, &models.PayrollLine{}, &models.PayrollAdjustment{},
+			&models.CourseSchedule{}, &models.ScheduleException{}, &models.Holiday{},
+			&models.Attendance{}, &models.CheckInWindow{}, &models.CheckInAttempts{},
+			&models.Room{}, &models.Resource{}, &models.TeacherAvailability{}, &models.TeacherLeave{},
+			&models.HomeworkVersion{}, &models.GradeRecord{},
+			&models.Rubric{}, &models.RubricCriterion{}, &models.RubricLevel{},
+			&models.GradingScheme{}, &models.TestSuite{}, &models.TestCase{}, &models.TestRun{},
+			&models.SimilarityReport{}, &models.SimilarityPair{}, &models.Annotation{}, &models.AnnotationReply{},
+			&models.PeerReview{}, &models.Quiz{}, &models.QuizQuestion{}, &models.QuizAttempt{},
+			&models.Attachment{})
 
 		if err != nil {
 			logger.Log("Failed to generate tables! Error: "+err.Error(), slog.LevelError)
 		}
+
+		migrateLegacyRooms()
+		migrateDueDates()
+
+		// оценки, выставленные до появления штрафов за опоздание, переносим как есть
+		DB.Exec("UPDATE users_homeworks SET effective_points = points WHERE checked AND penalty_percent = 0 AND effective_points = 0")
+
+		migrateHomeworkVersions()
+	}
+}
+
+// courses.price and courses.duration used to be free-text columns ("200", "3 months").
+// Convert them in place before AutoMigrate, which would otherwise cast "200" to 200 qəpik.
+func migrateCoursePricing() {
+	columns, err := DB.Migrator().ColumnTypes(&models.Course{})
+	if err != nil {
+		return
+	}
+
+	for _, column := range columns {
+		if column.DatabaseTypeName() != "text" {
+			continue
+		}
+
+		var query string
+		switch column.Name() {
+		case "price":
+			query = `ALTER TABLE courses ALTER COLUMN price TYPE bigint USING
+				COALESCE(ROUND(NULLIF(regexp_replace(replace(price, ',', '.'), '[^0-9.]', '', 'g'), '')::numeric * 100), 0)::bigint`
+		case "duration":
+			// "1 year" или "6-8 weeks" в месяцы не угадываем: такие курсы получают 1 и
+			// попадают в лог для ручной проверки
+			logUnclearDurations()
+			query = `ALTER TABLE courses ALTER COLUMN duration TYPE bigint USING
+				CASE WHEN duration ~* '` + monthsPattern + `' THEN substring(duration FROM '\d+')::bigint ELSE 1 END`
+		default:
+			continue
+		}
+
+		if err := DB.Exec(query).Error; err != nil {
+			logger.Log("Failed to migrate courses."+column.Name()+"! Error: "+err.Error(), slog.LevelError)
+		}
+	}
+}
+
+// A student has one homework record per lesson, but concurrent submissions could
+// create a second one before idx_homework_user_lesson existed. Keep the most advanced
+// record (highest version, then the oldest) so the index can be built; the others go
+// with their versions and grades.
+func dedupeHomework() {
+	if !DB.Migrator().HasTable(&models.UsersHomework{}) || DB.Migrator().HasIndex(&models.UsersHomework{}, "idx_homework_user_lesson") {
+		return
+	}
+
+	// до появления версий колонки version ещё нет
+	keep := "keep.id < h.id"
+	if DB.Migrator().HasColumn(&models.UsersHomework{}, "version") {
+		keep = "keep.version > h.version OR keep.version = h.version AND keep.id < h.id"
+	}
+
+	result := DB.Exec(`DELETE FROM users_homeworks h USING users_homeworks keep
+		WHERE keep.user_id = h.user_id AND keep.lesson_id = h.lesson_id AND keep.id <> h.id AND (` + keep + `)`)
+	if result.Error != nil {
+		logger.Log("Failed to dedupe homework! Error: "+result.Error.Error(), slog.LevelError)
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.Log(fmt.Sprintf("Removed %d duplicate homework records", result.RowsAffected), slog.LevelWarn)
+	}
+}
+
+// monthsPattern matches the durations that convert unambiguously: "3", "3 months".
+const monthsPattern = `^\s*\d+\s*(months?)?\s*$`
+
+func logUnclearDurations() {
+	var courses []struct {
+		ID       uint
+		Name     string
+		Duration *string
+	}
+
+	err := DB.Raw(`SELECT id, name, duration FROM courses WHERE duration IS NULL OR duration !~* '` + monthsPattern + `'`).
+		Scan(&courses).Error
+	if err != nil {
+		logger.Log("Failed to check course durations! Error: "+err.Error(), slog.LevelError)
+		return
+	}
+
+	for _, course := range courses {
+		duration := "<empty>"
+		if course.Duration != nil {
+			duration = *course.Duration
+		}
+		logger.Log(fmt.Sprintf("Course %d (%s): duration %q is not a number of months, set to 1 month; please review", course.ID, course.Name, duration), slog.LevelWarn)
+	}
+}
+
+// lessons.room and course_schedules.room used to hold the room name as free text.
+// Turn every distinct name into a Room, link it through room_id and drop the old column.
+func migrateLegacyRooms() {
+	for _, table := range []string{"lessons", "course_schedules"} {
+		if !DB.Migrator().HasColumn(table, "room") {
+			continue
+		}
+
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			queries := []string{
+				`INSERT INTO rooms (name, capacity, location, active)
+					SELECT DISTINCT trim(room), 0, '', true FROM ` + table + ` WHERE trim(room) <> ''
+					ON CONFLICT (name) DO NOTHING`,
+				`UPDATE ` + table + ` SET room_id = rooms.id FROM rooms
+					WHERE rooms.name = trim(` + table + `.room) AND ` + table + `.room_id IS NULL`,
+				`ALTER TABLE ` + table + ` DROP COLUMN room`,
+			}
+
+			for _, query := range queries {
+				if err := tx.Exec(query).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		if err != nil {
+			logger.Log("Failed to migrate "+table+".room! Error: "+err.Error(), slog.LevelError)
+		}
+	}
+}
+
+// The homework deadline used to be copied onto every lesson_tasks row of the lesson,
+// with an empty row holding it when no files were uploaded yet. Move it to lessons,
+// drop those placeholder rows and the old column.
+func migrateDueDates() {
+	if !DB.Migrator().HasColumn("lesson_tasks", "due_date") {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		queries := []string{
+			`UPDATE lessons SET due_date = t.due_date FROM (
+				SELECT lesson_id, MAX(due_date) AS due_date FROM lesson_tasks GROUP BY lesson_id
+			) t WHERE t.lesson_id = lessons.id AND t.due_date IS NOT NULL AND lessons.due_date IS NULL`,
+			`DELETE FROM lesson_tasks t
+				WHERE COALESCE(t.homework::text, 'null') IN ('null', '[]') AND COALESCE(t.classwork::text, 'null') IN ('null', '[]')
+				AND NOT EXISTS (SELECT 1 FROM lesson_task_homework_files f WHERE f.lesson_tasks_id = t.id)
+				AND NOT EXISTS (SELECT 1 FROM lesson_task_classwork_files f WHERE f.lesson_tasks_id = t.id)`,
+			`ALTER TABLE lesson_tasks DROP COLUMN due_date`,
+		}
+
+		for _, query := range queries {
+			if err := tx.Exec(query).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		logger.Log("Failed to migrate homework due dates! Error: "+err.Error(), slog.LevelError)
+	}
+}
+
+// Homework submitted before versioning becomes version 1, and existing grades
+// become the first entry of the grade history.
+func migrateHomeworkVersions() {
+	queries := []string{
+		`UPDATE users_homeworks SET status = 'graded' WHERE checked AND status = 'submitted'`,
+		`INSERT INTO homework_versions (homework_id, number, files, submitted_at, late, days_late, penalty_percent)
+			SELECT h.id, 1, h.homework, h.start_date, h.late, h.days_late, h.penalty_percent FROM users_homeworks h
+			WHERE NOT EXISTS (SELECT 1 FROM homework_versions v WHERE v.homework_id = h.id)`,
+		`INSERT INTO grade_records (homework_id, version, kind, points, effective_points, comment, graded_by, graded_at)
+			SELECT h.id, h.version, 'grade', h.points, h.effective_points, h.comment, COALESCE(c.teacher_id, 0), h.start_date
+			FROM users_homeworks h
+			JOIN lessons l ON l.id = h.lesson_id
+			JOIN courses c ON c.id = l.course_id
+			WHERE h.checked AND NOT EXISTS (SELECT 1 FROM grade_records g WHERE g.homework_id = h.id)`,
+	}
+
+	for _, query := range queries {
+		if err := DB.Exec(query).Error; err != nil {
+			logger.Log("Failed to migrate homework versions! Error: "+err.Error(), slog.LevelError)
+			return
+		}
 	}
 }
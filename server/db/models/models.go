@@ -2,6 +2,7 @@ package models
 
 import (
 	"codev_erp/logger"
+	"codev_erp/money"
 	"log/slog"
 	"time"
 
@@ -20,6 +21,9 @@ type User struct {
 	LastLogin  *time.Time `json:"lastLogin"`
 	Avatar     *string    `json:"avatar"`
 
+	// Секрет в ссылке на iCal-ленту; nil — лента отключена
+	CalendarToken *string `gorm:"uniqueIndex" json:"-"`
+
 	// Связи
 	CoursesTaught   []Course         `gorm:"foreignKey:TeacherID"` // 1:N (User → Courses)
 	EnrolledCourses []EnrolledCourse `gorm:"foreignKey:UserID"`    // 1:N (User → EnrolledCourses)
@@ -30,8 +34,21 @@ type Course struct {
 	Name         string `gorm:"not null" json:"name"`
 	Description  string `gorm:"not null" json:"description"`
 	PreviewImage string `gorm:"not null" json:"previewImage"`
-	Duration     string `gorm:"not null" json:"duration"`
-	Price        string `gorm:"not null" json:"price"`
+	Duration     uint   `gorm:"not null;default:1" json:"duration"` // в месяцах
+
+	// Цена за расчётный период (месяц или весь курс)
+	Price         money.Amount `gorm:"not null;default:0" json:"price"`
+	Currency      string       `gorm:"not null;size:3;default:'AZN'" json:"currency"`
+	BillingPeriod string       `gorm:"not null;default:'monthly';check: billing_period in ('monthly', 'full_term')" json:"billingPeriod"`
+
+	// Политика сдачи ДЗ после срока: accept, penalize (LatePenaltyPercent за каждый день) или reject.
+	// LateCutoffDays > 0 — после стольких дней опоздания работа не принимается совсем
+	LatePolicy         string `gorm:"not null;default:'accept';check: late_policy in ('accept', 'penalize', 'reject')" json:"latePolicy"`
+	LatePenaltyPercent uint   `gorm:"not null;default:0;check: late_penalty_percent <= 100" json:"latePenaltyPercent"`
+	LateCutoffDays     uint   `gorm:"not null;default:0" json:"lateCutoffDays"`
+
+	// Рубрика по умолчанию для всех занятий курса
+	RubricID *uint `json:"rubricID"`
 
 	TeacherID *uint // внешний ключ
 	Teacher   User  `gorm:"foreignKey:TeacherID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"teacher"`
@@ -47,10 +64,41 @@ type EnrolledCourse struct {
 	EndDate   time.Time
 	Paid      bool      `json:"paid"`
 	PaidDate  time.Time `json:"paid_date"`
+	Overdue   bool      `gorm:"not null;default:false" json:"overdue"`
+
+	// Полная стоимость обучения по договору: ListPrice до скидки, Price — к оплате
+	ListPrice  money.Amount `gorm:"not null;default:0" json:"listPrice"`
+	Price      money.Amount `gorm:"not null;default:0" json:"price"`
+	Currency   string       `gorm:"not null;size:3;default:'AZN'" json:"currency"`
+	DiscountID *uint        `json:"discountID"`
+
+	Discount *Discount `gorm:"foreignKey:DiscountID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"discount,omitempty"`
 
 	// Референсы (внешние ключи)
 	User   User   `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Course Course `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	Installments []Installment `gorm:"foreignKey:EnrollmentID" json:"installments,omitempty"`
+}
+
+// Installment is one scheduled payment of an enrollment's installment plan.
+type Installment struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	EnrollmentID uint         `gorm:"not null;index" json:"enrollmentID"`
+	Number       uint         `gorm:"not null" json:"number"`
+	DueDate      time.Time    `gorm:"not null" json:"dueDate"`
+	Amount       money.Amount `gorm:"not null" json:"amount"`
+	Currency     string       `gorm:"not null;size:3" json:"currency"`
+	PaidAmount   money.Amount `gorm:"not null;default:0" json:"paidAmount"` // частичная оплата
+	Paid         bool         `gorm:"not null;default:false" json:"paid"`
+	PaidDate     *time.Time   `json:"paidDate"`
+
+	// Состояние напоминаний: '', due_soon, overdue, final_notice
+	Overdue        bool       `gorm:"not null;default:false" json:"overdue"`
+	ReminderStage  string     `gorm:"not null;default:''" json:"reminderStage"`
+	LastReminderAt *time.Time `json:"lastReminderAt"`
+
+	Enrollment EnrolledCourse `gorm:"foreignKey:EnrollmentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
 type Lesson struct {
@@ -59,35 +107,757 @@ type Lesson struct {
 	Name        string    `gorm:"not null" json:"name"`
 	Description string    `gorm:"not null" json:"description"`
 	StartDate   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"startDate"`
+	Duration    uint      `gorm:"not null;default:90" json:"duration"` // в минутах
+	RoomID      *uint     `gorm:"index" json:"roomID"`
+	// Замещающий преподаватель на это занятие; курс остаётся за основным
+	SubstituteID *uint `gorm:"index" json:"substituteID"`
+	RubricID     *uint `json:"rubricID"` // иначе действует рубрика курса
+	// Категория журнала и максимум баллов, от которого считается процент
+	Category  string `gorm:"not null;default:'homework';check: category in ('homework', 'classwork', 'exam')" json:"category"`
+	MaxPoints uint   `gorm:"not null;default:100;check: max_points > 0" json:"maxPoints"`
+	// Взаимная проверка: сколько рецензий получает каждая работа; 0 — выключена
+	PeerReviewCount       uint       `gorm:"not null;default:0" json:"peerReviewCount"`
+	PeerReviewsAssignedAt *time.Time `json:"peerReviewsAssignedAt"`
+	// Срок сдачи ДЗ; nil — без срока
+	DueDate    *time.Time `json:"dueDate"`
+	Status     string     `gorm:"not null;default:'scheduled';check: status in ('scheduled', 'cancelled')" json:"status"`
+	ScheduleID *uint      `gorm:"index" json:"scheduleID"` // занятие сгенерировано из расписания
+
+	// Исходное время в расписании; не меняется при переносе, чтобы повторная генерация не создала дубль
+	OriginalStart *time.Time `json:"originalStart"`
+
+	Course     Course          `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"course"`
+	Schedule   *CourseSchedule `gorm:"foreignKey:ScheduleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+	Room       *Room           `gorm:"foreignKey:RoomID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"room,omitempty"`
+	Substitute *User           `gorm:"foreignKey:SubstituteID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"substitute,omitempty"`
+	Resources  []Resource      `gorm:"many2many:lesson_resources;" json:"resources,omitempty"`
+	Tasks      []LessonTasks   `gorm:"foreignKey:LessonID" json:"tasks"` // привязка по LessonID
+}
+
+// TeacherAvailability is a weekly window in which a teacher can take lessons.
+// A teacher without any windows is treated as always available.
+type TeacherAvailability struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	TeacherID uint   `gorm:"not null;index" json:"teacherID"`
+	Weekday   int    `gorm:"not null;check: weekday between 0 and 6" json:"weekday"` // 0 — воскресенье
+	StartTime string `gorm:"not null;size:5" json:"startTime"`
+	EndTime   string `gorm:"not null;size:5" json:"endTime"`
+	Timezone  string `gorm:"not null;default:'Asia/Baku'" json:"timezone"`
+
+	Teacher User `gorm:"foreignKey:TeacherID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// TeacherLeave is a period in which the teacher is away, e.g. sick leave or vacation.
+type TeacherLeave struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TeacherID uint      `gorm:"not null;index" json:"teacherID"`
+	Kind      string    `gorm:"not null;check: kind in ('sick', 'vacation', 'other')" json:"kind"`
+	StartDate time.Time `gorm:"not null" json:"startDate"`
+	EndDate   time.Time `gorm:"not null" json:"endDate"`
+	Reason    string    `json:"reason"`
+	CreatedBy uint      `gorm:"not null" json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Teacher User `gorm:"foreignKey:TeacherID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Room is a classroom that lessons are booked into.
+type Room struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Name     string `gorm:"unique;not null" json:"name"`
+	Capacity uint   `gorm:"not null;default:0" json:"capacity"` // 0 — не ограничена
+	Location string `json:"location"`
+	Active   bool   `gorm:"not null;default:true" json:"active"`
+}
+
+// Resource is shared equipment, e.g. a projector from the pool, booked per lesson.
+type Resource struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Name   string `gorm:"unique;not null" json:"name"`
+	Kind   string `gorm:"not null" json:"kind"`
+	Active bool   `gorm:"not null;default:true" json:"active"`
+}
+
+// CourseSchedule is a weekly recurring slot from which lesson occurrences are generated.
+// StartTime is wall-clock time ("18:30") in Timezone.
+type CourseSchedule struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CourseID   uint       `gorm:"not null;index" json:"courseID"`
+	Weekday    int        `gorm:"not null;check: weekday between 0 and 6" json:"weekday"` // 0 — воскресенье
+	StartTime  string     `gorm:"not null;size:5" json:"startTime"`
+	Duration   uint       `gorm:"not null;default:90" json:"duration"`
+	RoomID     *uint      `json:"roomID"`
+	Timezone   string     `gorm:"not null;default:'Asia/Baku'" json:"timezone"`
+	ValidFrom  *time.Time `json:"validFrom"`
+	ValidUntil *time.Time `json:"validUntil"`
+
+	Course     Course              `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Room       *Room               `gorm:"foreignKey:RoomID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"room,omitempty"`
+	Exceptions []ScheduleException `gorm:"foreignKey:ScheduleID" json:"exceptions,omitempty"`
+}
+
+// ScheduleException is a date on which a schedule does not produce a lesson.
+type ScheduleException struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ScheduleID uint      `gorm:"not null;index" json:"scheduleID"`
+	Date       time.Time `gorm:"not null;type:date" json:"date"`
+	Reason     string    `json:"reason"`
+
+	Schedule CourseSchedule `gorm:"foreignKey:ScheduleID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Holiday blocks lesson generation school-wide, or for one course when CourseID is set.
+type Holiday struct {
+	ID       uint      `gorm:"primaryKey" json:"id"`
+	Date     time.Time `gorm:"not null;type:date;index" json:"date"`
+	Name     string    `gorm:"not null" json:"name"`
+	CourseID *uint     `json:"courseID"`
+
+	Course *Course `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Attendance is one student's presence at one lesson, marked by the teacher or by self check-in.
+type Attendance struct {
+	ID       uint      `gorm:"primaryKey" json:"id"`
+	LessonID uint      `gorm:"not null;uniqueIndex:idx_attendance_lesson_user" json:"lessonID"`
+	UserID   uint      `gorm:"not null;uniqueIndex:idx_attendance_lesson_user;index" json:"userID"`
+	Status   string    `gorm:"not null;check: status in ('present', 'late', 'absent', 'excused')" json:"status"`
+	Method   string    `gorm:"not null;default:'manual'" json:"method"`
+	Note     string    `json:"note"`
+	MarkedBy uint      `gorm:"not null" json:"markedBy"`
+	MarkedAt time.Time `gorm:"not null" json:"markedAt"`
+
+	Lesson Lesson `gorm:"foreignKey:LessonID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	User   User   `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// CheckInWindow is an open self check-in for a lesson. Codes rotate and are derived
+// from Secret, so nothing but the window itself has to be stored.
+type CheckInWindow struct {
+	ID       uint       `gorm:"primaryKey" json:"id"`
+	LessonID uint       `gorm:"not null;uniqueIndex" json:"lessonID"`
+	Secret   string     `gorm:"not null" json:"-"`
+	OpenedBy uint       `gorm:"not null" json:"openedBy"`
+	OpensAt  time.Time  `gorm:"not null" json:"opensAt"`
+	ClosesAt time.Time  `gorm:"not null" json:"closesAt"`
+	ClosedAt *time.Time `json:"closedAt"`
 
-	Course Course        `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"course"`
-	Tasks  []LessonTasks `gorm:"foreignKey:LessonID" json:"tasks"` // привязка по LessonID
+	Lesson Lesson `gorm:"foreignKey:LessonID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// CheckInAttempts counts a student's wrong codes for one check-in window. Reopening the
+// check-in replaces the window, which drops its counts with it.
+type CheckInAttempts struct {
+	ID       uint `gorm:"primaryKey" json:"id"`
+	WindowID uint `gorm:"not null;uniqueIndex:idx_check_in_attempts_window_user" json:"windowID"`
+	UserID   uint `gorm:"not null;uniqueIndex:idx_check_in_attempts_window_user" json:"userID"`
+	Wrong    uint `gorm:"not null;default:0" json:"wrong"`
+
+	Window CheckInWindow `gorm:"foreignKey:WindowID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	User   User          `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Attachment is an uploaded file: Key is its unique name in storage, the rest is
+// what was known about it at upload time.
+type Attachment struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Key          string `gorm:"not null;uniqueIndex" json:"key"`
+	OriginalName string `gorm:"not null" json:"originalName"`
+	Size         int64  `gorm:"not null;default:0" json:"size"`
+	MimeType     string `gorm:"not null" json:"mimeType"`
+	// SHA-256 содержимого в hex
+	Checksum   string    `gorm:"not null" json:"checksum"`
+	UploadedBy *uint     `gorm:"index" json:"uploadedBy"`
+	CreatedAt  time.Time `json:"createdAt"`
 }
 
 type LessonTasks struct {
-	ID        uint     `gorm:"primaryKey"`
-	LessonID  uint     `gorm:"not null"`
-	Homework  []string `gorm:"type:json;serializer:json" json:"homework"`
-	Classwork []string `gorm:"type:json;serializer:json" json:"classwork"`
+	ID       uint `gorm:"primaryKey"`
+	LessonID uint `gorm:"not null"`
+	// Имена файлов до появления вложений; читает только перенос attachment.Backfill
+	LegacyHomework  []string `gorm:"column:homework;type:json;serializer:json" json:"-"`
+	LegacyClasswork []string `gorm:"column:classwork;type:json;serializer:json" json:"-"`
+
+	HomeworkFiles  []Attachment `gorm:"many2many:lesson_task_homework_files;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"homeworkFiles"`
+	ClassworkFiles []Attachment `gorm:"many2many:lesson_task_classwork_files;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"classworkFiles"`
 
 	Lesson Lesson `gorm:"foreignKey:LessonID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"lesson"`
 }
 
 type UsersHomework struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null" json:"userID"`
-	LessonID  uint      `gorm:"not null" json:"lessonID"`
-	Homework  []string  `gorm:"type:json;serializer:json" json:"homework"`
-	StartDate time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"startDate"`
+	ID uint `gorm:"primaryKey" json:"id"`
+	// Одна запись на студента и занятие: новые сдачи становятся её версиями
+	UserID   uint `gorm:"not null;uniqueIndex:idx_homework_user_lesson" json:"userID"`
+	LessonID uint `gorm:"not null;uniqueIndex:idx_homework_user_lesson" json:"lessonID"`
+	// Имена файлов до появления вложений; читает только перенос attachment.Backfill
+	LegacyHomework []string  `gorm:"column:homework;type:json;serializer:json" json:"-"`
+	StartDate      time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"startDate"`
 
 	Points  uint   `gorm:"not null;default:0" json:"points"`
 	Checked bool   `gorm:"not null;default:false" json:"checked"`
 	Comment string `gorm:"type:text" json:"comment"`
 
+	// Опоздание фиксируется при сдаче; EffectivePoints — баллы после штрафа
+	Late            bool `gorm:"not null;default:false" json:"late"`
+	DaysLate        uint `gorm:"not null;default:0" json:"daysLate"`
+	PenaltyPercent  uint `gorm:"not null;default:0" json:"penaltyPercent"`
+	EffectivePoints uint `gorm:"not null;default:0" json:"effectivePoints"`
+
+	// submitted → graded; changes_requested возвращает работу студенту на доработку.
+	// Version — номер последней версии, Files — её файлы
+	Status string `gorm:"not null;default:'submitted';check: status in ('submitted', 'changes_requested', 'graded')" json:"status"`
+	// Баллы по критериям рубрики; снимок, не зависящий от последующих правок рубрики
+	Breakdown []CriterionScore `gorm:"type:json;serializer:json" json:"breakdown"`
+	Version   uint             `gorm:"not null;default:1" json:"version"`
+	// Преподаватель, взявший работу на проверку; бронь истекает через homework.ClaimTTL
+	ClaimedBy *uint      `gorm:"index" json:"claimedBy"`
+	ClaimedAt *time.Time `json:"claimedAt"`
+
+	Files []Attachment `gorm:"many2many:users_homework_files;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"files"`
+
 	User   User   `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user"`
 	Lesson Lesson `gorm:"foreignKey:LessonID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"lesson"`
 }
 
+// HomeworkVersion keeps the files of every submitted version of a homework.
+type HomeworkVersion struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	HomeworkID     uint      `gorm:"not null;uniqueIndex:idx_homework_version" json:"homeworkID"`
+	Number         uint      `gorm:"not null;uniqueIndex:idx_homework_version" json:"number"`
+	SubmittedAt    time.Time `gorm:"not null" json:"submittedAt"`
+	Late           bool      `gorm:"not null;default:false" json:"late"`
+	DaysLate       uint      `gorm:"not null;default:0" json:"daysLate"`
+	PenaltyPercent uint      `gorm:"not null;default:0" json:"penaltyPercent"`
+	// Имена файлов до появления вложений; читает только перенос attachment.Backfill
+	LegacyFiles []string `gorm:"column:files;type:json;serializer:json" json:"-"`
+
+	Files []Attachment `gorm:"many2many:homework_version_files;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"files"`
+
+	Homework UsersHomework `gorm:"foreignKey:HomeworkID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// GradeRecord is one entry of a homework's grading history: a grade or a request
+// for changes. The latest grade is mirrored on UsersHomework.
+type GradeRecord struct {
+	ID              uint             `gorm:"primaryKey" json:"id"`
+	HomeworkID      uint             `gorm:"not null;index" json:"homeworkID"`
+	Version         uint             `gorm:"not null" json:"version"`
+	Kind            string           `gorm:"not null;check: kind in ('grade', 'changes_requested', 'quiz')" json:"kind"`
+	Points          uint             `gorm:"not null;default:0" json:"points"`
+	EffectivePoints uint             `gorm:"not null;default:0" json:"effectivePoints"`
+	Comment         string           `gorm:"type:text" json:"comment"`
+	Breakdown       []CriterionScore `gorm:"type:json;serializer:json" json:"breakdown"`
+	GradedBy        uint             `gorm:"not null" json:"gradedBy"` // 0 — оценено автоматически (тест)
+	GradedAt        time.Time        `gorm:"not null" json:"gradedAt"`
+
+	Homework UsersHomework `gorm:"foreignKey:HomeworkID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Rubric is a reusable grading template. CourseID nil makes it available to every course.
+type Rubric struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"not null" json:"name"`
+	Description string    `json:"description"`
+	CourseID    *uint     `gorm:"index" json:"courseID"`
+	CreatedBy   uint      `gorm:"not null" json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	Course   *Course           `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Criteria []RubricCriterion `gorm:"foreignKey:RubricID" json:"criteria"`
+}
+
+// RubricCriterion is one graded aspect of a rubric, e.g. correctness or code style.
+type RubricCriterion struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	RubricID    uint   `gorm:"not null;index" json:"rubricID"`
+	Name        string `gorm:"not null" json:"name"`
+	Description string `json:"description"`
+	Position    uint   `gorm:"not null;default:0" json:"position"`
+
+	Rubric Rubric        `gorm:"foreignKey:RubricID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Levels []RubricLevel `gorm:"foreignKey:CriterionID" json:"levels"`
+}
+
+// RubricLevel is a descriptor of one achievement level of a criterion and its points.
+type RubricLevel struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	CriterionID uint   `gorm:"not null;index" json:"criterionID"`
+	Name        string `gorm:"not null" json:"name"`
+	Description string `json:"description"`
+	Points      uint   `gorm:"not null" json:"points"`
+
+	Criterion RubricCriterion `gorm:"foreignKey:CriterionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// CriterionScore is the level chosen for one criterion when grading. It is stored as
+// JSON on the homework, so names are copied rather than referenced.
+type CriterionScore struct {
+	CriterionID uint   `json:"criterionID"`
+	Criterion   string `json:"criterion"`
+	LevelID     uint   `json:"levelID"`
+	Level       string `json:"level"`
+	Points      uint   `json:"points"`
+	MaxPoints   uint   `json:"maxPoints"`
+	Comment     string `json:"comment"`
+}
+
+// TestSuite is the set of automated tests run against code homework of a lesson.
+// Entrypoint picks the file to run when a submission has several; the first file
+// with the language's extension is used otherwise.
+type TestSuite struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	LessonID      uint      `gorm:"not null;uniqueIndex" json:"lessonID"`
+	Language      string    `gorm:"not null;check: language in ('python', 'javascript')" json:"language"`
+	Entrypoint    string    `json:"entrypoint"`
+	TimeLimitMs   uint      `gorm:"not null;default:5000" json:"timeLimitMs"`  // на один тест
+	MemoryLimitMB uint      `gorm:"not null;default:256" json:"memoryLimitMB"` // на один тест
+	UpdatedAt     time.Time `json:"updatedAt"`
+
+	Lesson Lesson     `gorm:"foreignKey:LessonID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Cases  []TestCase `gorm:"foreignKey:SuiteID" json:"cases"`
+}
+
+// TestCase feeds Stdin to the program and expects ExpectedOutput on stdout. Hidden
+// cases are not shown to students, so the tests cannot simply be hard-coded.
+type TestCase struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	SuiteID        uint   `gorm:"not null;index" json:"suiteID"`
+	Name           string `gorm:"not null" json:"name"`
+	Stdin          string `gorm:"type:text" json:"stdin"`
+	ExpectedOutput string `gorm:"type:text" json:"expectedOutput"`
+	Points         uint   `gorm:"not null;default:1" json:"points"`
+	Hidden         bool   `gorm:"not null;default:false" json:"hidden"`
+	Position       uint   `gorm:"not null;default:0" json:"position"`
+
+	Suite TestSuite `gorm:"foreignKey:SuiteID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// TestRun is one queued or finished run of a suite against a homework version.
+type TestRun struct {
+	ID              uint         `gorm:"primaryKey" json:"id"`
+	HomeworkID      uint         `gorm:"not null;index" json:"homeworkID"`
+	Version         uint         `gorm:"not null" json:"version"`
+	Status          string       `gorm:"not null;default:'queued';index;check: status in ('queued', 'running', 'done', 'error')" json:"status"`
+	Results         []TestResult `gorm:"type:json;serializer:json" json:"results"`
+	SuggestedPoints uint         `gorm:"not null;default:0" json:"suggestedPoints"`
+	MaxPoints       uint         `gorm:"not null;default:0" json:"maxPoints"`
+	Error           string       `gorm:"type:text" json:"error"`
+	QueuedAt        time.Time    `gorm:"not null" json:"queuedAt"`
+	StartedAt       *time.Time   `json:"startedAt"`
+	FinishedAt      *time.Time   `json:"finishedAt"`
+
+	Homework UsersHomework `gorm:"foreignKey:HomeworkID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// TestResult is the outcome of one test case, stored as JSON on the run.
+// Status is passed, failed, timeout, memory or error.
+type TestResult struct {
+	TestCaseID uint   `json:"testCaseID"`
+	Name       string `json:"name"`
+	Hidden     bool   `json:"hidden"`
+	Status     string `json:"status"`
+	Points     uint   `json:"points"`
+	MaxPoints  uint   `json:"maxPoints"`
+	DurationMs int64  `json:"durationMs"`
+	Output     string `json:"output,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+}
+
+// Annotation is a feedback thread pinned to a place in one file of a submission.
+// File is the stored file name, so the thread stays with the version it was made on.
+type Annotation struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	HomeworkID uint             `gorm:"not null;index" json:"homeworkID"`
+	Version    uint             `gorm:"not null" json:"version"`
+	File       string           `gorm:"not null" json:"file"`
+	Anchor     AnnotationAnchor `gorm:"type:json;serializer:json" json:"anchor"`
+	AuthorID   uint             `gorm:"not null" json:"authorID"`
+	Body       string           `gorm:"type:text;not null" json:"body"`
+	Resolved   bool             `gorm:"not null;default:false" json:"resolved"`
+	ResolvedBy *uint            `json:"resolvedBy"`
+	ResolvedAt *time.Time       `json:"resolvedAt"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+
+	Homework UsersHomework     `gorm:"foreignKey:HomeworkID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Author   User              `gorm:"foreignKey:AuthorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"author"`
+	Replies  []AnnotationReply `gorm:"foreignKey:AnnotationID" json:"replies"`
+}
+
+// AnnotationAnchor locates an annotation. "lines" uses StartLine..EndLine of a text
+// file; "region" marks a rectangle on an image or a PDF page, in fractions of its size.
+type AnnotationAnchor struct {
+	Kind      string  `json:"kind"`
+	StartLine int     `json:"startLine,omitempty"`
+	EndLine   int     `json:"endLine,omitempty"`
+	Page      int     `json:"page,omitempty"` // страница PDF с 1; 0 для изображений
+	X         float64 `json:"x,omitempty"`
+	Y         float64 `json:"y,omitempty"`
+	Width     float64 `json:"width,omitempty"`
+	Height    float64 `json:"height,omitempty"`
+}
+
+type AnnotationReply struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	AnnotationID uint      `gorm:"not null;index" json:"annotationID"`
+	AuthorID     uint      `gorm:"not null" json:"authorID"`
+	Body         string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+
+	Annotation Annotation `gorm:"foreignKey:AnnotationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Author     User       `gorm:"foreignKey:AuthorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"author"`
+}
+
+// PeerReview is one student's anonymous review of another student's submission.
+// Points and Breakdown follow the lesson's rubric when it has one.
+type PeerReview struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	HomeworkID  uint             `gorm:"not null;uniqueIndex:idx_peer_review" json:"homeworkID"`
+	ReviewerID  uint             `gorm:"not null;uniqueIndex:idx_peer_review;index" json:"reviewerID"`
+	Status      string           `gorm:"not null;default:'assigned';check: status in ('assigned', 'submitted')" json:"status"`
+	Points      uint             `gorm:"not null;default:0" json:"points"`
+	Breakdown   []CriterionScore `gorm:"type:json;serializer:json" json:"breakdown"`
+	Comment     string           `gorm:"type:text" json:"comment"`
+	AssignedAt  time.Time        `gorm:"not null" json:"assignedAt"`
+	SubmittedAt *time.Time       `json:"submittedAt"`
+
+	Homework UsersHomework `gorm:"foreignKey:HomeworkID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Reviewer User          `gorm:"foreignKey:ReviewerID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Quiz is an auto-scored test attached to a lesson. Its result becomes the student's
+// grade for the lesson, scaled to the lesson's MaxPoints; the best attempt counts.
+type Quiz struct {
+	ID               uint   `gorm:"primaryKey" json:"id"`
+	LessonID         uint   `gorm:"not null;uniqueIndex" json:"lessonID"`
+	Title            string `gorm:"not null" json:"title"`
+	Description      string `gorm:"type:text" json:"description"`
+	TimeLimitMinutes uint   `gorm:"not null;default:0" json:"timeLimitMinutes"` // 0 — без ограничения
+	MaxAttempts      uint   `gorm:"not null;default:1" json:"maxAttempts"`      // 0 — без ограничения
+	// Порядок вопросов и вариантов в каждой попытке случайный;
+	// QuestionCount > 0 — в попытку попадает случайная выборка из вопросов
+	ShuffleQuestions bool       `gorm:"not null;default:false" json:"shuffleQuestions"`
+	ShuffleOptions   bool       `gorm:"not null;default:false" json:"shuffleOptions"`
+	QuestionCount    uint       `gorm:"not null;default:0" json:"questionCount"`
+	OpensAt          *time.Time `json:"opensAt"`
+	ClosesAt         *time.Time `json:"closesAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+
+	Lesson    Lesson         `gorm:"foreignKey:LessonID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Questions []QuizQuestion `gorm:"foreignKey:QuizID" json:"questions,omitempty"`
+}
+
+// QuizQuestion is one question of a quiz. Choice questions use Options, short answer
+// and code output questions list the accepted Answers, numeric ones Number and Tolerance.
+type QuizQuestion struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	QuizID   uint   `gorm:"not null;index" json:"quizID"`
+	Position uint   `gorm:"not null;default:0" json:"position"`
+	Kind     string `gorm:"not null;check: kind in ('single', 'multiple', 'short', 'numeric', 'code_output')" json:"kind"`
+	Text     string `gorm:"type:text;not null" json:"text"`
+	Code     string `gorm:"type:text" json:"code"` // фрагмент программы для вопросов «что выведет код»
+	Points   uint   `gorm:"not null;default:1" json:"points"`
+
+	Options       []QuizOption `gorm:"type:json;serializer:json" json:"options,omitempty"`
+	Answers       []string     `gorm:"type:json;serializer:json" json:"answers,omitempty"`
+	CaseSensitive bool         `gorm:"not null;default:false" json:"caseSensitive"`
+	Number        *float64     `json:"number,omitempty"`
+	Tolerance     float64      `gorm:"not null;default:0" json:"tolerance"`
+
+	Quiz Quiz `gorm:"foreignKey:QuizID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// QuizOption is one choice of a choice question. ID is its number within the question.
+type QuizOption struct {
+	ID      uint   `json:"id"`
+	Text    string `json:"text"`
+	Correct bool   `json:"correct"`
+}
+
+// QuizAttempt is one try of a student at a quiz. Drawn keeps the questions in the order
+// they were shown; Deadline is when the attempt is submitted automatically.
+type QuizAttempt struct {
+	ID          uint                 `gorm:"primaryKey" json:"id"`
+	QuizID      uint                 `gorm:"not null;uniqueIndex:idx_quiz_attempt" json:"quizID"`
+	UserID      uint                 `gorm:"not null;uniqueIndex:idx_quiz_attempt" json:"userID"`
+	Number      uint                 `gorm:"not null;uniqueIndex:idx_quiz_attempt" json:"number"`
+	Status      string               `gorm:"not null;default:'in_progress';index;check: status in ('in_progress', 'submitted')" json:"status"`
+	Drawn       []QuizDrawnQuestion  `gorm:"type:json;serializer:json" json:"-"`
+	Answers     []QuizAnswer         `gorm:"type:json;serializer:json" json:"answers"`
+	Results     []QuizQuestionResult `gorm:"type:json;serializer:json" json:"results"`
+	Score       uint                 `gorm:"not null;default:0" json:"score"`
+	MaxScore    uint                 `gorm:"not null;default:0" json:"maxScore"`
+	StartedAt   time.Time            `gorm:"not null" json:"startedAt"`
+	Deadline    *time.Time           `json:"deadline"`
+	SubmittedAt *time.Time           `json:"submittedAt"`
+
+	Quiz Quiz `gorm:"foreignKey:QuizID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user"`
+}
+
+// QuizDrawnQuestion is a question of an attempt with its options in the order shown.
+type QuizDrawnQuestion struct {
+	QuestionID uint   `json:"questionID"`
+	OptionIDs  []uint `json:"optionIDs,omitempty"`
+}
+
+// QuizAnswer is a student's answer: the chosen options, or the typed Text.
+type QuizAnswer struct {
+	QuestionID uint   `json:"questionID"`
+	OptionIDs  []uint `json:"optionIDs,omitempty"`
+	Text       string `json:"text,omitempty"`
+}
+
+// QuizQuestionResult is how one question of a submitted attempt was scored.
+type QuizQuestionResult struct {
+	QuestionID uint `json:"questionID"`
+	Correct    bool `json:"correct"`
+	Points     uint `json:"points"`
+	MaxPoints  uint `json:"maxPoints"`
+}
+
+// SimilarityReport tracks the plagiarism analysis of one lesson's submissions. It is
+// queued again whenever a submission changes and recomputed by a background job.
+type SimilarityReport struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	LessonID    uint       `gorm:"not null;uniqueIndex" json:"lessonID"`
+	Status      string     `gorm:"not null;default:'queued';index;check: status in ('queued', 'running', 'done', 'error')" json:"status"`
+	Error       string     `gorm:"type:text" json:"error"`
+	RequestedAt time.Time  `gorm:"not null" json:"requestedAt"`
+	StartedAt   *time.Time `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+
+	Lesson Lesson           `gorm:"foreignKey:LessonID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Pairs  []SimilarityPair `gorm:"foreignKey:ReportID" json:"pairs,omitempty"`
+}
+
+// SimilarityPair is two submissions that share fingerprints. PercentA is the share of
+// A's fingerprints found in B and vice versa; Score is the larger of the two.
+type SimilarityPair struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	ReportID  uint              `gorm:"not null;index" json:"reportID"`
+	HomeworkA uint              `gorm:"not null" json:"homeworkA"`
+	HomeworkB uint              `gorm:"not null" json:"homeworkB"`
+	UserA     uint              `gorm:"not null" json:"userA"`
+	UserB     uint              `gorm:"not null" json:"userB"`
+	Score     float64           `gorm:"not null;index" json:"score"`
+	PercentA  float64           `gorm:"not null" json:"percentA"`
+	PercentB  float64           `gorm:"not null" json:"percentB"`
+	Matches   []SimilarityMatch `gorm:"type:json;serializer:json" json:"matches"`
+
+	Report SimilarityReport `gorm:"foreignKey:ReportID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// SimilarityMatch is one matching span, as lines and byte offsets in each file.
+type SimilarityMatch struct {
+	FileA      string `json:"fileA"`
+	StartLineA int    `json:"startLineA"`
+	EndLineA   int    `json:"endLineA"`
+	StartA     int    `json:"startA"`
+	EndA       int    `json:"endA"`
+	FileB      string `json:"fileB"`
+	StartLineB int    `json:"startLineB"`
+	EndLineB   int    `json:"endLineB"`
+	StartB     int    `json:"startB"`
+	EndB       int    `json:"endB"`
+}
+
+// GradingScheme holds a course's gradebook settings: category weights in percent and
+// the scale that turns the final percentage into a grade.
+type GradingScheme struct {
+	CourseID         uint        `gorm:"primaryKey" json:"courseID"`
+	HomeworkWeight   uint        `gorm:"not null;default:40" json:"homeworkWeight"`
+	ClassworkWeight  uint        `gorm:"not null;default:20" json:"classworkWeight"`
+	ExamWeight       uint        `gorm:"not null;default:30" json:"examWeight"`
+	AttendanceWeight uint        `gorm:"not null;default:10" json:"attendanceWeight"`
+	Scale            []GradeBand `gorm:"type:json;serializer:json" json:"scale"`
+	UpdatedAt        time.Time   `json:"updatedAt"`
+
+	Course Course `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// GradeBand is one step of a grading scale: the grade given from MinPercent upwards.
+type GradeBand struct {
+	Grade      string  `json:"grade"`
+	MinPercent float64 `json:"minPercent"`
+}
+
+// Discount is a sibling discount, scholarship or promo price. Discounts without
+// a Code can only be assigned by an admin; CourseID nil means it applies to any course.
+type Discount struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	Name       string       `gorm:"not null" json:"name"`
+	Code       *string      `gorm:"unique" json:"code"`
+	Kind       string       `gorm:"not null;check: kind in ('percent', 'fixed')" json:"kind"`
+	Percent    uint         `gorm:"not null;default:0;check: percent <= 100" json:"percent"`
+	Amount     money.Amount `gorm:"not null;default:0" json:"amount"`
+	Currency   string       `gorm:"not null;size:3;default:'AZN'" json:"currency"`
+	CourseID   *uint        `json:"courseID"`
+	ValidFrom  *time.Time   `json:"validFrom"`
+	ValidUntil *time.Time   `json:"validUntil"`
+	MaxUses    *uint        `json:"maxUses"`
+	Uses       uint         `gorm:"not null;default:0" json:"uses"`
+	Active     bool         `gorm:"not null;default:true" json:"active"`
+
+	Course *Course `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"course,omitempty"`
+}
+
+// Payment is a checkout created with a payment provider for an enrollment's due amount.
+type Payment struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	Reference    string       `gorm:"not null;unique" json:"reference"`
+	EnrollmentID uint         `gorm:"not null;index" json:"enrollmentID"`
+	Provider     string       `gorm:"not null" json:"provider"`
+	ProviderRef  string       `gorm:"index" json:"providerRef"`
+	Amount       money.Amount `gorm:"not null" json:"amount"`
+	Currency     string       `gorm:"not null;size:3" json:"currency"`
+	Status       string       `gorm:"not null;default:'pending';check: status in ('pending', 'succeeded', 'failed')" json:"status"`
+	CheckoutURL  string       `json:"checkoutURL"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	UpdatedAt    time.Time    `json:"updatedAt"`
+
+	Enrollment EnrolledCourse `gorm:"foreignKey:EnrollmentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// LedgerEntry records money actually received (or refunded) for an enrollment.
+// ExternalID holds the provider event ID so that a redelivered webhook is booked once.
+type LedgerEntry struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	EnrollmentID uint         `gorm:"not null;index" json:"enrollmentID"`
+	PaymentID    *uint        `json:"paymentID"`
+	Kind         string       `gorm:"not null;check: kind in ('payment', 'manual', 'refund')" json:"kind"`
+	Amount       money.Amount `gorm:"not null" json:"amount"`
+	Currency     string       `gorm:"not null;size:3" json:"currency"`
+	Provider     string       `json:"provider"`
+	ExternalID   *string      `gorm:"unique" json:"externalID"`
+	Note         string       `gorm:"type:text" json:"note"`
+	CreatedAt    time.Time    `json:"createdAt"`
+
+	Enrollment EnrolledCourse `gorm:"foreignKey:EnrollmentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Payment    *Payment       `gorm:"foreignKey:PaymentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+}
+
+// Document is an issued invoice or receipt. Number is sequential per kind and year
+// without gaps, e.g. INV-2026-000042.
+type Document struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	Kind          string       `gorm:"not null;check: kind in ('invoice', 'receipt')" json:"kind"`
+	Number        string       `gorm:"not null;unique" json:"number"`
+	Year          int          `gorm:"not null" json:"year"`
+	Sequence      uint         `gorm:"not null" json:"sequence"`
+	UserID        uint         `gorm:"not null;index" json:"userID"`
+	EnrollmentID  uint         `gorm:"not null" json:"enrollmentID"`
+	InstallmentID *uint        `json:"installmentID"`
+	Amount        money.Amount `gorm:"not null" json:"amount"`
+	Currency      string       `gorm:"not null;size:3" json:"currency"`
+	File          string       `gorm:"not null" json:"file"`
+	IssuedAt      time.Time    `gorm:"not null" json:"issuedAt"`
+
+	User        User           `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Enrollment  EnrolledCourse `gorm:"foreignKey:EnrollmentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Installment *Installment   `gorm:"foreignKey:InstallmentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+}
+
+// DocumentCounter holds the last issued sequence number per document kind and year.
+type DocumentCounter struct {
+	Kind string `gorm:"primaryKey"`
+	Year int    `gorm:"primaryKey;autoIncrement:false"`
+	Last uint   `gorm:"not null;default:0"`
+}
+
+// Branding is the single row of school details printed on invoices and receipts.
+type Branding struct {
+	ID          uint   `gorm:"primaryKey" json:"-"`
+	SchoolName  string `gorm:"not null;default:'CoDev Academy'" json:"schoolName"`
+	Address     string `json:"address"`
+	Phone       string `json:"phone"`
+	Email       string `json:"email"`
+	Website     string `json:"website"`
+	TaxID       string `json:"taxID"`
+	Logo        string `json:"logo"`
+	AccentColor string `gorm:"not null;default:'#1F6FEB'" json:"accentColor"`
+	Footer      string `gorm:"type:text" json:"footer"`
+	FontFile    string `json:"fontFile"` // TTF в хранилище загрузок для кириллицы и азербайджанских букв
+}
+
+// Notification is an in-app message; other channels (email) deliver copies of it.
+type Notification struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"userID"`
+	Kind      string    `gorm:"not null" json:"kind"`
+	Title     string    `gorm:"not null" json:"title"`
+	Body      string    `gorm:"type:text" json:"body"`
+	Read      bool      `gorm:"not null;default:false" json:"read"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// PayRate is what a teacher earns per lesson, per hour or per attending student.
+// A rate with a CourseID overrides the teacher's general rate for that course.
+type PayRate struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	TeacherID  uint         `gorm:"not null;index" json:"teacherID"`
+	CourseID   *uint        `json:"courseID"`
+	Kind       string       `gorm:"not null;check: kind in ('per_lesson', 'per_hour', 'per_student')" json:"kind"`
+	Amount     money.Amount `gorm:"not null" json:"amount"`
+	Currency   string       `gorm:"not null;size:3;default:'AZN'" json:"currency"`
+	ValidFrom  time.Time    `gorm:"not null" json:"validFrom"`
+	ValidUntil *time.Time   `json:"validUntil"`
+
+	Teacher User    `gorm:"foreignKey:TeacherID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Course  *Course `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"course,omitempty"`
+}
+
+// PayrollStatement is a teacher's earnings for one month ("2026-10").
+// Once locked it is no longer recomputed or adjusted.
+type PayrollStatement struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	TeacherID   uint         `gorm:"not null;uniqueIndex:idx_payroll_teacher_period" json:"teacherID"`
+	Period      string       `gorm:"not null;size:7;uniqueIndex:idx_payroll_teacher_period" json:"period"`
+	Status      string       `gorm:"not null;default:'draft';check: status in ('draft', 'locked')" json:"status"`
+	Gross       money.Amount `gorm:"not null;default:0" json:"gross"`
+	Adjustments money.Amount `gorm:"not null;default:0" json:"adjustments"`
+	Net         money.Amount `gorm:"not null;default:0" json:"net"`
+	Currency    string       `gorm:"not null;size:3;default:'AZN'" json:"currency"`
+	ComputedAt  time.Time    `json:"computedAt"`
+	LockedAt    *time.Time   `json:"lockedAt"`
+	LockedBy    *uint        `json:"lockedBy"`
+
+	Teacher         User                `gorm:"foreignKey:TeacherID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"teacher"`
+	Lines           []PayrollLine       `gorm:"foreignKey:StatementID" json:"lines,omitempty"`
+	AdjustmentItems []PayrollAdjustment `gorm:"foreignKey:StatementID" json:"adjustmentItems,omitempty"`
+}
+
+// PayrollLine is the pay for one taught lesson.
+type PayrollLine struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	StatementID uint         `gorm:"not null;index" json:"statementID"`
+	LessonID    *uint        `json:"lessonID"`
+	CourseID    uint         `gorm:"not null" json:"courseID"`
+	Description string       `gorm:"not null" json:"description"`
+	RateKind    string       `gorm:"not null" json:"rateKind"`
+	Rate        money.Amount `gorm:"not null" json:"rate"`
+	Quantity    float64      `gorm:"not null" json:"quantity"`
+	Amount      money.Amount `gorm:"not null" json:"amount"`
+
+	Statement PayrollStatement `gorm:"foreignKey:StatementID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Lesson    *Lesson          `gorm:"foreignKey:LessonID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+}
+
+// PayrollAdjustment is a manual bonus (positive) or deduction (negative).
+type PayrollAdjustment struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	StatementID uint         `gorm:"not null;index" json:"statementID"`
+	Amount      money.Amount `gorm:"not null" json:"amount"`
+	Reason      string       `gorm:"not null;type:text" json:"reason"`
+	CreatedBy   uint         `gorm:"not null" json:"createdBy"`
+	CreatedAt   time.Time    `json:"createdAt"`
+
+	Statement PayrollStatement `gorm:"foreignKey:StatementID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
 type Lead struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	Description string    `gorm:"not null;type:text" json:"description"`
@@ -110,8 +880,14 @@ type Sales struct {
 	GroupID  uint   `gorm:"not null" json:"group"`
 	Note     string `gorm:"type:text" json:"note"`
 
-	Lead   Lead   `gorm:"foreignKey:LeadID" json:"lead"`
-	Course Course `gorm:"foreignKey:GroupID" json:"course"`
+	// Скидка, обещанная лиду; применяется при зачислении на курс
+	DiscountID *uint `json:"discountID"`
+	// Студент, которого зачислили по этой записи; скидка достаётся только ему
+	StudentID *uint `gorm:"index" json:"studentID"`
+
+	Lead     Lead      `gorm:"foreignKey:LeadID" json:"lead"`
+	Course   Course    `gorm:"foreignKey:GroupID" json:"course"`
+	Discount *Discount `gorm:"foreignKey:DiscountID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"discount,omitempty"`
 }
 
 //automatically hash user's password
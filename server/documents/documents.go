@@ -0,0 +1,160 @@
+package documents
+
+import (
+	"bytes"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/storage"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	KindInvoice = "invoice"
+	KindReceipt = "receipt"
+
+	// Dir is where generated PDFs live in the upload storage.
+	Dir = "documents"
+)
+
+var ErrNotPaid = errors.New("receipts can only be issued for paid installments or enrollments")
+
+var prefixes = map[string]string{
+	KindInvoice: "INV",
+	KindReceipt: "RCP",
+}
+
+// Issue numbers, renders and stores an invoice or receipt for an enrollment, or for a
+// single installment of it when installmentID is set. The sequence number is taken
+// inside the same transaction as the document row, so a failed render leaves no gap.
+func Issue(kind string, enrollmentID uint, installmentID *uint) (models.Document, error) {
+	var doc models.Document
+
+	prefix, ok := prefixes[kind]
+	if !ok {
+		return doc, fmt.Errorf("unknown document kind %q", kind)
+	}
+
+	var enrollment models.EnrolledCourse
+	err := db.DB.
+		Preload("User").
+		Preload("Course").
+		Preload("Installments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("number")
+		}).
+		Where("id = ?", enrollmentID).
+		First(&enrollment).Error
+	if err != nil {
+		return doc, err
+	}
+
+	lines := enrollment.Installments
+	amount := enrollment.Price
+
+	if installmentID != nil {
+		lines = nil
+		for _, installment := range enrollment.Installments {
+			if installment.ID == *installmentID {
+				lines = append(lines, installment)
+			}
+		}
+		if len(lines) == 0 {
+			return doc, gorm.ErrRecordNotFound
+		}
+		amount = lines[0].Amount
+	}
+
+	if kind == KindReceipt && !isPaid(enrollment, lines, installmentID != nil) {
+		return doc, ErrNotPaid
+	}
+
+	var branding models.Branding
+	db.DB.FirstOrCreate(&branding, models.Branding{ID: 1})
+
+	now := time.Now()
+	doc = models.Document{
+		Kind:          kind,
+		Year:          now.Year(),
+		UserID:        enrollment.UserID,
+		EnrollmentID:  enrollment.ID,
+		InstallmentID: installmentID,
+		Amount:        amount,
+		Currency:      enrollment.Currency,
+		File:          path.Join(Dir, uuid.New().String()+".pdf"),
+		IssuedAt:      now,
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		sequence, err := nextSequence(tx, kind, doc.Year)
+		if err != nil {
+			return err
+		}
+
+		doc.Sequence = sequence
+		doc.Number = fmt.Sprintf("%s-%d-%06d", prefix, doc.Year, sequence)
+
+		if err := tx.Create(&doc).Error; err != nil {
+			return err
+		}
+
+		var pdf bytes.Buffer
+		if err := render(&pdf, doc, branding, enrollment, lines); err != nil {
+			return err
+		}
+
+		return storage.WriteFile(doc.File, pdf.Bytes())
+	})
+
+	if err != nil {
+		_ = storage.Files.Delete(doc.File)
+		return doc, err
+	}
+
+	return doc, nil
+}
+
+func isPaid(enrollment models.EnrolledCourse, lines []models.Installment, single bool) bool {
+	if !single && enrollment.Paid {
+		return true
+	}
+	if len(lines) == 0 {
+		return false
+	}
+	for _, line := range lines {
+		if !line.Paid {
+			return false
+		}
+	}
+	return true
+}
+
+// nextSequence locks the counter row for the kind and year and increments it.
+func nextSequence(tx *gorm.DB, kind string, year int) (uint, error) {
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.DocumentCounter{Kind: kind, Year: year}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var counter models.DocumentCounter
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("kind = ? AND year = ?", kind, year).
+		First(&counter).Error
+	if err != nil {
+		return 0, err
+	}
+
+	counter.Last++
+
+	err = tx.Model(&models.DocumentCounter{}).
+		Where("kind = ? AND year = ?", kind, year).
+		Update("last", counter.Last).Error
+
+	return counter.Last, err
+}
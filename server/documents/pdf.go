@@ -0,0 +1,184 @@
+package documents
+
+import (
+	"codev_erp/billing"
+	"codev_erp/db/models"
+	"codev_erp/storage"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+var titles = map[string]string{
+	KindInvoice: "INVOICE",
+	KindReceipt: "RECEIPT",
+}
+
+func render(w io.Writer, doc models.Document, branding models.Branding,
+	enrollment models.EnrolledCourse, lines []models.Installment) error {
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+
+	family := "Helvetica"
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// встроенные шрифты понимают только cp1252, поэтому для ə, ş, кириллицы подключаем TTF
+	if branding.FontFile != "" {
+		font, err := storage.ReadFile(branding.FontFile)
+		if err != nil {
+			return err
+		}
+		pdf.AddUTF8FontFromBytes("school", "", font)
+		pdf.AddUTF8FontFromBytes("school", "B", font)
+		family = "school"
+		tr = func(s string) string { return s }
+	}
+
+	pdf.AddPage()
+	r, g, b := hexColor(branding.AccentColor)
+
+	// Шапка: логотип и реквизиты школы
+	textX := 15.0
+	if isEmbeddableImage(branding.Logo) {
+		logo, err := storage.Files.Open(branding.Logo)
+		if err != nil {
+			return err
+		}
+		options := fpdf.ImageOptions{ReadDpi: true, ImageType: strings.TrimPrefix(filepath.Ext(branding.Logo), ".")}
+		pdf.RegisterImageOptionsReader(branding.Logo, options, logo)
+		logo.Close()
+
+		pdf.ImageOptions(branding.Logo, 15, 15, 0, 20, false, options, 0, "")
+		textX = 45
+	}
+
+	pdf.SetXY(textX, 15)
+	pdf.SetFont(family, "B", 16)
+	pdf.SetTextColor(r, g, b)
+	pdf.CellFormat(0, 8, tr(branding.SchoolName), "", 1, "L", false, 0, "")
+
+	pdf.SetFont(family, "", 9)
+	pdf.SetTextColor(80, 80, 80)
+	for _, line := range []string{branding.Address, joinNonEmpty(" · ", branding.Phone, branding.Email, branding.Website)} {
+		if line != "" {
+			pdf.SetX(textX)
+			pdf.CellFormat(0, 5, tr(line), "", 1, "L", false, 0, "")
+		}
+	}
+	if branding.TaxID != "" {
+		pdf.SetX(textX)
+		pdf.CellFormat(0, 5, tr("Tax ID: "+branding.TaxID), "", 1, "L", false, 0, "")
+	}
+
+	// Заголовок документа
+	pdf.SetY(45)
+	pdf.SetFont(family, "B", 20)
+	pdf.SetTextColor(r, g, b)
+	pdf.CellFormat(0, 10, titles[doc.Kind], "", 1, "R", false, 0, "")
+
+	pdf.SetFont(family, "", 10)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(0, 6, tr("No. "+doc.Number), "", 1, "R", false, 0, "")
+	pdf.CellFormat(0, 6, tr("Date: "+doc.IssuedAt.Format("02.01.2006")), "", 1, "R", false, 0, "")
+
+	// Плательщик
+	pdf.Ln(4)
+	pdf.SetFont(family, "B", 10)
+	pdf.CellFormat(0, 6, tr("Billed to"), "", 1, "L", false, 0, "")
+	pdf.SetFont(family, "", 10)
+	pdf.CellFormat(0, 5, tr(enrollment.User.FirstName+" "+enrollment.User.LastName), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, tr(enrollment.User.Email), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, tr("Course: "+enrollment.Course.Name), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, tr("Period: "+enrollment.StartDate.Format("02.01.2006")+" - "+
+		enrollment.EndDate.Format("02.01.2006")), "", 1, "L", false, 0, "")
+
+	// Таблица платежей
+	pdf.Ln(6)
+	pdf.SetFont(family, "B", 10)
+	pdf.SetFillColor(r, g, b)
+	pdf.SetTextColor(255, 255, 255)
+	pdf.CellFormat(15, 8, "#", "", 0, "C", true, 0, "")
+	pdf.CellFormat(75, 8, tr("Description"), "", 0, "L", true, 0, "")
+	pdf.CellFormat(35, 8, tr("Due date"), "", 0, "L", true, 0, "")
+	pdf.CellFormat(25, 8, tr("Status"), "", 0, "L", true, 0, "")
+	pdf.CellFormat(30, 8, tr("Amount"), "", 1, "R", true, 0, "")
+
+	pdf.SetFont(family, "", 10)
+	pdf.SetTextColor(0, 0, 0)
+	for _, line := range lines {
+		status := "Unpaid"
+		if line.Paid {
+			status = "Paid"
+		}
+
+		pdf.CellFormat(15, 7, strconv.Itoa(int(line.Number)), "B", 0, "C", false, 0, "")
+		pdf.CellFormat(75, 7, tr(enrollment.Course.Name+" - installment "+strconv.Itoa(int(line.Number))), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(35, 7, line.DueDate.Format("02.01.2006"), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(25, 7, status, "B", 0, "L", false, 0, "")
+		pdf.CellFormat(30, 7, line.Amount.String()+" "+line.Currency, "B", 1, "R", false, 0, "")
+	}
+
+	// Итоги
+	pdf.Ln(3)
+	pdf.SetFont(family, "", 10)
+	if doc.InstallmentID == nil && enrollment.ListPrice != enrollment.Price {
+		totalRow(pdf, tr("List price"), enrollment.ListPrice.String()+" "+doc.Currency)
+		totalRow(pdf, tr("Discount"), "-"+(enrollment.ListPrice-enrollment.Price).String()+" "+doc.Currency)
+	}
+
+	pdf.SetFont(family, "B", 11)
+	if doc.Kind == KindReceipt {
+		totalRow(pdf, tr("Amount received"), doc.Amount.String()+" "+doc.Currency)
+	} else {
+		totalRow(pdf, tr("Total"), doc.Amount.String()+" "+doc.Currency)
+		if doc.InstallmentID == nil {
+			totalRow(pdf, tr("Amount due"), billing.Outstanding(lines).String()+" "+doc.Currency)
+		}
+	}
+
+	if branding.Footer != "" {
+		pdf.SetY(-30)
+		pdf.SetFont(family, "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.MultiCell(0, 4, tr(branding.Footer), "T", "C", false)
+	}
+
+	return pdf.Output(w)
+}
+
+func totalRow(pdf *fpdf.Fpdf, label string, value string) {
+	pdf.CellFormat(150, 7, label, "", 0, "R", false, 0, "")
+	pdf.CellFormat(30, 7, value, "", 1, "R", false, 0, "")
+}
+
+// fpdf can only embed JPEG, PNG and GIF images
+func isEmbeddableImage(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+func hexColor(hex string) (int, int, int) {
+	hex = strings.TrimPrefix(hex, "#")
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return 31, 111, 235
+	}
+	return int(value >> 16 & 0xFF), int(value >> 8 & 0xFF), int(value & 0xFF)
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
@@ -1,6 +1,11 @@
 package dto
 
-import "time"
+import (
+	"codev_erp/attendance"
+	"codev_erp/db/models"
+	"codev_erp/money"
+	"time"
+)
 
 type AuthRequest struct {
 	Email    string `json:"email"`
@@ -18,9 +23,13 @@ type RegisterRequest struct {
 }
 
 type LessonRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	CourseID    uint   `json:"courseId"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	CourseID    uint       `json:"courseId"`
+	Duration    uint       `json:"duration"`
+	StartDate   *time.Time `json:"startDate"`
+	RoomID      *uint      `json:"roomID"`
+	ResourceIDs []uint     `json:"resourceIDs"`
 }
 
 ////////////////////////////////////////
@@ -37,12 +46,15 @@ type UserResponse struct {
 }
 
 type CourseResponse struct {
-	ID           uint         `json:"id"`
-	Name         string       `json:"name"`
-	Description  string       `json:"description"`
-	PreviewImage string       `json:"previewImage"`
-	Duration     string       `json:"duration"`
-	Teacher      UserResponse `json:"teacher"`
+	ID            uint         `json:"id"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	PreviewImage  string       `json:"previewImage"`
+	Duration      uint         `json:"duration"`
+	Price         money.Amount `json:"price"`
+	Currency      string       `json:"currency"`
+	BillingPeriod string       `json:"billingPeriod"`
+	Teacher       UserResponse `json:"teacher"`
 }
 
 type ParticipantResponse struct {
@@ -55,3 +67,93 @@ type ParticipantResponse struct {
 	EndDate   time.Time `json:"end_date"`
 	StartDate time.Time `json:"start_date"`
 }
+
+type OverdueEnrollmentResponse struct {
+	EnrollmentID  uint                 `json:"enrollmentID"`
+	StudentID     uint                 `json:"studentID"`
+	FirstName     string               `json:"firstName"`
+	LastName      string               `json:"lastName"`
+	Email         string               `json:"email"`
+	CourseID      uint                 `json:"courseID"`
+	CourseName    string               `json:"courseName"`
+	AmountOverdue money.Amount         `json:"amountOverdue"`
+	Currency      string               `json:"currency"`
+	DaysOverdue   int                  `json:"daysOverdue"`
+	ReminderStage string               `json:"reminderStage"`
+	Installments  []models.Installment `json:"installments"`
+}
+
+type RevenueRow struct {
+	Group    string       `json:"group"`
+	Currency string       `json:"currency"`
+	Amount   money.Amount `json:"amount"`
+	Payments int          `json:"payments"`
+}
+
+type ReceivableRow struct {
+	EnrollmentID uint         `json:"enrollmentID"`
+	StudentID    uint         `json:"studentID"`
+	FirstName    string       `json:"firstName"`
+	LastName     string       `json:"lastName"`
+	CourseName   string       `json:"courseName"`
+	Currency     string       `json:"currency"`
+	DueDate      time.Time    `json:"dueDate"`
+	Amount       money.Amount `json:"amount"`
+	DaysPastDue  int          `json:"daysPastDue"`
+	Bucket       string       `json:"bucket"`
+}
+
+type ReceivablesResponse struct {
+	Currency     string          `json:"currency"`
+	TotalPastDue money.Amount    `json:"totalPastDue"`
+	Days0To30    money.Amount    `json:"days0to30"`
+	Days31To60   money.Amount    `json:"days31to60"`
+	Days60Plus   money.Amount    `json:"days60plus"`
+	NotYetDue    money.Amount    `json:"notYetDue"`
+	Rows         []ReceivableRow `json:"rows"`
+}
+
+type AttendanceRosterRow struct {
+	UserID    uint       `json:"userID"`
+	FirstName string     `json:"firstName"`
+	LastName  string     `json:"lastName"`
+	Status    string     `json:"status"`
+	Method    string     `json:"method"`
+	Note      string     `json:"note"`
+	MarkedAt  *time.Time `json:"markedAt"`
+}
+
+type AttendanceStatsRow struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	attendance.Counts
+}
+
+type AttendanceStatsResponse struct {
+	Total attendance.Counts    `json:"total"`
+	Rows  []AttendanceStatsRow `json:"rows"`
+}
+
+type CheckInCodeResponse struct {
+	LessonID  uint       `json:"lessonID"`
+	Open      bool       `json:"open"`
+	Code      string     `json:"code,omitempty"`
+	QR        string     `json:"qr,omitempty"` // data URL с PNG
+	RotatesAt *time.Time `json:"rotatesAt,omitempty"`
+	OpensAt   time.Time  `json:"opensAt"`
+	ClosesAt  time.Time  `json:"closesAt"`
+}
+
+type BusySlot struct {
+	LessonID   uint      `json:"lessonID"`
+	LessonName string    `json:"lessonName"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+}
+
+type FreeBusyRow struct {
+	Kind string     `json:"kind"` // room, resource или teacher
+	ID   uint       `json:"id"`
+	Name string     `json:"name"`
+	Busy []BusySlot `json:"busy"`
+}
@@ -0,0 +1,357 @@
+package annotation_handlers
+
+import (
+	"codev_erp/attachment"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/endpoints"
+	"codev_erp/homework"
+	"codev_erp/logger"
+	"codev_erp/notify"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type annotationRequest struct {
+	File    string                  `json:"file" binding:"required"`
+	Version uint                    `json:"version"` // по умолчанию — текущая версия
+	Anchor  models.AnnotationAnchor `json:"anchor"`
+	Body    string                  `json:"body" binding:"required"`
+}
+
+type bodyRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// access tells who the current user is to a submission: its student or a teacher
+// of the lesson. Everyone else is answered with 403 and ok=false.
+type access struct {
+	submission models.UsersHomework
+	teacher    bool
+}
+
+func loadAccess(ctx *gin.Context, homeworkID interface{}) (access, bool) {
+	user := endpoints.SessionUser(ctx)
+
+	var a access
+	if err := db.DB.Preload("Lesson").Where("id = ?", homeworkID).First(&a.submission).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Homework not found"})
+		return a, false
+	}
+
+	a.teacher = endpoints.CanManageLesson(user, a.submission.Lesson)
+	if !a.teacher && a.submission.UserID != user.ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return a, false
+	}
+
+	return a, true
+}
+
+func loadAnnotation(ctx *gin.Context) (models.Annotation, access, bool) {
+	var annotation models.Annotation
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&annotation).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Annotation not found"})
+		return annotation, access{}, false
+	}
+
+	a, ok := loadAccess(ctx, annotation.HomeworkID)
+	return annotation, a, ok
+}
+
+// notifyOtherParty tells the student about teacher feedback, and the teacher who
+// opened the thread about the student's answer.
+func notifyOtherParty(ctx *gin.Context, a access, annotation models.Annotation, title string, body string) {
+	recipient := a.submission.UserID
+	if !a.teacher {
+		recipient = annotation.AuthorID
+	}
+	if recipient == endpoints.SessionUser(ctx).ID {
+		return
+	}
+
+	name := annotation.File
+	if file, err := attachment.ByKey(db.DB, annotation.File); err == nil && file != nil {
+		name = file.OriginalName
+	}
+
+	notify.Send(recipient, notify.Message{
+		Kind:  "homework_annotation",
+		Title: title,
+		Body:  fmt.Sprintf("%s (%s, %s): %s", a.submission.Lesson.Name, name, location(annotation.Anchor), body),
+	})
+}
+
+func location(anchor models.AnnotationAnchor) string {
+	switch {
+	case anchor.Kind == homework.AnchorLines && anchor.StartLine == anchor.EndLine:
+		return fmt.Sprintf("line %d", anchor.StartLine)
+	case anchor.Kind == homework.AnchorLines:
+		return fmt.Sprintf("lines %d-%d", anchor.StartLine, anchor.EndLine)
+	case anchor.Page > 0:
+		return fmt.Sprintf("page %d", anchor.Page)
+	}
+	return "image"
+}
+
+// GetAnnotationsHandler lists the threads of a submission, optionally narrowed by
+// ?version= and ?file=.
+func GetAnnotationsHandler(ctx *gin.Context) {
+	a, ok := loadAccess(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	query := db.DB.Where("homework_id = ?", a.submission.ID)
+	if version := ctx.Query("version"); version != "" {
+		query = query.Where("version = ?", version)
+	}
+	if file := ctx.Query("file"); file != "" {
+		query = query.Where("file = ?", file)
+	}
+
+	var annotations []models.Annotation
+	err := query.
+		Preload("Author").
+		Preload("Replies", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Preload("Replies.Author").
+		Order("created_at").
+		Find(&annotations).Error
+	if err != nil {
+		logger.Log("Failed to get annotations: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get annotations"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, annotations)
+}
+
+// AddAnnotationHandler opens a thread. Only teachers start threads; students answer.
+func AddAnnotationHandler(ctx *gin.Context) {
+	var req annotationRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := homework.ValidateAnchor(req.Anchor); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	a, ok := loadAccess(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !a.teacher {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Only teachers can start a thread"})
+		return
+	}
+
+	if req.Version == 0 {
+		req.Version = a.submission.Version
+	}
+
+	var version models.HomeworkVersion
+	if err := db.DB.Preload("Files").Where("homework_id = ? AND number = ?", a.submission.ID, req.Version).First(&version).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	if !slices.ContainsFunc(version.Files, func(file models.Attachment) bool { return file.Key == req.File }) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The file is not part of this version"})
+		return
+	}
+
+	annotation := models.Annotation{
+		HomeworkID: a.submission.ID,
+		Version:    req.Version,
+		File:       req.File,
+		Anchor:     req.Anchor,
+		AuthorID:   endpoints.SessionUser(ctx).ID,
+		Body:       req.Body,
+	}
+
+	if err := db.DB.Omit("Author").Create(&annotation).Error; err != nil {
+		logger.Log("Failed to create annotation: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create annotation"})
+		return
+	}
+
+	notifyOtherParty(ctx, a, annotation, "New feedback on your homework", req.Body)
+
+	ctx.JSON(http.StatusCreated, annotation)
+}
+
+// UpdateAnnotationHandler lets the author correct the opening comment.
+func UpdateAnnotationHandler(ctx *gin.Context) {
+	var req bodyRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	annotation, _, ok := loadAnnotation(ctx)
+	if !ok {
+		return
+	}
+
+	if annotation.AuthorID != endpoints.SessionUser(ctx).ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit the comment"})
+		return
+	}
+
+	if err := db.DB.Model(&annotation).Update("body", req.Body).Error; err != nil {
+		logger.Log("Failed to update annotation: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update annotation"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, annotation)
+}
+
+// DeleteAnnotationHandler removes a whole thread; teachers of the lesson may delete it.
+func DeleteAnnotationHandler(ctx *gin.Context) {
+	annotation, a, ok := loadAnnotation(ctx)
+	if !ok {
+		return
+	}
+
+	if !a.teacher {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := db.DB.Delete(&annotation).Error; err != nil {
+		logger.Log("Failed to delete annotation: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete annotation"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Annotation deleted successfully"})
+}
+
+func AddReplyHandler(ctx *gin.Context) {
+	var req bodyRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	annotation, a, ok := loadAnnotation(ctx)
+	if !ok {
+		return
+	}
+
+	if annotation.Resolved {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The thread is resolved; reopen it to reply"})
+		return
+	}
+
+	reply := models.AnnotationReply{AnnotationID: annotation.ID, AuthorID: endpoints.SessionUser(ctx).ID, Body: req.Body}
+
+	if err := db.DB.Omit("Author").Create(&reply).Error; err != nil {
+		logger.Log("Failed to create reply: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reply"})
+		return
+	}
+
+	notifyOtherParty(ctx, a, annotation, "New reply to homework feedback", req.Body)
+
+	ctx.JSON(http.StatusCreated, reply)
+}
+
+func UpdateReplyHandler(ctx *gin.Context) {
+	var req bodyRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var reply models.AnnotationReply
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&reply).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Reply not found"})
+		return
+	}
+
+	if reply.AuthorID != endpoints.SessionUser(ctx).ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit the reply"})
+		return
+	}
+
+	if err := db.DB.Model(&reply).Update("body", req.Body).Error; err != nil {
+		logger.Log("Failed to update reply: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reply"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, reply)
+}
+
+func DeleteReplyHandler(ctx *gin.Context) {
+	var reply models.AnnotationReply
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&reply).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Reply not found"})
+		return
+	}
+
+	if reply.AuthorID != endpoints.SessionUser(ctx).ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Only the author can delete the reply"})
+		return
+	}
+
+	if err := db.DB.Delete(&reply).Error; err != nil {
+		logger.Log("Failed to delete reply: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reply"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Reply deleted successfully"})
+}
+
+// ResolveAnnotationHandler closes a thread; either side may do it.
+func ResolveAnnotationHandler(ctx *gin.Context) {
+	setResolved(ctx, true)
+}
+
+func ReopenAnnotationHandler(ctx *gin.Context) {
+	setResolved(ctx, false)
+}
+
+func setResolved(ctx *gin.Context, resolved bool) {
+	annotation, a, ok := loadAnnotation(ctx)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{"resolved": resolved, "resolved_by": nil, "resolved_at": nil}
+	if resolved {
+		updates["resolved_by"] = endpoints.SessionUser(ctx).ID
+		updates["resolved_at"] = time.Now()
+	}
+
+	if err := db.DB.Model(&annotation).Updates(updates).Error; err != nil {
+		logger.Log("Failed to update annotation: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update annotation"})
+		return
+	}
+
+	if resolved {
+		notifyOtherParty(ctx, a, annotation, "Homework feedback thread resolved", annotation.Body)
+	} else {
+		notifyOtherParty(ctx, a, annotation, "Homework feedback thread reopened", annotation.Body)
+	}
+
+	ctx.JSON(http.StatusOK, annotation)
+}
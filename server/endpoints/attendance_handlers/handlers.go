@@ -0,0 +1,351 @@
+package attendance_handlers
+
+import (
+	"codev_erp/attendance"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/endpoints"
+	"codev_erp/logger"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+type statusCount struct {
+	GroupID uint
+	Status  string
+	Count   int64
+}
+
+// GetLessonAttendanceHandler returns the lesson's roster: every enrolled student with
+// their mark, or an empty status when not marked yet.
+func GetLessonAttendanceHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	studentIDs, err := attendance.EnrolledStudents(db.DB, lesson)
+	if err != nil {
+		logger.Log("Failed to get lesson students: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attendance"})
+		return
+	}
+
+	var students []models.User
+	var records []models.Attendance
+
+	if err := db.DB.Where("id IN ?", studentIDs).Order("last_name, first_name").Find(&students).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attendance"})
+		return
+	}
+	if err := db.DB.Where("lesson_id = ?", lesson.ID).Find(&records).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attendance"})
+		return
+	}
+
+	marks := map[uint]models.Attendance{}
+	for _, record := range records {
+		marks[record.UserID] = record
+	}
+
+	roster := make([]dto.AttendanceRosterRow, 0, len(students))
+	for _, student := range students {
+		row := dto.AttendanceRosterRow{
+			UserID:    student.ID,
+			FirstName: student.FirstName,
+			LastName:  student.LastName,
+		}
+		if mark, ok := marks[student.ID]; ok {
+			row.Status = mark.Status
+			row.Method = mark.Method
+			row.Note = mark.Note
+			row.MarkedAt = &mark.MarkedAt
+		}
+		roster = append(roster, row)
+	}
+
+	ctx.JSON(http.StatusOK, roster)
+}
+
+// MarkAttendanceHandler stores the marks of several students at once.
+func MarkAttendanceHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Records []attendance.Entry `json:"records" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user := endpoints.SessionUser(ctx)
+
+	err := attendance.Mark(lesson, req.Records, user.ID, attendance.MethodManual, time.Now())
+	switch {
+	case errors.Is(err, attendance.ErrLessonCancelled), errors.Is(err, attendance.ErrNotEnrolled), errors.Is(err, attendance.ErrInvalidStatus):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logger.Log("Failed to mark attendance: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark attendance"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Attendance saved successfully"})
+}
+
+// GetStudentStatsHandler returns a student's attendance rate per course. Students see
+// their own, teachers the courses they teach, admins everything.
+func GetStudentStatsHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	studentID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid student ID"})
+		return
+	}
+
+	query := statsQuery().Select("lessons.course_id AS group_id, attendances.status, COUNT(*) AS count").
+		Where("attendances.user_id = ?", studentID).
+		Group("lessons.course_id, attendances.status")
+
+	switch user.Role {
+	case "admin":
+	case "teacher":
+		query = query.Joins("JOIN courses ON courses.id = lessons.course_id").Where("courses.teacher_id = ?", user.ID)
+	case "student":
+		if uint(studentID) != user.ID {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+	default:
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if courseID := ctx.Query("courseId"); courseID != "" {
+		query = query.Where("lessons.course_id = ?", courseID)
+	}
+
+	var counts []statusCount
+	if err := query.Scan(&counts).Error; err != nil {
+		logger.Log("Failed to get attendance stats: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attendance stats"})
+		return
+	}
+
+	groups, total := fold(counts)
+
+	var courses []models.Course
+	db.DB.Where("id IN ?", keys(groups)).Find(&courses)
+
+	rows := make([]dto.AttendanceStatsRow, 0, len(courses))
+	for _, course := range courses {
+		rows = append(rows, dto.AttendanceStatsRow{ID: course.ID, Name: course.Name, Counts: attendance.Summarize(groups[course.ID])})
+	}
+
+	ctx.JSON(http.StatusOK, dto.AttendanceStatsResponse{Total: attendance.Summarize(total), Rows: rows})
+}
+
+// GetCourseStatsHandler returns the attendance rate of every student of a course.
+func GetCourseStatsHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	courseID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(user, uint(courseID)) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return
+	}
+
+	var counts []statusCount
+
+	err = statsQuery().Select("attendances.user_id AS group_id, attendances.status, COUNT(*) AS count").
+		Where("lessons.course_id = ?", courseID).
+		Group("attendances.user_id, attendances.status").
+		Scan(&counts).Error
+	if err != nil {
+		logger.Log("Failed to get attendance stats: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attendance stats"})
+		return
+	}
+
+	groups, total := fold(counts)
+
+	var students []models.User
+	db.DB.Where("id IN ?", keys(groups)).Order("last_name, first_name").Find(&students)
+
+	rows := make([]dto.AttendanceStatsRow, 0, len(students))
+	for _, student := range students {
+		rows = append(rows, dto.AttendanceStatsRow{
+			ID:     student.ID,
+			Name:   student.FirstName + " " + student.LastName,
+			Counts: attendance.Summarize(groups[student.ID]),
+		})
+	}
+
+	ctx.JSON(http.StatusOK, dto.AttendanceStatsResponse{Total: attendance.Summarize(total), Rows: rows})
+}
+
+// OpenCheckInHandler starts self check-in for the lesson and returns the first code.
+func OpenCheckInHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+
+	window, err := attendance.OpenCheckIn(lesson, endpoints.SessionUser(ctx).ID, now)
+	switch {
+	case errors.Is(err, attendance.ErrLessonCancelled), errors.Is(err, attendance.ErrOutsideWindow):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logger.Log("Failed to open check-in: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open check-in"})
+		return
+	}
+
+	respondWithCode(ctx, window, now)
+}
+
+// GetCheckInCodeHandler returns the current code and its QR image. The teacher's
+// screen polls it to follow the rotation.
+func GetCheckInCodeHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	var window models.CheckInWindow
+	if err := db.DB.Where("lesson_id = ?", lesson.ID).First(&window).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": attendance.ErrCheckInClosed.Error()})
+		return
+	}
+
+	respondWithCode(ctx, window, time.Now())
+}
+
+func CloseCheckInHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	if err := attendance.CloseCheckIn(lesson.ID, time.Now()); err != nil {
+		logger.Log("Failed to close check-in: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close check-in"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Check-in closed"})
+}
+
+// CheckInHandler lets a logged-in student mark themselves with the scanned or typed code.
+func CheckInHandler(ctx *gin.Context) {
+	var req struct {
+		LessonID uint   `json:"lessonID" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	record, err := attendance.CheckIn(req.LessonID, endpoints.SessionUser(ctx).ID, req.Code, time.Now())
+	switch {
+	case errors.Is(err, attendance.ErrAlreadyChecked):
+		ctx.JSON(http.StatusOK, record)
+		return
+	case errors.Is(err, attendance.ErrNotEnrolled):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, attendance.ErrTooManyAttempts), errors.Is(err, attendance.ErrLockedOut):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, attendance.ErrCheckInClosed), errors.Is(err, attendance.ErrInvalidCode), errors.Is(err, attendance.ErrLessonCancelled):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logger.Log("Failed to check in: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+func respondWithCode(ctx *gin.Context, window models.CheckInWindow, now time.Time) {
+	if !attendance.IsOpen(window, now) {
+		ctx.JSON(http.StatusOK, dto.CheckInCodeResponse{LessonID: window.LessonID, Open: false, OpensAt: window.OpensAt, ClosesAt: window.ClosesAt})
+		return
+	}
+
+	code, rotatesAt := attendance.Code(window, now)
+
+	png, err := qrcode.Encode(attendance.QRPayload(window, code), qrcode.Medium, 320)
+	if err != nil {
+		logger.Log("Failed to render check-in QR code: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.CheckInCodeResponse{
+		LessonID:  window.LessonID,
+		Open:      true,
+		Code:      code,
+		QR:        "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		RotatesAt: &rotatesAt,
+		OpensAt:   window.OpensAt,
+		ClosesAt:  window.ClosesAt,
+	})
+}
+
+func statsQuery() *gorm.DB {
+	return db.DB.Model(&models.Attendance{}).
+		Joins("JOIN lessons ON lessons.id = attendances.lesson_id").
+		Where("lessons.status <> ?", "cancelled")
+}
+
+func fold(counts []statusCount) (map[uint]map[string]int64, map[string]int64) {
+	groups := map[uint]map[string]int64{}
+	total := map[string]int64{}
+
+	for _, c := range counts {
+		if groups[c.GroupID] == nil {
+			groups[c.GroupID] = map[string]int64{}
+		}
+		groups[c.GroupID][c.Status] += c.Count
+		total[c.Status] += c.Count
+	}
+
+	return groups, total
+}
+
+func keys(groups map[uint]map[string]int64) []uint {
+	ids := make([]uint, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	return ids
+}
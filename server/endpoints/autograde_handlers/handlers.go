@@ -0,0 +1,210 @@
+package autograde_handlers
+
+import (
+	"codev_erp/autograde"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/endpoints"
+	"codev_erp/logger"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxTimeLimitMs   = 30000
+	maxMemoryLimitMB = 1024
+)
+
+type testCaseRequest struct {
+	Name           string `json:"name" binding:"required"`
+	Stdin          string `json:"stdin"`
+	ExpectedOutput string `json:"expectedOutput"`
+	Points         uint   `json:"points"`
+	Hidden         bool   `json:"hidden"`
+}
+
+type testSuiteRequest struct {
+	Language      string            `json:"language" binding:"required"`
+	Entrypoint    string            `json:"entrypoint"`
+	TimeLimitMs   uint              `json:"timeLimitMs"`
+	MemoryLimitMB uint              `json:"memoryLimitMB"`
+	Cases         []testCaseRequest `json:"cases" binding:"required,min=1,dive"`
+}
+
+func GetTestSuiteHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	var suite models.TestSuite
+	err := db.DB.
+		Preload("Cases", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Where("lesson_id = ?", lesson.ID).
+		First(&suite).Error
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "The lesson has no test suite"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, suite)
+}
+
+// SaveTestSuiteHandler creates the lesson's suite or replaces it with its cases.
+// Earlier runs keep their results; new submissions are tested with the new cases.
+func SaveTestSuiteHandler(ctx *gin.Context) {
+	var req testSuiteRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if !autograde.ValidLanguage(req.Language) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Language must be python or javascript"})
+		return
+	}
+
+	if req.TimeLimitMs == 0 {
+		req.TimeLimitMs = 5000
+	}
+	if req.MemoryLimitMB == 0 {
+		req.MemoryLimitMB = 256
+	}
+	if req.TimeLimitMs > maxTimeLimitMs || req.MemoryLimitMB > maxMemoryLimitMB || req.MemoryLimitMB < 32 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Limits must be at most 30000 ms and between 32 and 1024 MB"})
+		return
+	}
+
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	suite := models.TestSuite{LessonID: lesson.ID}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("lesson_id = ?", lesson.ID).FirstOrInit(&suite).Error; err != nil {
+			return err
+		}
+
+		suite.Language = req.Language
+		suite.Entrypoint = req.Entrypoint
+		suite.TimeLimitMs = req.TimeLimitMs
+		suite.MemoryLimitMB = req.MemoryLimitMB
+
+		if err := tx.Save(&suite).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("suite_id = ?", suite.ID).Delete(&models.TestCase{}).Error; err != nil {
+			return err
+		}
+
+		suite.Cases = nil
+		for i, c := range req.Cases {
+			suite.Cases = append(suite.Cases, models.TestCase{
+				SuiteID:        suite.ID,
+				Name:           c.Name,
+				Stdin:          c.Stdin,
+				ExpectedOutput: c.ExpectedOutput,
+				Points:         c.Points,
+				Hidden:         c.Hidden,
+				Position:       uint(i),
+			})
+		}
+		return tx.Create(&suite.Cases).Error
+	})
+
+	if err != nil {
+		logger.Log("Failed to save test suite: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save test suite"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, suite)
+}
+
+func DeleteTestSuiteHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	if err := db.DB.Where("lesson_id = ?", lesson.ID).Delete(&models.TestSuite{}).Error; err != nil {
+		logger.Log("Failed to delete test suite: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete test suite"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Test suite deleted successfully"})
+}
+
+// GetTestRunsHandler lists the runs of a submission, newest first. Students see
+// only pass/fail for hidden tests, not what their program printed.
+func GetTestRunsHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var submission models.UsersHomework
+	if err := db.DB.Preload("Lesson").Where("id = ?", ctx.Param("id")).First(&submission).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Homework not found"})
+		return
+	}
+
+	teacher := endpoints.CanManageLesson(user, submission.Lesson)
+	if !teacher && submission.UserID != user.ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var runs []models.TestRun
+	if err := db.DB.Where("homework_id = ?", submission.ID).Order("id DESC").Find(&runs).Error; err != nil {
+		logger.Log("Failed to get test runs: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get test runs"})
+		return
+	}
+
+	if !teacher {
+		for i := range runs {
+			for j := range runs[i].Results {
+				if runs[i].Results[j].Hidden {
+					runs[i].Results[j].Output = ""
+					runs[i].Results[j].Stderr = ""
+				}
+			}
+		}
+	}
+
+	ctx.JSON(http.StatusOK, runs)
+}
+
+// RerunTestsHandler queues another run of the current version, e.g. after the
+// teacher fixed a test case.
+func RerunTestsHandler(ctx *gin.Context) {
+	var submission models.UsersHomework
+	if err := db.DB.Preload("Lesson").Where("id = ?", ctx.Param("id")).First(&submission).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Homework not found"})
+		return
+	}
+
+	if !endpoints.CanManageLesson(endpoints.SessionUser(ctx), submission.Lesson) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	run, err := autograde.Enqueue(db.DB, submission.ID, submission.LessonID, submission.Version)
+	if err != nil {
+		logger.Log("Failed to queue test run: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue test run"})
+		return
+	}
+
+	if run == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The lesson has no test suite"})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, run)
+}
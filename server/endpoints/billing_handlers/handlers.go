@@ -0,0 +1,74 @@
+package billing_handlers
+
+import (
+	"codev_erp/billing"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/logger"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Administrator-specific handlers
+
+func GetOverdueReportHandler(ctx *gin.Context) {
+	var installments []models.Installment
+
+	err := db.DB.
+		Preload("Enrollment.User").
+		Preload("Enrollment.Course").
+		Where("overdue = ? AND paid = ?", true, false).
+		Order("due_date").
+		Find(&installments).Error
+
+	if err != nil {
+		logger.Log("Failed to get overdue installments: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get overdue report"})
+		return
+	}
+
+	now := time.Now()
+	byEnrollment := map[uint]*dto.OverdueEnrollmentResponse{}
+	var report []*dto.OverdueEnrollmentResponse
+
+	for _, installment := range installments {
+		row, ok := byEnrollment[installment.EnrollmentID]
+
+		if !ok {
+			enrollment := installment.Enrollment
+			row = &dto.OverdueEnrollmentResponse{
+				EnrollmentID: enrollment.ID,
+				StudentID:    enrollment.UserID,
+				FirstName:    enrollment.User.FirstName,
+				LastName:     enrollment.User.LastName,
+				Email:        enrollment.User.Email,
+				CourseID:     enrollment.CourseID,
+				CourseName:   enrollment.Course.Name,
+				Currency:     installment.Currency,
+			}
+			byEnrollment[installment.EnrollmentID] = row
+			report = append(report, row)
+		}
+
+		row.AmountOverdue += installment.Amount
+		row.Installments = append(row.Installments, installment)
+
+		if days := billing.DaysOverdue(installment, now); days > row.DaysOverdue {
+			row.DaysOverdue = days
+		}
+		if billing.Escalates(installment.ReminderStage, row.ReminderStage) {
+			row.ReminderStage = installment.ReminderStage
+		}
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].DaysOverdue > report[j].DaysOverdue
+	})
+
+	ctx.JSON(http.StatusOK, report)
+}
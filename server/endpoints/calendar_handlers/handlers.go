@@ -0,0 +1,83 @@
+package calendar_handlers
+
+import (
+	"codev_erp/calendar"
+	"codev_erp/config"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/endpoints"
+	"codev_erp/logger"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFeedHandler serves the iCalendar feed. It is authorized by the token in the URL
+// alone, since calendar clients subscribe without a session cookie.
+func GetFeedHandler(ctx *gin.Context) {
+	token := strings.TrimSuffix(ctx.Param("token"), ".ics")
+
+	var user models.User
+	if token == "" || db.DB.Where("calendar_token = ?", token).First(&user).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	events, err := calendar.Feed(user, time.Now())
+	if err != nil {
+		logger.Log("Failed to build calendar feed: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", calendar.Render("Codev: "+user.FirstName+" "+user.LastName, events))
+}
+
+// GetTokenHandler returns the subscription URL, or 404 if the feed is disabled.
+func GetTokenHandler(ctx *gin.Context) {
+	var user models.User
+	if err := db.DB.Where("id = ?", endpoints.SessionUser(ctx).ID).First(&user).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.CalendarToken == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed is disabled"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"url": feedURL(*user.CalendarToken)})
+}
+
+// RegenerateTokenHandler issues a new token; the old subscription URL stops working.
+func RegenerateTokenHandler(ctx *gin.Context) {
+	token, err := calendar.NewToken()
+	if err == nil {
+		err = db.DB.Model(&models.User{}).Where("id = ?", endpoints.SessionUser(ctx).ID).Update("calendar_token", token).Error
+	}
+	if err != nil {
+		logger.Log("Failed to generate calendar token: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate calendar link"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"url": feedURL(token)})
+}
+
+func RevokeTokenHandler(ctx *gin.Context) {
+	if err := db.DB.Model(&models.User{}).Where("id = ?", endpoints.SessionUser(ctx).ID).Update("calendar_token", nil).Error; err != nil {
+		logger.Log("Failed to revoke calendar token: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke calendar link"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Calendar link revoked"})
+}
+
+func feedURL(token string) string {
+	return config.PublicURL() + "/calendar/" + token + ".ics"
+}
@@ -1,14 +1,20 @@
 package course_handlers
 
 import (
+	"codev_erp/billing"
 	"codev_erp/db"
 	"codev_erp/db/models"
 	"codev_erp/dto"
 	"codev_erp/endpoints"
+	"codev_erp/homework"
 	"codev_erp/logger"
+	"codev_erp/money"
+	"codev_erp/storage"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -113,7 +119,6 @@ func AddCourseHandler(ctx *gin.Context) {
 		return
 	}
 
-	filename := endpoints.ProcessImageFile(form.File["preview_image"][0], ctx, false)
 	teacherIdInt, err := strconv.Atoi(form.Value["teacher_id"][0])
 
 	if err != nil {
@@ -121,15 +126,57 @@ func AddCourseHandler(ctx *gin.Context) {
 		return
 	}
 
+	duration, err := strconv.ParseUint(form.Value["duration"][0], 10, 64)
+
+	if err != nil || duration == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course duration"})
+		return
+	}
+
+	price, err := money.Parse(form.Value["price"][0])
+
+	if err != nil || price < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course price"})
+		return
+	}
+
+	currency := money.DefaultCurrency
+	if form.Value["currency"] != nil {
+		currency = strings.ToUpper(form.Value["currency"][0])
+	}
+
+	if !money.ValidCurrency(currency) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency"})
+		return
+	}
+
+	billingPeriod := billing.PeriodMonthly
+	if form.Value["billing_period"] != nil {
+		billingPeriod = form.Value["billing_period"][0]
+	}
+
+	if !billing.ValidPeriod(billingPeriod) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid billing period"})
+		return
+	}
+
+	filename := endpoints.ProcessImageFile(form.File["preview_image"][0], ctx, storage.PreviewsDir)
+
+	if filename == "" {
+		return
+	}
+
 	teacherId := uint(teacherIdInt)
 
 	course := models.Course{
-		Name:         form.Value["name"][0],
-		Description:  form.Value["description"][0],
-		PreviewImage: filename,
-		Duration:     form.Value["duration"][0],
-		TeacherID:    &teacherId,
-		Price:        form.Value["price"][0],
+		Name:          form.Value["name"][0],
+		Description:   form.Value["description"][0],
+		PreviewImage:  filename,
+		Duration:      uint(duration),
+		TeacherID:     &teacherId,
+		Price:         price,
+		Currency:      currency,
+		BillingPeriod: billingPeriod,
 	}
 
 	if err := db.DB.Create(&course).Error; err != nil {
@@ -139,17 +186,61 @@ func AddCourseHandler(ctx *gin.Context) {
 	}
 
 	courseResponse := dto.CourseResponse{
-		ID:           course.ID,
-		Name:         course.Name,
-		Description:  course.Description,
-		PreviewImage: course.PreviewImage,
-		Duration:     course.Duration,
-		Teacher:      dto.UserResponse{},
+		ID:            course.ID,
+		Name:          course.Name,
+		Description:   course.Description,
+		PreviewImage:  course.PreviewImage,
+		Duration:      course.Duration,
+		Price:         course.Price,
+		Currency:      course.Currency,
+		BillingPeriod: course.BillingPeriod,
+		Teacher:       dto.UserResponse{},
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"success": "Course created successfully", "course": courseResponse})
 }
 
+// UpdateLatePolicyHandler sets how the course treats homework submitted after the deadline.
+func UpdateLatePolicyHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	courseID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(user, uint(courseID)) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return
+	}
+
+	var req struct {
+		LatePolicy         string `json:"latePolicy"`
+		LatePenaltyPercent uint   `json:"latePenaltyPercent"`
+		LateCutoffDays     uint   `json:"lateCutoffDays"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || !homework.ValidPolicy(req.LatePolicy) || req.LatePenaltyPercent > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "latePolicy must be accept, penalize or reject, latePenaltyPercent at most 100"})
+		return
+	}
+
+	err = db.DB.Model(&models.Course{}).Where("id = ?", courseID).Updates(map[string]interface{}{
+		"late_policy":          req.LatePolicy,
+		"late_penalty_percent": req.LatePenaltyPercent,
+		"late_cutoff_days":     req.LateCutoffDays,
+	}).Error
+
+	if err != nil {
+		logger.Log("Failed to update late policy: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update late policy"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Late policy updated successfully"})
+}
+
 func DeleteCourseHandler(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
@@ -195,7 +286,11 @@ func AddParticipantHandler(ctx *gin.Context) {
 
 	var body struct {
 		StudentID      uint   `json:"student_id"`
-		CourseDuration string `json:"course_duration"`
+		CourseDuration uint   `json:"course_duration"`
+		Installments   uint   `json:"installments"`
+		PromoCode      string `json:"promo_code"`
+		DiscountID     uint   `json:"discount_id"`
+		SalesID        uint   `json:"sales_id"`
 	}
 
 	if err := ctx.ShouldBindJSON(&body); err != nil || body.StudentID == 0 {
@@ -210,22 +305,101 @@ func AddParticipantHandler(ctx *gin.Context) {
 		return
 	}
 
-	duration, err := strconv.ParseUint(body.CourseDuration, 10, 64)
+	var course models.Course
+	if err := db.DB.Where("id = ?", courseID).First(&course).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return
+	}
 
-	if err != nil {
+	duration := body.CourseDuration
+	if duration == 0 {
+		duration = course.Duration
+	}
+
+	if duration == 0 {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course duration"})
 		return
 	}
 
+	if body.Installments > duration {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Installments cannot exceed course duration"})
+		return
+	}
+
+	var sales *models.Sales
+	if body.SalesID != 0 {
+		sales = &models.Sales{}
+		err := db.DB.Where("id = ? AND group_id = ? AND (student_id IS NULL OR student_id = ?)", body.SalesID, courseID, body.StudentID).
+			First(sales).Error
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Sales record not found for this student and course"})
+			return
+		}
+	}
+
+	discount, err := resolveEnrollmentDiscount(body.PromoCode, body.DiscountID, sales)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listPrice := billing.TotalPrice(course, duration)
+
 	enrollment := models.EnrolledCourse{
 		UserID:    body.StudentID,
 		CourseID:  uint(courseID),
 		StartDate: time.Now(),
 		EndDate:   time.Now().AddDate(0, int(duration), 0),
 		Paid:      false,
+		ListPrice: listPrice,
+		Price:     listPrice,
+		Currency:  course.Currency,
 	}
 
-	if err := db.DB.Create(&enrollment).Error; err != nil {
+	if discount != nil {
+		if err := billing.CheckDiscount(*discount, course, time.Now()); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		enrollment.DiscountID = &discount.ID
+		enrollment.Price = billing.ApplyDiscount(listPrice, *discount)
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if sales != nil {
+			// запись лида закрепляется за первым зачисленным студентом
+			result := tx.Model(&models.Sales{}).
+				Where("id = ? AND (student_id IS NULL OR student_id = ?)", sales.ID, body.StudentID).
+				Update("student_id", body.StudentID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errSalesTaken
+			}
+		}
+
+		if discount != nil {
+			if err := billing.ClaimDiscount(tx, *discount); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&enrollment).Error; err != nil {
+			return err
+		}
+
+		schedule := billing.BuildSchedule(enrollment, course.BillingPeriod, duration, body.Installments)
+		return tx.Create(&schedule).Error
+	})
+
+	if errors.Is(err, billing.ErrDiscountExhausted) || errors.Is(err, errSalesTaken) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
 		logger.Log("Failed to enroll student! "+err.Error(), slog.LevelError)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll student"})
 		return
@@ -234,6 +408,63 @@ func AddParticipantHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"success": "Student added successfully"})
 }
 
+var errSalesTaken = errors.New("sales record is already used by another student")
+
+// resolveEnrollmentDiscount picks the discount for a new enrollment: an explicit promo code,
+// an admin-assigned discount (scholarship, sibling) or the one promised to the lead in sales.
+// The sales record must already be checked against the enrolled student and course.
+func resolveEnrollmentDiscount(promoCode string, discountID uint, sales *models.Sales) (*models.Discount, error) {
+	if promoCode != "" {
+		discount, err := billing.FindDiscountByCode(db.DB, promoCode)
+		if err != nil {
+			return nil, err
+		}
+		return &discount, nil
+	}
+
+	if discountID == 0 && sales != nil && sales.DiscountID != nil {
+		discountID = *sales.DiscountID
+	}
+
+	if discountID == 0 {
+		return nil, nil
+	}
+
+	var discount models.Discount
+	if err := db.DB.Where("id = ?", discountID).First(&discount).Error; err != nil {
+		return nil, billing.ErrDiscountNotFound
+	}
+
+	return &discount, nil
+}
+
+func GetInstallmentsHandler(ctx *gin.Context) {
+	courseID := ctx.Param("id")
+	studentID := ctx.Param("studentId")
+
+	var enrollment models.EnrolledCourse
+
+	err := db.DB.
+		Preload("Installments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("number")
+		}).
+		Where("course_id = ? AND user_id = ?", courseID, studentID).
+		First(&enrollment).Error
+
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"listPrice":    enrollment.ListPrice,
+		"price":        enrollment.Price,
+		"currency":     enrollment.Currency,
+		"discountID":   enrollment.DiscountID,
+		"installments": enrollment.Installments,
+	})
+}
+
 func RemoveParticipantHandler(ctx *gin.Context) {
 
 	courseID := ctx.Param("id")
@@ -259,11 +490,15 @@ func GetStudentCoursesHandler(ctx *gin.Context) {
 	userIdInt, err := strconv.Atoi(userId)
 
 	err = db.DB.
-		Select("enrolled_courses.paid, enrolled_courses.paid_date, enrolled_courses.course_id, enrolled_courses.id").
+		Select("enrolled_courses.paid, enrolled_courses.paid_date, enrolled_courses.course_id, enrolled_courses.id, "+
+			"enrolled_courses.list_price, enrolled_courses.price, enrolled_courses.currency, enrolled_courses.discount_id").
 		Where("enrolled_courses.user_id = ?", userIdInt).
 		Preload("Course", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name")
 		}).
+		Preload("Installments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("number")
+		}).
 		Find(&enrolledCourses).Error
 
 	if err != nil {
@@ -312,6 +547,42 @@ func UpdateStudentPayments(ctx *gin.Context) {
 		db.DB.Where("user_id = ? AND course_id = ?",
 			payment.UserID, payment.CourseID).Updates(&DbPaymentRequest)
 
+		// Updates пропускает нулевые значения, поэтому снятие флага пишем отдельно
+		if !payment.Paid {
+			db.DB.Model(&models.EnrolledCourse{}).Where("user_id = ? AND course_id = ?",
+				payment.UserID, payment.CourseID).Update("paid", false)
+			continue
+		}
+
+		// оплата всего курса наличными/переводом: проводим остаток по взносам через леджер
+		var enrollment models.EnrolledCourse
+		if err := db.DB.Preload("Installments").Where("user_id = ? AND course_id = ?",
+			payment.UserID, payment.CourseID).First(&enrollment).Error; err != nil {
+			continue
+		}
+
+		var outstanding money.Amount
+		for _, installment := range enrollment.Installments {
+			outstanding += billing.Remaining(installment)
+		}
+
+		if outstanding <= 0 {
+			continue
+		}
+
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			return billing.RecordPayment(tx, &models.LedgerEntry{
+				EnrollmentID: enrollment.ID,
+				Kind:         billing.LedgerManual,
+				Amount:       outstanding,
+				Currency:     enrollment.Currency,
+				Note:         "Marked as paid by admin",
+			}, tmstmp)
+		})
+
+		if err != nil {
+			logger.Log("Failed to record manual payment! "+err.Error(), slog.LevelError)
+		}
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"success": "Payments updated successfully"})
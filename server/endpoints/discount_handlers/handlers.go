@@ -0,0 +1,182 @@
+package discount_handlers
+
+import (
+	"codev_erp/billing"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/logger"
+	"codev_erp/money"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DiscountRequest struct {
+	Name       string       `json:"name"`
+	Code       string       `json:"code"`
+	Kind       string       `json:"kind"`
+	Percent    uint         `json:"percent"`
+	Amount     money.Amount `json:"amount"`
+	Currency   string       `json:"currency"`
+	CourseID   *uint        `json:"courseID"`
+	ValidFrom  *time.Time   `json:"validFrom"`
+	ValidUntil *time.Time   `json:"validUntil"`
+	MaxUses    *uint        `json:"maxUses"`
+	Active     *bool        `json:"active"`
+}
+
+//Administrator-specific handlers
+
+func GetDiscountsHandler(ctx *gin.Context) {
+	var discounts []models.Discount
+
+	if err := db.DB.Preload("Course").Order("id").Find(&discounts).Error; err != nil {
+		logger.Log("Failed to get discounts: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get discounts"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, discounts)
+}
+
+func AddDiscountHandler(ctx *gin.Context) {
+	var req DiscountRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var discount models.Discount
+	discount.Active = true
+
+	if reason := applyDiscountRequest(&discount, req); reason != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": reason})
+		return
+	}
+
+	if err := db.DB.Create(&discount).Error; err != nil {
+		logger.Log("Failed to create discount: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create discount"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, discount)
+}
+
+func UpdateDiscountHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid discount ID"})
+		return
+	}
+
+	var req DiscountRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var discount models.Discount
+	if err := db.DB.Where("id = ?", id).First(&discount).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Discount not found"})
+		return
+	}
+
+	if reason := applyDiscountRequest(&discount, req); reason != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": reason})
+		return
+	}
+
+	// Select("*") so that cleared optional fields (code, validity, caps) are written as NULL
+	if err := db.DB.Select("*").Omit("uses").Save(&discount).Error; err != nil {
+		logger.Log("Failed to update discount: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update discount"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, discount)
+}
+
+func DeleteDiscountHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid discount ID"})
+		return
+	}
+
+	// enrollments keep referencing the discount, so it is only switched off
+	result := db.DB.Model(&models.Discount{}).Where("id = ?", id).Update("active", false)
+	if result.Error != nil {
+		logger.Log("Failed to deactivate discount: "+result.Error.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete discount"})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Discount not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Discount deactivated successfully"})
+}
+
+// applyDiscountRequest validates the request and copies it onto the model.
+// It returns a human-readable reason when the request is invalid.
+func applyDiscountRequest(discount *models.Discount, req DiscountRequest) string {
+	if strings.TrimSpace(req.Name) == "" {
+		return "Discount name is required"
+	}
+
+	switch req.Kind {
+	case billing.DiscountPercent:
+		if req.Percent == 0 || req.Percent > 100 {
+			return "Percent must be between 1 and 100"
+		}
+	case billing.DiscountFixed:
+		if req.Amount <= 0 {
+			return "Amount must be positive"
+		}
+	default:
+		return "Kind must be percent or fixed"
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	if !money.ValidCurrency(currency) {
+		return "Invalid currency"
+	}
+
+	if req.ValidFrom != nil && req.ValidUntil != nil && req.ValidUntil.Before(*req.ValidFrom) {
+		return "validUntil must be after validFrom"
+	}
+
+	discount.Name = req.Name
+	discount.Kind = req.Kind
+	discount.Percent = req.Percent
+	discount.Amount = req.Amount
+	discount.Currency = currency
+	discount.CourseID = req.CourseID
+	discount.ValidFrom = req.ValidFrom
+	discount.ValidUntil = req.ValidUntil
+	discount.MaxUses = req.MaxUses
+	discount.Code = nil
+
+	if code := strings.TrimSpace(req.Code); code != "" {
+		code = strings.ToUpper(code)
+		discount.Code = &code
+	}
+
+	if req.Active != nil {
+		discount.Active = *req.Active
+	}
+
+	return ""
+}
@@ -0,0 +1,190 @@
+package document_handlers
+
+import (
+	"codev_erp/attachment"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/documents"
+	"codev_erp/dto"
+	"codev_erp/endpoints"
+	"codev_erp/logger"
+	"codev_erp/storage"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetDocumentsHandler(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	user, ok := session.Get("user").(dto.UserResponse)
+
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	query := db.DB.Order("issued_at DESC")
+
+	switch user.Role {
+	case "admin":
+		if userId := ctx.Query("userId"); userId != "" {
+			query = query.Where("user_id = ?", userId)
+		}
+	case "student":
+		query = query.Where("user_id = ?", user.ID)
+	default:
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var docs []models.Document
+
+	if err := query.Find(&docs).Error; err != nil {
+		logger.Log("Failed to get documents: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get documents"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, docs)
+}
+
+func DownloadDocumentHandler(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	user, ok := session.Get("user").(dto.UserResponse)
+
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var doc models.Document
+
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&doc).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	if user.Role != "admin" && doc.UserID != user.ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	endpoints.ServeFile(ctx, doc.File, doc.Number+".pdf")
+}
+
+//Administrator-specific handlers
+
+func IssueDocumentHandler(ctx *gin.Context) {
+	var req struct {
+		Kind          string `json:"kind"`
+		EnrollmentID  uint   `json:"enrollment_id"`
+		InstallmentID *uint  `json:"installment_id"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.EnrollmentID == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if req.Kind != documents.KindInvoice && req.Kind != documents.KindReceipt {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be invoice or receipt"})
+		return
+	}
+
+	doc, err := documents.Issue(req.Kind, req.EnrollmentID, req.InstallmentID)
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Enrollment or installment not found"})
+		return
+	}
+
+	if errors.Is(err, documents.ErrNotPaid) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		logger.Log("Failed to issue "+req.Kind+": "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue document"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, doc)
+}
+
+func GetBrandingHandler(ctx *gin.Context) {
+	var branding models.Branding
+
+	if err := db.DB.FirstOrCreate(&branding, models.Branding{ID: 1}).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get branding"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, branding)
+}
+
+func UpdateBrandingHandler(ctx *gin.Context) {
+	form, err := ctx.MultipartForm()
+
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse form"})
+		return
+	}
+
+	var branding models.Branding
+	db.DB.FirstOrCreate(&branding, models.Branding{ID: 1})
+
+	fields := map[string]*string{
+		"school_name":  &branding.SchoolName,
+		"address":      &branding.Address,
+		"phone":        &branding.Phone,
+		"email":        &branding.Email,
+		"website":      &branding.Website,
+		"tax_id":       &branding.TaxID,
+		"accent_color": &branding.AccentColor,
+		"footer":       &branding.Footer,
+	}
+
+	for key, field := range fields {
+		if form.Value[key] != nil {
+			*field = form.Value[key][0]
+		}
+	}
+
+	if form.File["logo"] != nil {
+		filename := endpoints.ProcessImageFile(form.File["logo"][0], ctx, storage.BrandingDir)
+		if filename == "" {
+			return
+		}
+		branding.Logo = filename
+	}
+
+	if form.File["font"] != nil {
+		if !strings.EqualFold(filepath.Ext(form.File["font"][0].Filename), ".ttf") {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Font must be a .ttf file"})
+			return
+		}
+
+		user := endpoints.SessionUser(ctx)
+
+		font := endpoints.SaveAttachment(form.File["font"][0], ctx, attachment.UniqueKey(form.File["font"][0].Filename), user.ID)
+		if font == nil {
+			return
+		}
+		branding.FontFile = font.Key
+	}
+
+	if err := db.DB.Save(&branding).Error; err != nil {
+		logger.Log("Failed to update branding: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branding"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, branding)
+}
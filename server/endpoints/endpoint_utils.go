@@ -1,11 +1,23 @@
 package endpoints
 
 import (
+	"codev_erp/attachment"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/logger"
+	"codev_erp/scheduling"
+	"codev_erp/storage"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
 	"strings"
 
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -16,41 +28,176 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func ProcessImageFile(fh *multipart.FileHeader, ctx *gin.Context, ignoreUUID bool) string {
+// ProcessImageFile stores an uploaded picture under a random name in dir, one of the
+// public storage directories. Returns "" after responding on failure.
+func ProcessImageFile(fh *multipart.FileHeader, ctx *gin.Context, dir string) string {
 
 	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(fh.Filename), "."))
 	allowedExtensions := []string{"jpg", "jpeg", "png", "webp"}
 
-	if !ignoreUUID {
-		valid := false
-		for _, e := range allowedExtensions {
-			if e == ext {
-				valid = true
-				break
-			}
-		}
-		if !valid {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file extension"})
-			return ""
+	valid := false
+	for _, e := range allowedExtensions {
+		if e == ext {
+			valid = true
+			break
 		}
 	}
+	if !valid {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file extension"})
+		return ""
+	}
 
-	var filename string
+	filename := dir + "/" + uuid.New().String() + "." + ext
 
-	if !ignoreUUID {
-		filename = uuid.New().String() + "." + ext
+	if err := saveUpload(fh, filename); err != nil {
+		logger.Log("Failed to save file: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return ""
+	}
 
-	} else {
-		filename = strings.Trim(strings.ReplaceAll(fh.Filename, " ", "_"), " ")
+	return filename
+}
 
+// SaveAttachment stores an upload under key and records its size, type, checksum and
+// uploader. Returns nil after responding on failure.
+func SaveAttachment(fh *multipart.FileHeader, ctx *gin.Context, key string, uploadedBy uint) *models.Attachment {
+	src, err := fh.Open()
+	if err != nil {
+		logger.Log("Failed to open upload: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return nil
 	}
+	defer src.Close()
 
-	savePath := filepath.Join("./static", filename)
-
-	if err := ctx.SaveUploadedFile(fh, savePath); err != nil {
+	a, err := attachment.Save(db.DB, key, filepath.Base(fh.Filename), src, fh.Size, fh.Header.Get("Content-Type"), &uploadedBy)
+	if err != nil {
+		logger.Log("Failed to save file: "+err.Error(), slog.LevelError)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-		return ""
+		return nil
 	}
 
-	return filename
+	return &a
+}
+
+func saveUpload(fh *multipart.FileHeader, name string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return storage.Files.Put(name, src, fh.Size)
+}
+
+// ServeFile streams a stored file. With a downloadName the browser saves it under
+// that name instead of showing it.
+func ServeFile(ctx *gin.Context, name string, downloadName string) {
+	file, err := storage.Files.Open(name)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidName) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		logger.Log("Failed to open file: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	headers := map[string]string{}
+	if downloadName != "" {
+		// исходные имена бывают с пробелами и не латиницей, FormatMediaType их экранирует
+		headers["Content-Disposition"] = mime.FormatMediaType("attachment", map[string]string{"filename": downloadName})
+	}
+
+	ctx.DataFromReader(http.StatusOK, -1, contentType, file, headers)
+}
+
+// StaticHandler serves the public pictures under /static/*name from storage. Documents,
+// homework and lesson materials are only served by handlers that check access.
+func StaticHandler(ctx *gin.Context) {
+	name := strings.TrimPrefix(ctx.Param("name"), "/")
+
+	if !storage.IsPublic(name) && !isLegacyPicture(name) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	ServeFile(ctx, name, "")
+}
+
+// isLegacyPicture accepts the flat names that avatars, course previews and logos were
+// stored under before the public directories, as long as one still points at them.
+func isLegacyPicture(name string) bool {
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		return false
+	}
+
+	var referenced bool
+	err := db.DB.Raw(`SELECT EXISTS (SELECT 1 FROM users WHERE avatar = @name)
+		OR EXISTS (SELECT 1 FROM courses WHERE preview_image = @name)
+		OR EXISTS (SELECT 1 FROM brandings WHERE logo = @name)`, sql.Named("name", name)).
+		Scan(&referenced).Error
+	if err != nil {
+		logger.Log("Failed to look up static file: "+err.Error(), slog.LevelError)
+		return false
+	}
+	return referenced
+}
+
+// CanManageCourse reports whether the user may edit a course's lessons and schedule:
+// admins always, teachers only for courses they teach.
+func CanManageCourse(user dto.UserResponse, courseID uint) bool {
+	switch user.Role {
+	case "admin":
+		return true
+	case "teacher":
+		var count int64
+		db.DB.Model(&models.Course{}).Where("id = ? AND teacher_id = ?", courseID, user.ID).Count(&count)
+		return count > 0
+	}
+	return false
+}
+
+// RespondConflicts rejects a booking that clashes with already scheduled lessons.
+func RespondConflicts(ctx *gin.Context, conflicts []scheduling.Conflict) {
+	ctx.JSON(http.StatusConflict, gin.H{"error": "The lesson conflicts with other bookings", "conflicts": conflicts})
+}
+
+// CanManageLesson extends CanManageCourse to a single lesson: its substitute teacher
+// may mark attendance and grade homework for it while assigned.
+func CanManageLesson(user dto.UserResponse, lesson models.Lesson) bool {
+	if lesson.SubstituteID != nil && *lesson.SubstituteID == user.ID {
+		return true
+	}
+	return CanManageCourse(user, lesson.CourseID)
+}
+
+// ManagedLesson loads a lesson for a teacher who may manage it (see CanManageLesson).
+// It responds and returns false when the lesson is missing or belongs to someone else.
+func ManagedLesson(ctx *gin.Context, lessonID interface{}) (models.Lesson, bool) {
+	var lesson models.Lesson
+	if err := db.DB.Where("id = ?", lessonID).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		return lesson, false
+	}
+
+	if !CanManageLesson(SessionUser(ctx), lesson) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return lesson, false
+	}
+
+	return lesson, true
+}
+
+// SessionUser is the logged-in user. Routes behind middleware.ValidateUser always
+// have one; elsewhere the zero value stands for a guest.
+func SessionUser(ctx *gin.Context) dto.UserResponse {
+	user, _ := sessions.Default(ctx).Get("user").(dto.UserResponse)
+	return user
 }
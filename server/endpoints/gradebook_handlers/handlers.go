@@ -0,0 +1,229 @@
+package gradebook_handlers
+
+import (
+	"bytes"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/endpoints"
+	"codev_erp/gradebook"
+	"codev_erp/logger"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+type schemeRequest struct {
+	HomeworkWeight   uint               `json:"homeworkWeight"`
+	ClassworkWeight  uint               `json:"classworkWeight"`
+	ExamWeight       uint               `json:"examWeight"`
+	AttendanceWeight uint               `json:"attendanceWeight"`
+	Scale            []models.GradeBand `json:"scale"`
+}
+
+type lessonGradingRequest struct {
+	Category  string `json:"category" binding:"required"`
+	MaxPoints uint   `json:"maxPoints" binding:"required,min=1"`
+}
+
+// courseParam reads :id and checks that the current user manages the course.
+func courseParam(ctx *gin.Context) (uint, bool) {
+	courseID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course id"})
+		return 0, false
+	}
+
+	if !endpoints.CanManageCourse(endpoints.SessionUser(ctx), uint(courseID)) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return 0, false
+	}
+
+	return uint(courseID), true
+}
+
+func GetGradebookHandler(ctx *gin.Context) {
+	courseID, ok := courseParam(ctx)
+	if !ok {
+		return
+	}
+
+	book, err := gradebook.Build(db.DB, courseID, time.Now())
+	if err != nil {
+		logger.Log("Failed to build gradebook: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build gradebook"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, book)
+}
+
+// ExportGradebookHandler downloads the gradebook as ?format=csv (default) or xlsx.
+func ExportGradebookHandler(ctx *gin.Context) {
+	courseID, ok := courseParam(ctx)
+	if !ok {
+		return
+	}
+
+	format := ctx.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or xlsx"})
+		return
+	}
+
+	book, err := gradebook.Build(db.DB, courseID, time.Now())
+	if err != nil {
+		logger.Log("Failed to build gradebook: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build gradebook"})
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "text/csv; charset=utf-8"
+
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = gradebook.WriteXLSX(&buf, gradebook.Table(book))
+	} else {
+		err = gradebook.WriteCSV(&buf, gradebook.Table(book))
+	}
+
+	if err != nil {
+		logger.Log("Failed to export gradebook: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export gradebook"})
+		return
+	}
+
+	filename := fmt.Sprintf("gradebook_course%d_%s.%s", courseID, time.Now().Format("2006-01-02"), format)
+	ctx.Header("Content-Disposition", "attachment; filename="+filename)
+	ctx.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// GetMyGradeHandler shows a student their own row of the course gradebook.
+func GetMyGradeHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	courseID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course id"})
+		return
+	}
+
+	book, err := gradebook.Build(db.DB, uint(courseID), time.Now())
+	if err != nil {
+		logger.Log("Failed to build gradebook: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build gradebook"})
+		return
+	}
+
+	for _, row := range book.Rows {
+		if row.UserID == user.ID {
+			ctx.JSON(http.StatusOK, gin.H{"columns": book.Columns, "row": row, "scheme": book.Scheme})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusNotFound, gin.H{"error": "You are not enrolled in this course"})
+}
+
+func GetGradingSchemeHandler(ctx *gin.Context) {
+	courseID, ok := courseParam(ctx)
+	if !ok {
+		return
+	}
+
+	scheme, err := gradebook.Scheme(db.DB, courseID)
+	if err != nil {
+		logger.Log("Failed to get grading scheme: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get grading scheme"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheme)
+}
+
+func UpdateGradingSchemeHandler(ctx *gin.Context) {
+	courseID, ok := courseParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req schemeRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if req.HomeworkWeight+req.ClassworkWeight+req.ExamWeight+req.AttendanceWeight != 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Category weights must add up to 100"})
+		return
+	}
+
+	scheme := models.GradingScheme{
+		CourseID:         courseID,
+		HomeworkWeight:   req.HomeworkWeight,
+		ClassworkWeight:  req.ClassworkWeight,
+		ExamWeight:       req.ExamWeight,
+		AttendanceWeight: req.AttendanceWeight,
+		Scale:            gradebook.DefaultScale,
+		UpdatedAt:        time.Now(),
+	}
+
+	if len(req.Scale) > 0 {
+		scale, err := gradebook.NormalizeScale(req.Scale)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		scheme.Scale = scale
+	}
+
+	if err := db.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&scheme).Error; err != nil {
+		logger.Log("Failed to save grading scheme: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save grading scheme"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheme)
+}
+
+// SetLessonGradingHandler files a lesson under a gradebook category and sets the
+// points its work is graded out of.
+func SetLessonGradingHandler(ctx *gin.Context) {
+	var req lessonGradingRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if !gradebook.ValidLessonCategory(req.Category) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Category must be homework, classwork or exam"})
+		return
+	}
+
+	var lesson models.Lesson
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(endpoints.SessionUser(ctx), lesson.CourseID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	err := db.DB.Model(&lesson).Updates(map[string]interface{}{"category": req.Category, "max_points": req.MaxPoints}).Error
+	if err != nil {
+		logger.Log("Failed to update lesson grading: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update lesson grading"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Lesson grading updated successfully"})
+}
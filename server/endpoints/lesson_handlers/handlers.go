@@ -1,19 +1,31 @@
 package lesson_handlers
 
 import (
+	"codev_erp/attachment"
+	"codev_erp/attendance"
+	"codev_erp/autograde"
 	"codev_erp/db"
 	"codev_erp/db/models"
 	"codev_erp/dto"
 	"codev_erp/endpoints"
+	"codev_erp/homework"
 	"codev_erp/logger"
+	"codev_erp/notify"
+	"codev_erp/peerreview"
+	"codev_erp/quiz"
+	"codev_erp/scheduling"
+	"codev_erp/similarity"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func AddLessonHandler(ctx *gin.Context) {
@@ -24,10 +36,50 @@ func AddLessonHandler(ctx *gin.Context) {
 		return
 	}
 
+	if req.Duration == 0 {
+		req.Duration = 90
+	}
+
+	startDate := time.Now()
+	if req.StartDate != nil {
+		startDate = *req.StartDate
+	}
+
+	conflicts, err := scheduling.Conflicts(db.DB, scheduling.Booking{
+		CourseID:    req.CourseID,
+		Start:       startDate,
+		Duration:    req.Duration,
+		RoomID:      req.RoomID,
+		ResourceIDs: req.ResourceIDs,
+	}, nil)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Course not found"})
+		return
+	}
+	if err != nil {
+		logger.Log("Failed to check lesson conflicts: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create lesson"})
+		return
+	}
+	if len(conflicts) > 0 {
+		endpoints.RespondConflicts(ctx, conflicts)
+		return
+	}
+
 	lesson := models.Lesson{
 		CourseID:    req.CourseID,
 		Name:        req.Name,
 		Description: req.Description,
+		StartDate:   startDate,
+		Duration:    req.Duration,
+		RoomID:      req.RoomID,
+	}
+
+	if len(req.ResourceIDs) > 0 {
+		if err := db.DB.Where("id IN ?", req.ResourceIDs).Find(&lesson.Resources).Error; err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create lesson"})
+			return
+		}
 	}
 
 	if err := db.DB.Create(&lesson).Error; err != nil {
@@ -139,19 +191,45 @@ func AddTasksHandler(ctx *gin.Context) {
 	var tasks models.LessonTasks
 	tasks.LessonID = uint(lessonIdInt)
 
+	if len(homeworkFiles) > 0 && !allowsHomework(ctx, tasks.LessonID) {
+		return
+	}
+
+	// срок сдачи хранится у занятия; без поля прежний срок остаётся
+	if dueDate := form.Value["due_date"]; len(dueDate) > 0 && dueDate[0] != "" {
+		due, err := time.Parse(time.RFC3339, dueDate[0])
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "due_date must be an RFC 3339 time"})
+			return
+		}
+
+		if err := db.DB.Model(&models.Lesson{}).Where("id = ?", tasks.LessonID).Update("due_date", due).Error; err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tasks"})
+			return
+		}
+	}
+
+	user := endpoints.SessionUser(ctx)
+
 	// Process Homework Files
 	for _, file := range homeworkFiles {
-		filename := endpoints.ProcessImageFile(file, ctx, true)
-		tasks.Homework = append(tasks.Homework, filename)
+		a := endpoints.SaveAttachment(file, ctx, attachment.UniqueKey(file.Filename), user.ID)
+		if a == nil {
+			return
+		}
+		tasks.HomeworkFiles = append(tasks.HomeworkFiles, *a)
 	}
 
 	// Process Classwork Files
 	for _, file := range classworkFiles {
-		filename := endpoints.ProcessImageFile(file, ctx, true)
-		tasks.Classwork = append(tasks.Classwork, filename)
+		a := endpoints.SaveAttachment(file, ctx, attachment.UniqueKey(file.Filename), user.ID)
+		if a == nil {
+			return
+		}
+		tasks.ClassworkFiles = append(tasks.ClassworkFiles, *a)
 	}
 
-	// ✅ GORM сам преобразует []string → JSON
+	// GORM сам записывает и связи с вложениями
 	err = db.DB.Create(&tasks).Error
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tasks", "details": err.Error()})
@@ -172,7 +250,7 @@ func GetLessonTasksHandler(ctx *gin.Context) {
 
 	var lessontasks []models.LessonTasks
 
-	err = db.DB.Where("lesson_id = ?", lessonIdInt).Find(&lessontasks).Error
+	err = db.DB.Preload("HomeworkFiles").Preload("ClassworkFiles").Where("lesson_id = ?", lessonIdInt).Find(&lessontasks).Error
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks", "details": err.Error()})
 	}
@@ -184,32 +262,47 @@ func GetLessonTasksHandler(ctx *gin.Context) {
 
 	for _, task := range lessontasks {
 
-		if task.Homework != nil {
-
-			for _, hometask := range task.Homework {
-
-				newLessonTask.Homework = append(newLessonTask.Homework, hometask)
+		newLessonTask.HomeworkFiles = append(newLessonTask.HomeworkFiles, task.HomeworkFiles...)
+		newLessonTask.ClassworkFiles = append(newLessonTask.ClassworkFiles, task.ClassworkFiles...)
 
-			}
-
-		}
+	}
 
-		if task.Classwork != nil {
+	ctx.JSON(http.StatusOK, newLessonTask)
 
-			for _, classwork := range task.Classwork {
+}
 
-				newLessonTask.Classwork = append(newLessonTask.Classwork, classwork)
+// SetDueDateHandler sets or clears the homework deadline of a lesson.
+func SetDueDateHandler(ctx *gin.Context) {
+	lessonID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lessonId"})
+		return
+	}
 
-			}
+	var req struct {
+		DueDate *time.Time `json:"dueDate"`
+	}
 
-		}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
 
+	if _, ok := endpoints.ManagedLesson(ctx, lessonID); !ok {
+		return
 	}
 
-	ctx.JSON(http.StatusOK, newLessonTask)
+	if err := db.DB.Model(&models.Lesson{}).Where("id = ?", lessonID).Update("due_date", req.DueDate).Error; err != nil {
+		logger.Log("Failed to set due date: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set due date"})
+		return
+	}
 
+	ctx.JSON(http.StatusOK, gin.H{"success": "Due date updated successfully", "dueDate": req.DueDate})
 }
 
+// FileDownloadHandler serves lesson materials; other stored files, such as homework
+// and documents, are not reachable by key here.
 func FileDownloadHandler(ctx *gin.Context) {
 
 	file := ctx.Param("file")
@@ -219,19 +312,44 @@ func FileDownloadHandler(ctx *gin.Context) {
 		return
 	}
 
-	dir, err := os.Getwd()
-
+	var material models.Attachment
+	err := db.DB.Where("key = ?", file).
+		Where("id IN (?) OR id IN (?)",
+			db.DB.Table("lesson_task_homework_files").Select("attachment_id"),
+			db.DB.Table("lesson_task_classwork_files").Select("attachment_id")).
+		First(&material).Error
 	if err != nil {
-		logger.Log("Failed to get working directory: "+err.Error(), slog.LevelError)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get working directory"})
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
 
-	fullPath := filepath.Join(dir, "static", file)
-	ctx.Header("Content-Disposition", "attachment; filename="+file)
-	ctx.Header("Content-Type", "application/octet-stream")
+	endpoints.ServeFile(ctx, material.Key, material.OriginalName)
+}
+
+// DownloadHomeworkFileHandler serves a file of any version of a submission to its
+// author and to the teachers of the lesson.
+func DownloadHomeworkFileHandler(ctx *gin.Context) {
+	submission, ok := loadVisibleHomework(ctx)
+	if !ok {
+		return
+	}
+
+	var versions []models.HomeworkVersion
+	if err := db.DB.Preload("Files").Where("homework_id = ?", submission.ID).Find(&versions).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get versions"})
+		return
+	}
+
+	for _, version := range versions {
+		for _, file := range version.Files {
+			if file.Key == ctx.Param("key") {
+				endpoints.ServeFile(ctx, file.Key, file.OriginalName)
+				return
+			}
+		}
+	}
 
-	ctx.File(fullPath)
+	ctx.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 }
 
 func ScreenRecordHandler(ctx *gin.Context) {
@@ -264,9 +382,9 @@ func ScreenRecordHandler(ctx *gin.Context) {
 		return
 	}
 
-	file.Filename = nativeFilename[0]
-
-	filename := endpoints.ProcessImageFile(file, ctx, true)
+	if len(nativeFilename) > 0 && nativeFilename[0] != "" {
+		file.Filename = nativeFilename[0]
+	}
 
 	var lesson models.LessonTasks
 
@@ -276,8 +394,15 @@ func ScreenRecordHandler(ctx *gin.Context) {
 		return
 	}
 
+	user := endpoints.SessionUser(ctx)
+
+	a := endpoints.SaveAttachment(file, ctx, attachment.UniqueKey(file.Filename), user.ID)
+	if a == nil {
+		return
+	}
+
 	// 2. Добавляем файл
-	lesson.Classwork = append(lesson.Classwork, filename)
+	lesson.ClassworkFiles = append(lesson.ClassworkFiles, *a)
 
 	// 3. Сохраняем
 	if err := db.DB.Save(&lesson).Error; err != nil {
@@ -289,6 +414,61 @@ func ScreenRecordHandler(ctx *gin.Context) {
 
 }
 
+// GetSimilarityReportHandler returns the plagiarism report of a lesson, most similar
+// pairs first, with the students' names.
+func GetSimilarityReportHandler(ctx *gin.Context) {
+	lessonID := ctx.Param("id")
+
+	if _, ok := endpoints.ManagedLesson(ctx, lessonID); !ok {
+		return
+	}
+
+	var report models.SimilarityReport
+	err := db.DB.
+		Preload("Pairs", func(db *gorm.DB) *gorm.DB { return db.Order("score DESC") }).
+		Where("lesson_id = ?", lessonID).
+		First(&report).Error
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No similarity report yet"})
+		return
+	}
+
+	var students []models.User
+	if err := db.DB.Where("id IN (?)", db.DB.Model(&models.UsersHomework{}).Where("lesson_id = ?", lessonID).Select("user_id")).Find(&students).Error; err != nil {
+		logger.Log("Failed to get students: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get students"})
+		return
+	}
+
+	names := map[uint]string{}
+	for _, student := range students {
+		names[student.ID] = student.FirstName + " " + student.LastName
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"report": report, "students": names})
+}
+
+// RequestSimilarityReportHandler queues the lesson's report to be recomputed.
+func RequestSimilarityReportHandler(ctx *gin.Context) {
+	lessonID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lesson id"})
+		return
+	}
+
+	if _, ok := endpoints.ManagedLesson(ctx, lessonID); !ok {
+		return
+	}
+
+	if err := similarity.Request(db.DB, uint(lessonID)); err != nil {
+		logger.Log("Failed to queue similarity report: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue similarity report"})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"success": "Similarity report queued"})
+}
+
 func SubmitHomeworkHandler(ctx *gin.Context) {
 
 	form, err := ctx.MultipartForm()
@@ -298,13 +478,13 @@ func SubmitHomeworkHandler(ctx *gin.Context) {
 		return
 	}
 
-	userID := form.Value["userId"]
-	lessonID := form.Value["lessonId"]
-
-	userIdInt, err := strconv.Atoi(userID[0])
+	// сдаёт всегда владелец сессии: userId из формы не принимаем
+	user := endpoints.SessionUser(ctx)
+	userIdInt := int(user.ID)
 
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userId"})
+	lessonID := form.Value["lessonId"]
+	if len(lessonID) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "lessonId is required"})
 		return
 	}
 
@@ -315,12 +495,28 @@ func SubmitHomeworkHandler(ctx *gin.Context) {
 		return
 	}
 
-	var checkHwModel models.UsersHomework
+	var lesson models.Lesson
+	if err := db.DB.Preload("Course").Where("id = ?", lessonIdInt).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Lesson not found"})
+		return
+	}
 
-	if err := db.DB.Where("user_id = ? and lesson_id = ?",
-		userIdInt, lessonIdInt).First(&checkHwModel).Error; err == nil {
+	if enrolled, reason := checkCourseEnrollment(userIdInt, int(lesson.CourseID), user.Role); !enrolled {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": reason})
+		return
+	}
 
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "You have already submitted homework for this lesson"})
+	if !allowsHomework(ctx, lesson.ID) {
+		return
+	}
+
+	// повторная сдача возможна до проверки или после запроса доработки
+	var userHw models.UsersHomework
+
+	existing := db.DB.Where("user_id = ? and lesson_id = ?", userIdInt, lessonIdInt).First(&userHw).Error == nil
+	if existing && userHw.Status == homework.StatusGraded {
+
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Homework is already graded"})
 		return
 
 	}
@@ -329,24 +525,83 @@ func SubmitHomeworkHandler(ctx *gin.Context) {
 
 	if len(hwFile) == 0 {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "homework_file is required"})
+		return
 	}
 
-	var userHw models.UsersHomework
+	submittedAt := time.Now()
 
-	userHw.UserID = uint(userIdInt)
-	userHw.LessonID = uint(lessonIdInt)
+	lateness := homework.Lateness{Late: userHw.Late, DaysLate: userHw.DaysLate, PenaltyPercent: userHw.PenaltyPercent}
 
+	// доработку по запросу преподавателя не штрафуем повторно за срок
+	if !existing || userHw.Status != homework.StatusChangesRequested {
+		lateness, err = homework.Evaluate(lesson.Course, lesson.DueDate, submittedAt)
+		if errors.Is(err, homework.ErrPastCutoff) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	version := uint(1)
+	if existing {
+		version = userHw.Version + 1
+	}
+
+	var files []models.Attachment
 	for _, file := range hwFile {
 
-		filename := endpoints.ProcessImageFile(file, ctx, true)
-		userHw.Homework = append(userHw.Homework, filename)
+		// ключ случайный: по нему нельзя узнать автора, а версия и автор есть только в БД
+		a := endpoints.SaveAttachment(file, ctx, attachment.UniqueKey(file.Filename), uint(userIdInt))
+		if a == nil {
+			return
+		}
+		files = append(files, *a)
 
 	}
 
-	db.DB.Create(&userHw)
+	userHw.UserID = uint(userIdInt)
+	userHw.LessonID = uint(lessonIdInt)
+	userHw.StartDate = submittedAt
+	userHw.Late = lateness.Late
+	userHw.DaysLate = lateness.DaysLate
+	userHw.PenaltyPercent = lateness.PenaltyPercent
+	userHw.Status = homework.StatusSubmitted
+	userHw.Version = version
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User", "Lesson", "Files").Save(&userHw).Error; err != nil {
+			return err
+		}
+		// ссылки на файлы прошлой версии заменяем, сами файлы остаются в её HomeworkVersion
+		if err := tx.Model(&userHw).Association("Files").Replace(files); err != nil {
+			return err
+		}
+		err := tx.Create(&models.HomeworkVersion{
+			HomeworkID:     userHw.ID,
+			Number:         version,
+			Files:          files,
+			SubmittedAt:    submittedAt,
+			Late:           lateness.Late,
+			DaysLate:       lateness.DaysLate,
+			PenaltyPercent: lateness.PenaltyPercent,
+		}).Error
+		if err != nil {
+			return err
+		}
+		// автотесты запускаются фоновым джобом, если у занятия есть набор тестов
+		if _, err := autograde.Enqueue(tx, userHw.ID, userHw.LessonID, version); err != nil {
+			return err
+		}
+		return similarity.Request(tx, userHw.LessonID)
+	})
+
+	if err != nil {
+		logger.Log("Failed to submit homework: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit homework"})
+		return
+	}
 
 	logger.Log("User with ID "+string(rune(userIdInt))+" submitted homewrork", slog.LevelDebug)
-	ctx.JSON(http.StatusOK, gin.H{"success": "Homework submitted successfully"})
+	ctx.JSON(http.StatusOK, gin.H{"success": "Homework submitted successfully", "version": userHw.Version, "late": userHw.Late, "penaltyPercent": userHw.PenaltyPercent})
 
 }
 
@@ -359,16 +614,56 @@ func ListHomeworkHandler(ctx *gin.Context) {
 		return
 	}
 
+	if _, ok := endpoints.ManagedLesson(ctx, lessonID); !ok {
+		return
+	}
+
 	var homework []models.UsersHomework
 
-	if err := db.DB.Preload("User").Where("lesson_id = ?", lessonID).Find(&homework).Error; err != nil {
+	if err := db.DB.Preload("User").Preload("Files").Where("lesson_id = ?", lessonID).Find(&homework).Error; err != nil {
 
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get homework"})
 		logger.Log("Failed to get homework: "+err.Error(), slog.LevelError)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, homework)
+	ids := make([]uint, 0, len(homework))
+	for _, hw := range homework {
+		ids = append(ids, hw.ID)
+	}
+
+	summaries, err := peerreview.Summaries(db.DB, ids)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get peer reviews"})
+		logger.Log("Failed to get peer reviews: "+err.Error(), slog.LevelError)
+		return
+	}
+
+	// PeerReview is omitted for works that were never handed out for peer review
+	type listedHomework struct {
+		models.UsersHomework
+		PeerReview *peerreview.Summary `json:"peerReview,omitempty"`
+	}
+
+	list := make([]listedHomework, 0, len(homework))
+	for _, hw := range homework {
+		item := listedHomework{UsersHomework: hw}
+		if summary, ok := summaries[hw.ID]; ok {
+			item.PeerReview = &summary
+		}
+		list = append(list, item)
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// gradeRequest is the grade for one submission, alone or as part of a batch.
+type gradeRequest struct {
+	Points  int                   `json:"points"`
+	Comment string                `json:"comment"`
+	Scores  []homework.ScoreInput `json:"scores"` // при рубрике баллы считаются по критериям
+	// AcceptSuggested берёт баллы из последнего прогона автотестов этой версии
+	AcceptSuggested bool `json:"acceptSuggested"`
 }
 
 func GradeHomeworkHandler(ctx *gin.Context) {
@@ -381,40 +676,340 @@ func GradeHomeworkHandler(ctx *gin.Context) {
 
 	}
 
-	type GradeRequest struct {
-		Points  int    `json:"points"`
-		Comment string `json:"comment"`
+	var req gradeRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+
+	}
+
+	if status, err := gradeSubmission(ctx, hwId, req); err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
 	}
 
-	var req GradeRequest
+	ctx.JSON(http.StatusOK, gin.H{"success": "Homework graded successfully"})
+}
+
+// gradeSubmission claims and grades one homework for the current teacher. On failure
+// it returns the HTTP status and an error whose message can be shown to the client.
+func gradeSubmission(ctx *gin.Context, hwID interface{}, req gradeRequest) (int, error) {
+	user := endpoints.SessionUser(ctx)
 
-	if err := ctx.ShouldBindJSON(&req); err != nil {
+	var submission models.UsersHomework
+
+	if err := db.DB.Preload("Lesson").Where("id = ?", hwID).First(&submission).Error; err != nil {
+		return http.StatusNotFound, errors.New("Homework not found")
+	}
+
+	if !endpoints.CanManageLesson(user, submission.Lesson) {
+		return http.StatusForbidden, errors.New("Course not found or you are not the teacher")
+	}
+
+	if submission.Status == homework.StatusChangesRequested {
+		return http.StatusBadRequest, errors.New("Waiting for the student to resubmit")
+	}
+
+	// бронь берётся и при пакетной оценке, чтобы не перебить работу коллеги
+	if err := homework.Claim(db.DB, submission.ID, user.ID, time.Now()); err != nil {
+		if errors.Is(err, homework.ErrClaimed) {
+			return http.StatusConflict, err
+		}
+		logger.Log("Failed to claim homework: "+err.Error(), slog.LevelError)
+		return http.StatusInternalServerError, errors.New("Failed to claim homework")
+	}
+
+	if req.AcceptSuggested {
+		run, err := autograde.Latest(db.DB, submission.ID, submission.Version)
+		if err != nil {
+			logger.Log("Failed to get test run: "+err.Error(), slog.LevelError)
+			return http.StatusInternalServerError, errors.New("Failed to get test run")
+		}
+		if run == nil {
+			return http.StatusBadRequest, errors.New("The current version has no finished test run")
+		}
+		req.Points = int(run.SuggestedPoints)
+	}
+
+	if status, err := scoreSubmission(&submission, submission.Lesson, req.Points, req.Comment, req.Scores); err != nil {
+		return status, err
+	}
+
+	err := recordGrade(ctx, &submission, homework.GradeKindGrade)
+	if errors.Is(err, homework.ErrClaimed) {
+		return http.StatusConflict, err
+	}
+	if err != nil {
+		logger.Log("Failed to grade homework: "+err.Error(), slog.LevelError)
+		return http.StatusInternalServerError, errors.New("Failed to grade homework")
+	}
+
+	return http.StatusOK, nil
+}
+
+// GetGradingQueueHandler lists the submissions waiting for a grade across every course
+// the teacher teaches or substitutes in, or across all courses for an admin.
+// ?sort=lesson groups them by lesson, otherwise the oldest submissions come first;
+// ?courseId= and ?lessonId= narrow the list and ?claimed=mine|unclaimed filters by claim.
+func GetGradingQueueHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+	now := time.Now()
+
+	query := db.DB.Model(&models.UsersHomework{}).
+		Joins("JOIN lessons ON lessons.id = users_homeworks.lesson_id").
+		Joins("JOIN courses ON courses.id = lessons.course_id").
+		Where("users_homeworks.status = ?", homework.StatusSubmitted)
+
+	if user.Role != "admin" {
+		query = query.Where("courses.teacher_id = ? OR lessons.substitute_id = ?", user.ID, user.ID)
+	}
+	if courseID := ctx.Query("courseId"); courseID != "" {
+		query = query.Where("lessons.course_id = ?", courseID)
+	}
+	if lessonID := ctx.Query("lessonId"); lessonID != "" {
+		query = query.Where("users_homeworks.lesson_id = ?", lessonID)
+	}
+
+	expired := now.Add(-homework.ClaimTTL)
+	switch ctx.Query("claimed") {
+	case "mine":
+		query = query.Where("users_homeworks.claimed_by = ? AND users_homeworks.claimed_at >= ?", user.ID, expired)
+	case "unclaimed":
+		query = query.Where("users_homeworks.claimed_by IS NULL OR users_homeworks.claimed_at < ?", expired)
+	}
+
+	if ctx.Query("sort") == "lesson" {
+		query = query.Order("lessons.start_date, lessons.id, users_homeworks.start_date")
+	} else {
+		query = query.Order("users_homeworks.start_date, users_homeworks.id")
+	}
 
+	var queue []models.UsersHomework
+
+	if err := query.Preload("User").Preload("Lesson.Course").Preload("Files").Find(&queue).Error; err != nil {
+		logger.Log("Failed to get grading queue: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get grading queue"})
+		return
+	}
+
+	for i := range queue {
+		// истёкшая бронь никого не держит, поэтому её не показываем
+		queue[i].ClaimedBy = homework.ClaimHolder(queue[i], now)
+		if queue[i].ClaimedBy == nil {
+			queue[i].ClaimedAt = nil
+		}
+	}
+
+	ctx.JSON(http.StatusOK, queue)
+}
+
+// ClaimHomeworkHandler reserves a submission so co-teachers don't grade it at the same
+// time. The claim lasts homework.ClaimTTL; claiming again renews it.
+func ClaimHomeworkHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var submission models.UsersHomework
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&submission).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Homework not found"})
+		return
+	}
+
+	if _, ok := endpoints.ManagedLesson(ctx, submission.LessonID); !ok {
+		return
+	}
+
+	if submission.Status != homework.StatusSubmitted {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The homework is not waiting for a grade"})
+		return
+	}
+
+	err := homework.Claim(db.DB, submission.ID, user.ID, time.Now())
+	if errors.Is(err, homework.ErrClaimed) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Log("Failed to claim homework: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim homework"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Homework claimed", "expiresAt": time.Now().Add(homework.ClaimTTL)})
+}
+
+// ReleaseHomeworkHandler gives up the current teacher's claim on a submission.
+func ReleaseHomeworkHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var submission models.UsersHomework
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&submission).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Homework not found"})
+		return
+	}
+
+	if err := homework.Release(db.DB, submission.ID, user.ID); err != nil {
+		logger.Log("Failed to release homework: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release homework"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Homework released"})
+}
+
+// BatchGradeHandler grades several submissions in one request. Each one is graded on
+// its own, so one failure doesn't roll back the others; the result lists every outcome.
+func BatchGradeHandler(ctx *gin.Context) {
+	type batchItem struct {
+		ID uint `json:"id" binding:"required"`
+		gradeRequest
+	}
+
+	var req struct {
+		Grades []batchItem `json:"grades" binding:"required,min=1,dive"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 		return
+	}
 
+	type batchResult struct {
+		ID      uint   `json:"id"`
+		Success bool   `json:"success"`
+		Error   string `json:"error,omitempty"`
 	}
 
-	var homework models.UsersHomework
+	results := make([]batchResult, 0, len(req.Grades))
+	graded := 0
 
-	if err := db.DB.Where("id = ?", hwId).First(&homework).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get homework"})
-		logger.Log("Failed to get homework: "+err.Error(), slog.LevelError)
+	for _, item := range req.Grades {
+		result := batchResult{ID: item.ID, Success: true}
+		if _, err := gradeSubmission(ctx, item.ID, item.gradeRequest); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+		} else {
+			graded++
+		}
+		results = append(results, result)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"graded": graded, "results": results})
+}
+
+// RequestChangesHandler sends the homework back to the student, who may then resubmit.
+func RequestChangesHandler(ctx *gin.Context) {
+	var req struct {
+		Comment string `json:"comment" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "A comment explaining the changes is required"})
 		return
 	}
 
-	if homework.Checked {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Homework already graded"})
+	var submission models.UsersHomework
+	if err := db.DB.Preload("Lesson").Where("id = ?", ctx.Param("id")).First(&submission).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Homework not found"})
 		return
 	}
 
-	homework.Points = uint(req.Points)
-	homework.Comment = req.Comment
-	homework.Checked = true
+	if _, ok := endpoints.ManagedLesson(ctx, submission.LessonID); !ok {
+		return
+	}
 
-	db.DB.Save(&homework)
+	submission.Comment = req.Comment
+	submission.Checked = false
+	submission.Status = homework.StatusChangesRequested
 
-	ctx.JSON(http.StatusOK, gin.H{"success": "Homework graded successfully"})
+	err := recordGrade(ctx, &submission, homework.GradeKindChangesRequested)
+	if errors.Is(err, homework.ErrClaimed) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Log("Failed to request changes: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request changes"})
+		return
+	}
+
+	notify.Send(submission.UserID, notify.Message{
+		Kind:  "homework_changes_requested",
+		Title: "Homework needs changes",
+		Body:  fmt.Sprintf("Your homework for %s needs changes: %s", submission.Lesson.Name, req.Comment),
+	})
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Changes requested"})
+}
+
+// GetHomeworkVersionsHandler lists every submitted version with its files.
+func GetHomeworkVersionsHandler(ctx *gin.Context) {
+	submission, ok := loadVisibleHomework(ctx)
+	if !ok {
+		return
+	}
+
+	var versions []models.HomeworkVersion
+	if err := db.DB.Preload("Files").Where("homework_id = ?", submission.ID).Order("number").Find(&versions).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get versions"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, versions)
+}
+
+// CompareHomeworkVersionsHandler diffs two versions, by default the last two.
+func CompareHomeworkVersionsHandler(ctx *gin.Context) {
+	submission, ok := loadVisibleHomework(ctx)
+	if !ok {
+		return
+	}
+
+	to := submission.Version
+	if value, err := strconv.Atoi(ctx.Query("to")); err == nil {
+		to = uint(value)
+	}
+	from := to - 1
+	if value, err := strconv.Atoi(ctx.Query("from")); err == nil {
+		from = uint(value)
+	}
+
+	var versions []models.HomeworkVersion
+	db.DB.Preload("Files").Where("homework_id = ? AND number IN ?", submission.ID, []uint{from, to}).Find(&versions)
+
+	var fromVersion, toVersion *models.HomeworkVersion
+	for i := range versions {
+		switch versions[i].Number {
+		case from:
+			fromVersion = &versions[i]
+		case to:
+			toVersion = &versions[i]
+		}
+	}
+
+	if fromVersion == nil || toVersion == nil || from == to {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Two different existing versions are required"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"from": from, "to": to, "files": homework.Compare(*fromVersion, *toVersion)})
+}
+
+// GetGradeHistoryHandler lists every grade and change request of a homework.
+func GetGradeHistoryHandler(ctx *gin.Context) {
+	submission, ok := loadVisibleHomework(ctx)
+	if !ok {
+		return
+	}
+
+	var history []models.GradeRecord
+	if err := db.DB.Where("homework_id = ?", submission.ID).Order("graded_at").Find(&history).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get grade history"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
 }
 
 func ViewGradesHandler(ctx *gin.Context) {
@@ -446,3 +1041,175 @@ func ViewGradesHandler(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, grades)
 }
+
+// ScoreLessonHandler records a grade for work that is not handed in through the
+// system, such as classwork or an exam. An existing submission is regraded instead.
+func ScoreLessonHandler(ctx *gin.Context) {
+	var req struct {
+		UserID  uint                  `json:"userID" binding:"required"`
+		Points  int                   `json:"points"`
+		Comment string                `json:"comment"`
+		Scores  []homework.ScoreInput `json:"scores"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Points < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	enrolled, err := attendance.EnrolledStudents(db.DB, lesson)
+	if err != nil {
+		logger.Log("Failed to get enrolled students: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get enrolled students"})
+		return
+	}
+
+	if !slices.Contains(enrolled, req.UserID) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The student is not enrolled in this course"})
+		return
+	}
+
+	submission := models.UsersHomework{UserID: req.UserID, LessonID: lesson.ID, Version: 1}
+	err = db.DB.Where("lesson_id = ? AND user_id = ?", lesson.ID, req.UserID).First(&submission).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Log("Failed to get homework: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get homework"})
+		return
+	}
+
+	// запись без сдачи создаётся только вместе с оценкой (recordGrade)
+	if !applyGrade(ctx, &submission, lesson, req.Points, req.Comment, req.Scores) {
+		return
+	}
+
+	err = recordGrade(ctx, &submission, homework.GradeKindGrade)
+	if errors.Is(err, homework.ErrClaimed) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Log("Failed to save score: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save score"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, submission)
+}
+
+// applyGrade sets the grade on a submission: the given points, or the rubric total
+// when a rubric applies to the lesson. It answers the request itself on failure.
+func applyGrade(ctx *gin.Context, submission *models.UsersHomework, lesson models.Lesson, points int, comment string, scores []homework.ScoreInput) bool {
+	if status, err := scoreSubmission(submission, lesson, points, comment, scores); err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// scoreSubmission does the work of applyGrade and reports failures as a status and
+// a client-facing error instead of answering the request.
+func scoreSubmission(submission *models.UsersHomework, lesson models.Lesson, points int, comment string, scores []homework.ScoreInput) (int, error) {
+	rubric, err := homework.RubricFor(db.DB, lesson)
+	if err != nil {
+		logger.Log("Failed to get rubric: "+err.Error(), slog.LevelError)
+		return http.StatusInternalServerError, errors.New("Failed to get rubric")
+	}
+
+	// повторная оценка допускается, прежние остаются в истории
+	submission.Points = uint(points)
+	submission.Breakdown = nil
+
+	if rubric != nil {
+		breakdown, total, err := homework.Score(*rubric, scores)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		submission.Points = total
+		submission.Breakdown = breakdown
+	}
+
+	submission.EffectivePoints = homework.EffectivePoints(submission.Points, submission.PenaltyPercent)
+	submission.Comment = comment
+	submission.Checked = true
+	submission.Status = homework.StatusGraded
+
+	return http.StatusOK, nil
+}
+
+// recordGrade saves the homework's new state and appends it to the grade history; a
+// grade for work not handed in creates the record first. It fails with
+// homework.ErrClaimed when another teacher has claimed the homework.
+func recordGrade(ctx *gin.Context, submission *models.UsersHomework, kind string) error {
+	user := endpoints.SessionUser(ctx)
+
+	now := time.Now()
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if submission.ID == 0 {
+			if err := tx.Omit("User", "Lesson", "Files").Create(submission).Error; err != nil {
+				return err
+			}
+		}
+		// оценённая или возвращённая работа уходит из очереди вместе с бронью
+		if err := homework.SaveGrade(tx, submission, user.ID, now); err != nil {
+			return err
+		}
+		return tx.Create(&models.GradeRecord{
+			HomeworkID:      submission.ID,
+			Version:         submission.Version,
+			Kind:            kind,
+			Points:          submission.Points,
+			EffectivePoints: submission.EffectivePoints,
+			Comment:         submission.Comment,
+			Breakdown:       submission.Breakdown,
+			GradedBy:        user.ID,
+			GradedAt:        now,
+		}).Error
+	})
+}
+
+// allowsHomework responds and returns false when the lesson is graded by a quiz,
+// whose grade would clash with homework grades.
+func allowsHomework(ctx *gin.Context, lessonID uint) bool {
+	hasQuiz, err := quiz.Exists(db.DB, lessonID)
+	if err != nil {
+		logger.Log("Failed to check lesson quiz: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check lesson quiz"})
+		return false
+	}
+	if hasQuiz {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": quiz.ErrLessonHasQuiz.Error()})
+		return false
+	}
+	return true
+}
+
+// loadVisibleHomework lets the student who owns the homework and its teachers through.
+func loadVisibleHomework(ctx *gin.Context) (models.UsersHomework, bool) {
+	session := sessions.Default(ctx)
+	user, ok := session.Get("user").(dto.UserResponse)
+
+	var submission models.UsersHomework
+
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return submission, false
+	}
+
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&submission).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Homework not found"})
+		return submission, false
+	}
+
+	if submission.UserID == user.ID {
+		return submission, true
+	}
+
+	_, ok = endpoints.ManagedLesson(ctx, submission.LessonID)
+	return submission, ok
+}
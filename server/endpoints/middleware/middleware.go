@@ -28,6 +28,12 @@ func RateLimiter(limiter *rate.Limiter) gin.HandlerFunc {
 
 func ValidateUser(userRole string) gin.HandlerFunc {
 
+	return ValidateAnyUser(userRole)
+
+}
+
+func ValidateAnyUser(userRoles ...string) gin.HandlerFunc {
+
 	return func(c *gin.Context) {
 
 		session := sessions.Default(c)
@@ -46,15 +52,15 @@ func ValidateUser(userRole string) gin.HandlerFunc {
 			return
 		}
 
-		if user.Role == userRole {
-			c.Next()
-
-		} else {
-			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden"})
-			return
-
+		for _, role := range userRoles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
 		}
 
+		c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden"})
+
 	}
 
 }
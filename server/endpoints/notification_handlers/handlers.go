@@ -0,0 +1,64 @@
+package notification_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/logger"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+func GetNotificationsHandler(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	user, ok := session.Get("user").(dto.UserResponse)
+
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	query := db.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(100)
+
+	if ctx.Query("unread") == "true" {
+		query = query.Where("read = ?", false)
+	}
+
+	var notifications []models.Notification
+
+	if err := query.Find(&notifications).Error; err != nil {
+		logger.Log("Failed to get notifications: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, notifications)
+}
+
+func MarkNotificationReadHandler(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	user, ok := session.Get("user").(dto.UserResponse)
+
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	query := db.DB.Model(&models.Notification{}).Where("user_id = ?", user.ID)
+
+	// "all" отмечает прочитанными все уведомления пользователя
+	if id := ctx.Param("id"); id != "all" {
+		query = query.Where("id = ?", id)
+	}
+
+	if err := query.Update("read", true).Error; err != nil {
+		logger.Log("Failed to mark notification as read: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Notification marked as read"})
+}
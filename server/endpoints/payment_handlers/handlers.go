@@ -0,0 +1,278 @@
+package payment_handlers
+
+import (
+	"codev_erp/billing"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/logger"
+	"codev_erp/payments"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func CreateCheckoutHandler(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	user, ok := session.Get("user").(dto.UserResponse)
+
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		EnrollmentID uint `json:"enrollment_id"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.EnrollmentID == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var enrollment models.EnrolledCourse
+
+	err := db.DB.Preload("Installments").Preload("Course").Preload("User").
+		Where("id = ?", req.EnrollmentID).First(&enrollment).Error
+
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found"})
+		return
+	}
+
+	if user.Role != "admin" && enrollment.UserID != user.ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	amount := billing.DueAmount(enrollment.Installments, time.Now())
+
+	if amount <= 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to pay for this enrollment"})
+		return
+	}
+
+	provider, err := payments.Default()
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Payment provider is not configured"})
+		return
+	}
+
+	payment := models.Payment{
+		Reference:    uuid.New().String(),
+		EnrollmentID: enrollment.ID,
+		Provider:     provider.Name(),
+		Amount:       amount,
+		Currency:     enrollment.Currency,
+	}
+
+	checkout, err := provider.CreateCheckout(payments.Checkout{
+		Reference:   payment.Reference,
+		Amount:      amount,
+		Currency:    enrollment.Currency,
+		Description: enrollment.Course.Name,
+		Email:       enrollment.User.Email,
+	})
+
+	if err != nil {
+		logger.Log("Failed to create checkout with "+provider.Name()+": "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create checkout"})
+		return
+	}
+
+	payment.ProviderRef = checkout.ProviderRef
+	payment.CheckoutURL = checkout.URL
+
+	if err := db.DB.Create(&payment).Error; err != nil {
+		logger.Log("Failed to save payment: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create checkout"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payment)
+}
+
+var errNotPending = errors.New("payment is not pending")
+
+// WebhookHandler receives signed notifications from a provider and books the ledger.
+// Redelivered events are acknowledged without being booked twice.
+func WebhookHandler(ctx *gin.Context) {
+	provider, err := payments.Get(ctx.Param("provider"))
+
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+
+	event, err := provider.ParseWebhook(ctx.Request)
+
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		logger.Log("Rejected "+provider.Name()+" webhook with invalid signature from IP: "+ctx.ClientIP(), slog.LevelError)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	if err != nil || event.ID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+		return
+	}
+
+	var payment models.Payment
+
+	if err := db.DB.Where("reference = ? AND provider = ?", event.Reference, provider.Name()).First(&payment).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	var booked int64
+	db.DB.Model(&models.LedgerEntry{}).Where("external_id = ?", event.ID).Count(&booked)
+
+	if booked > 0 {
+		ctx.JSON(http.StatusOK, gin.H{"success": "Event already processed"})
+		return
+	}
+
+	switch event.Type {
+	case payments.EventSucceeded:
+		if event.Currency != payment.Currency {
+			logger.Log("Webhook currency mismatch for payment "+payment.Reference, slog.LevelError)
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Currency mismatch"})
+			return
+		}
+
+		if event.Amount != payment.Amount {
+			logger.Log("Webhook amount mismatch for payment "+payment.Reference, slog.LevelError)
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Amount mismatch"})
+			return
+		}
+
+		err = db.DB.Transaction(func(tx *gorm.DB) error {
+			// проводим только ожидающий платёж, даже если событие подписано
+			result := tx.Model(&payment).Where("status = ?", "pending").Update("status", "succeeded")
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errNotPending
+			}
+
+			return billing.RecordPayment(tx, &models.LedgerEntry{
+				EnrollmentID: payment.EnrollmentID,
+				PaymentID:    &payment.ID,
+				Kind:         billing.LedgerPayment,
+				Amount:       event.Amount,
+				Currency:     event.Currency,
+				Provider:     provider.Name(),
+				ExternalID:   &event.ID,
+			}, time.Now())
+		})
+
+	case payments.EventFailed:
+		err = db.DB.Model(&payment).Where("status = ?", "pending").Update("status", "failed").Error
+	}
+
+	if errors.Is(err, errNotPending) {
+		// провайдер повторяет недоставленные события, поэтому подтверждаем без проводки
+		logger.Log("Ignored webhook for payment "+payment.Reference+" that is no longer pending", slog.LevelWarn)
+		ctx.JSON(http.StatusOK, gin.H{"success": "Payment is no longer pending"})
+		return
+	}
+
+	if err != nil {
+		logger.Log("Failed to reconcile payment "+payment.Reference+": "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Webhook processed"})
+}
+
+var fakeCheckoutPage = template.Must(template.New("checkout").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>Fake checkout</title></head>
+<body style="font-family: sans-serif; max-width: 420px; margin: 80px auto">
+<h2>Fake payment provider</h2>
+<p>Pay <b>{{.Amount}} {{.Currency}}</b> (reference {{.Reference}})</p>
+<form method="post">
+<button name="outcome" value="succeeded">Pay</button>
+<button name="outcome" value="failed">Decline</button>
+</form>
+</body></html>`))
+
+// FakeCheckoutPageHandler serves the development checkout page of the fake provider.
+func FakeCheckoutPageHandler(ctx *gin.Context) {
+	var payment models.Payment
+
+	if err := db.DB.Where("reference = ? AND provider = ?", ctx.Param("reference"), "fake").First(&payment).Error; err != nil {
+		ctx.String(http.StatusNotFound, "Payment not found")
+		return
+	}
+
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	_ = fakeCheckoutPage.Execute(ctx.Writer, payment)
+}
+
+// FakeCheckoutCompleteHandler plays the provider's part: it sends the signed webhook.
+func FakeCheckoutCompleteHandler(ctx *gin.Context) {
+	var payment models.Payment
+
+	if err := db.DB.Where("reference = ? AND provider = ?", ctx.Param("reference"), "fake").First(&payment).Error; err != nil {
+		ctx.String(http.StatusNotFound, "Payment not found")
+		return
+	}
+
+	if payment.Status != "pending" {
+		ctx.String(http.StatusConflict, "Payment is already "+payment.Status)
+		return
+	}
+
+	provider, _ := payments.Get("fake")
+
+	eventType := payments.EventSucceeded
+	if ctx.PostForm("outcome") == "failed" {
+		eventType = payments.EventFailed
+	}
+
+	err := provider.(*payments.FakeProvider).Complete(payments.Event{
+		Type:        eventType,
+		Reference:   payment.Reference,
+		ProviderRef: payment.ProviderRef,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+	})
+
+	if err != nil {
+		logger.Log("Fake provider failed to deliver webhook: "+err.Error(), slog.LevelError)
+		ctx.String(http.StatusBadGateway, "Failed to deliver webhook: "+err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, "Payment "+eventType)
+}
+
+//Administrator-specific handlers
+
+func GetLedgerHandler(ctx *gin.Context) {
+	query := db.DB.Order("created_at DESC")
+
+	if enrollmentId := ctx.Query("enrollmentId"); enrollmentId != "" {
+		query = query.Where("enrollment_id = ?", enrollmentId)
+	}
+
+	var entries []models.LedgerEntry
+
+	if err := query.Find(&entries).Error; err != nil {
+		logger.Log("Failed to get ledger: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get ledger"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
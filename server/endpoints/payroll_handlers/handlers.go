@@ -0,0 +1,310 @@
+package payroll_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/endpoints"
+	"codev_erp/logger"
+	"codev_erp/money"
+	"codev_erp/payroll"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetStatementsHandler(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	user, ok := session.Get("user").(dto.UserResponse)
+
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	query := db.DB.Preload("Teacher").Order("period DESC, teacher_id")
+
+	switch user.Role {
+	case "admin":
+		if teacherId := ctx.Query("teacherId"); teacherId != "" {
+			query = query.Where("teacher_id = ?", teacherId)
+		}
+	case "teacher":
+		query = query.Where("teacher_id = ?", user.ID)
+	default:
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if period := ctx.Query("period"); period != "" {
+		query = query.Where("period = ?", period)
+	}
+
+	var statements []models.PayrollStatement
+
+	if err := query.Find(&statements).Error; err != nil {
+		logger.Log("Failed to get payroll statements: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payroll statements"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, statements)
+}
+
+func GetStatementHandler(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	user, ok := session.Get("user").(dto.UserResponse)
+
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var statement models.PayrollStatement
+
+	err := db.DB.Preload("Teacher").Preload("Lines").Preload("AdjustmentItems").
+		Where("id = ?", ctx.Param("id")).First(&statement).Error
+
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Statement not found"})
+		return
+	}
+
+	if user.Role != "admin" && !(user.Role == "teacher" && statement.TeacherID == user.ID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, statement)
+}
+
+//Administrator-specific handlers
+
+func GetRatesHandler(ctx *gin.Context) {
+	query := db.DB.Preload("Course").Order("teacher_id, valid_from DESC")
+
+	if teacherId := ctx.Query("teacherId"); teacherId != "" {
+		query = query.Where("teacher_id = ?", teacherId)
+	}
+
+	var rates []models.PayRate
+
+	if err := query.Find(&rates).Error; err != nil {
+		logger.Log("Failed to get pay rates: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get pay rates"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rates)
+}
+
+func AddRateHandler(ctx *gin.Context) {
+	var req struct {
+		TeacherID  uint         `json:"teacherID"`
+		CourseID   *uint        `json:"courseID"`
+		Kind       string       `json:"kind"`
+		Amount     money.Amount `json:"amount"`
+		Currency   string       `json:"currency"`
+		ValidFrom  *time.Time   `json:"validFrom"`
+		ValidUntil *time.Time   `json:"validUntil"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.TeacherID == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if !payroll.ValidRateKind(req.Kind) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be per_lesson, per_hour or per_student"})
+		return
+	}
+
+	if req.Amount <= 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+		return
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	if !money.ValidCurrency(currency) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency"})
+		return
+	}
+
+	var teacher models.User
+	if err := db.DB.Where("id = ? AND role = ?", req.TeacherID, "teacher").First(&teacher).Error; err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Teacher not found"})
+		return
+	}
+
+	err := payroll.CheckCurrency(db.DB, req.TeacherID, currency)
+	if errors.Is(err, payroll.ErrMixedCurrency) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Log("Failed to check pay rate currency: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pay rate"})
+		return
+	}
+
+	rate := models.PayRate{
+		TeacherID:  req.TeacherID,
+		CourseID:   req.CourseID,
+		Kind:       req.Kind,
+		Amount:     req.Amount,
+		Currency:   currency,
+		ValidFrom:  time.Now(),
+		ValidUntil: req.ValidUntil,
+	}
+
+	if req.ValidFrom != nil {
+		rate.ValidFrom = *req.ValidFrom
+	}
+
+	if err := db.DB.Create(&rate).Error; err != nil {
+		logger.Log("Failed to create pay rate: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pay rate"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, rate)
+}
+
+func DeleteRateHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rate ID"})
+		return
+	}
+
+	if err := db.DB.Delete(&models.PayRate{}, id).Error; err != nil {
+		logger.Log("Failed to delete pay rate: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pay rate"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Pay rate deleted successfully"})
+}
+
+func RunPayrollHandler(ctx *gin.Context) {
+	var req struct {
+		Period    string `json:"period"`
+		TeacherID uint   `json:"teacherID"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var statements []models.PayrollStatement
+	var err error
+
+	if req.TeacherID != 0 {
+		var statement models.PayrollStatement
+		statement, err = payroll.Compute(req.TeacherID, req.Period)
+		statements = append(statements, statement)
+	} else {
+		statements, err = payroll.Run(req.Period)
+	}
+
+	switch {
+	case errors.Is(err, payroll.ErrInvalidPeriod), errors.Is(err, payroll.ErrLocked), errors.Is(err, payroll.ErrMixedCurrency):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logger.Log("Failed to run payroll for "+req.Period+": "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run payroll"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, statements)
+}
+
+func AddAdjustmentHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var req struct {
+		Amount money.Amount `json:"amount"`
+		Reason string       `json:"reason"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Amount == 0 || strings.TrimSpace(req.Reason) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Amount and reason are required"})
+		return
+	}
+
+	var statement models.PayrollStatement
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", ctx.Param("id")).First(&statement).Error; err != nil {
+			return err
+		}
+
+		if statement.Status == payroll.StatusLocked {
+			return payroll.ErrLocked
+		}
+
+		adjustment := models.PayrollAdjustment{
+			StatementID: statement.ID,
+			Amount:      req.Amount,
+			Reason:      req.Reason,
+			CreatedBy:   user.ID,
+		}
+
+		if err := tx.Create(&adjustment).Error; err != nil {
+			return err
+		}
+
+		return payroll.Recalculate(tx, &statement)
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Statement not found"})
+		return
+	case errors.Is(err, payroll.ErrLocked):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logger.Log("Failed to add payroll adjustment: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add adjustment"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, statement)
+}
+
+func LockStatementHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	now := time.Now()
+
+	res := db.DB.Model(&models.PayrollStatement{}).
+		Where("id = ? AND status = ?", ctx.Param("id"), payroll.StatusDraft).
+		Updates(map[string]interface{}{"status": payroll.StatusLocked, "locked_at": now, "locked_by": user.ID})
+
+	if res.Error != nil {
+		logger.Log("Failed to lock payroll statement: "+res.Error.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock statement"})
+		return
+	}
+
+	if res.RowsAffected == 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Statement not found or already locked"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Statement locked successfully"})
+}
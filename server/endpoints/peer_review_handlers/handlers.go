@@ -0,0 +1,271 @@
+package peer_review_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/endpoints"
+	"codev_erp/homework"
+	"codev_erp/logger"
+	"codev_erp/peerreview"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// reviewView is a review as shown to students: the reviewer is never included,
+// and the reviewed student's name is not either.
+type reviewView struct {
+	ID          uint                    `json:"id"`
+	LessonID    uint                    `json:"lessonID"`
+	LessonName  string                  `json:"lessonName"`
+	Files       []string                `json:"files,omitempty"`
+	Status      string                  `json:"status"`
+	Points      uint                    `json:"points"`
+	Breakdown   []models.CriterionScore `json:"breakdown"`
+	Comment     string                  `json:"comment"`
+	SubmittedAt *time.Time              `json:"submittedAt"`
+	ReviewerID  *uint                   `json:"reviewerID,omitempty"` // только для преподавателя
+}
+
+func view(review models.PeerReview, withFiles bool) reviewView {
+	v := reviewView{
+		ID:          review.ID,
+		LessonID:    review.Homework.LessonID,
+		LessonName:  review.Homework.Lesson.Name,
+		Status:      review.Status,
+		Points:      review.Points,
+		Breakdown:   review.Breakdown,
+		Comment:     review.Comment,
+		SubmittedAt: review.SubmittedAt,
+	}
+	if withFiles {
+		for _, file := range review.Homework.Files {
+			v.Files = append(v.Files, file.OriginalName)
+		}
+	}
+	return v
+}
+
+// loadOwnReview loads :id as a review assigned to the current student.
+func loadOwnReview(ctx *gin.Context) (models.PeerReview, bool) {
+	var review models.PeerReview
+	err := db.DB.Preload("Homework.Lesson").Preload("Homework.Files").
+		Where("id = ? AND reviewer_id = ?", ctx.Param("id"), endpoints.SessionUser(ctx).ID).
+		First(&review).Error
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return review, false
+	}
+	return review, true
+}
+
+// SetPeerReviewHandler turns peer review on for a lesson with {"count": N} reviews
+// per submission, or off with 0. Reviews are handed out after the homework deadline.
+func SetPeerReviewHandler(ctx *gin.Context) {
+	var req struct {
+		Count uint `json:"count"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	if lesson.PeerReviewsAssignedAt != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": peerreview.ErrAlreadyAssigned.Error()})
+		return
+	}
+
+	if err := db.DB.Model(&lesson).Update("peer_review_count", req.Count).Error; err != nil {
+		logger.Log("Failed to update peer review: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update peer review"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Peer review updated successfully"})
+}
+
+// AssignPeerReviewsHandler hands out the reviews right away, without waiting for the deadline.
+func AssignPeerReviewsHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	var reviews []models.PeerReview
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		reviews, err = peerreview.Assign(tx, lesson, time.Now())
+		return err
+	})
+
+	if errors.Is(err, peerreview.ErrNotEnabled) || errors.Is(err, peerreview.ErrAlreadyAssigned) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Log("Failed to assign peer reviews: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign peer reviews"})
+		return
+	}
+
+	peerreview.NotifyReviewers(lesson, reviews)
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Peer reviews assigned", "assigned": len(reviews)})
+}
+
+// GetMyPeerReviewsHandler lists the reviews assigned to the current student.
+func GetMyPeerReviewsHandler(ctx *gin.Context) {
+	var reviews []models.PeerReview
+	err := db.DB.Preload("Homework.Lesson").
+		Where("reviewer_id = ?", endpoints.SessionUser(ctx).ID).
+		Order("assigned_at DESC, id").
+		Find(&reviews).Error
+	if err != nil {
+		logger.Log("Failed to get peer reviews: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get peer reviews"})
+		return
+	}
+
+	views := make([]reviewView, 0, len(reviews))
+	for _, review := range reviews {
+		views = append(views, view(review, false))
+	}
+
+	ctx.JSON(http.StatusOK, views)
+}
+
+// GetPeerReviewHandler returns one assigned review with the work's file names and
+// the rubric to fill in.
+func GetPeerReviewHandler(ctx *gin.Context) {
+	review, ok := loadOwnReview(ctx)
+	if !ok {
+		return
+	}
+
+	rubric, err := homework.RubricFor(db.DB, review.Homework.Lesson)
+	if err != nil {
+		logger.Log("Failed to get rubric: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rubric"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"review": view(review, true), "rubric": rubric, "maxPoints": review.Homework.Lesson.MaxPoints})
+}
+
+// DownloadPeerReviewFileHandler serves a file of the reviewed work by its original
+// name; storage keys are not shown to the reviewer.
+func DownloadPeerReviewFileHandler(ctx *gin.Context) {
+	review, ok := loadOwnReview(ctx)
+	if !ok {
+		return
+	}
+
+	name := ctx.Param("name")
+	for _, file := range review.Homework.Files {
+		if file.OriginalName == name {
+			endpoints.ServeFile(ctx, file.Key, filepath.Base(name))
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+}
+
+// SubmitPeerReviewHandler saves the review; it can be revised until the teacher grades the work.
+func SubmitPeerReviewHandler(ctx *gin.Context) {
+	var req struct {
+		Points  uint                  `json:"points"`
+		Comment string                `json:"comment"`
+		Scores  []homework.ScoreInput `json:"scores"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	review, ok := loadOwnReview(ctx)
+	if !ok {
+		return
+	}
+
+	if review.Homework.Status == homework.StatusGraded {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The work has already been graded"})
+		return
+	}
+
+	points, breakdown, err := peerreview.Grade(db.DB, review.Homework.Lesson, req.Points, req.Scores)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	review.Status = peerreview.StatusSubmitted
+	review.Points = points
+	review.Breakdown = breakdown
+	review.Comment = req.Comment
+	review.SubmittedAt = &now
+
+	if err := db.DB.Omit("Homework", "Reviewer").Save(&review).Error; err != nil {
+		logger.Log("Failed to save peer review: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save peer review"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, view(review, false))
+}
+
+// GetReceivedPeerReviewsHandler shows the reviews a submission received. The student
+// sees submitted reviews without reviewers; teachers see every review and who wrote it.
+func GetReceivedPeerReviewsHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var submission models.UsersHomework
+	if err := db.DB.Preload("Lesson").Where("id = ?", ctx.Param("id")).First(&submission).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Homework not found"})
+		return
+	}
+
+	teacher := endpoints.CanManageLesson(user, submission.Lesson)
+	if !teacher && submission.UserID != user.ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	query := db.DB.Where("homework_id = ?", submission.ID)
+	if !teacher {
+		query = query.Where("status = ?", peerreview.StatusSubmitted)
+	}
+
+	var reviews []models.PeerReview
+	if err := query.Order("id").Find(&reviews).Error; err != nil {
+		logger.Log("Failed to get peer reviews: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get peer reviews"})
+		return
+	}
+
+	views := make([]reviewView, 0, len(reviews))
+	for _, review := range reviews {
+		review.Homework = submission
+		v := view(review, false)
+		if teacher {
+			reviewer := review.ReviewerID
+			v.ReviewerID = &reviewer
+		}
+		views = append(views, v)
+	}
+
+	ctx.JSON(http.StatusOK, views)
+}
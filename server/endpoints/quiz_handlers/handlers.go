@@ -0,0 +1,371 @@
+package quiz_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/endpoints"
+	"codev_erp/homework"
+	"codev_erp/logger"
+	"codev_erp/quiz"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type questionRequest struct {
+	Kind          string              `json:"kind" binding:"required"`
+	Text          string              `json:"text" binding:"required"`
+	Code          string              `json:"code"`
+	Points        uint                `json:"points"`
+	Options       []models.QuizOption `json:"options"`
+	Answers       []string            `json:"answers"`
+	CaseSensitive bool                `json:"caseSensitive"`
+	Number        *float64            `json:"number"`
+	Tolerance     float64             `json:"tolerance"`
+}
+
+type quizRequest struct {
+	Title            string            `json:"title" binding:"required"`
+	Description      string            `json:"description"`
+	TimeLimitMinutes uint              `json:"timeLimitMinutes"`
+	MaxAttempts      uint              `json:"maxAttempts"`
+	ShuffleQuestions bool              `json:"shuffleQuestions"`
+	ShuffleOptions   bool              `json:"shuffleOptions"`
+	QuestionCount    uint              `json:"questionCount"`
+	OpensAt          *time.Time        `json:"opensAt"`
+	ClosesAt         *time.Time        `json:"closesAt"`
+	Questions        []questionRequest `json:"questions" binding:"required,min=1,dive"`
+}
+
+type answersRequest struct {
+	Answers []models.QuizAnswer `json:"answers"`
+}
+
+// attemptView is an attempt with its questions as the student sees them.
+type attemptView struct {
+	models.QuizAttempt
+	Questions []quiz.PaperQuestion `json:"questions"`
+}
+
+func loadLesson(ctx *gin.Context) (models.Lesson, bool) {
+	var lesson models.Lesson
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		return lesson, false
+	}
+	return lesson, true
+}
+
+// loadAttempt loads :id with its quiz and lesson. The student who owns the attempt
+// and the lesson's teachers are let through.
+func loadAttempt(ctx *gin.Context) (models.QuizAttempt, bool) {
+	var attempt models.QuizAttempt
+	err := db.DB.
+		Preload("Quiz.Questions", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Quiz.Lesson").
+		Where("id = ?", ctx.Param("id")).
+		First(&attempt).Error
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Attempt not found"})
+		return attempt, false
+	}
+
+	user := endpoints.SessionUser(ctx)
+	if attempt.UserID != user.ID && !endpoints.CanManageLesson(user, attempt.Quiz.Lesson) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return attempt, false
+	}
+
+	return attempt, true
+}
+
+func view(attempt models.QuizAttempt) attemptView {
+	return attemptView{QuizAttempt: attempt, Questions: quiz.Paper(attempt.Quiz, attempt)}
+}
+
+func respondQuizError(ctx *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, quiz.ErrNotEnrolled):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, quiz.ErrNotOpen), errors.Is(err, quiz.ErrNoAttemptsLeft),
+		errors.Is(err, quiz.ErrAttemptFinished), errors.Is(err, quiz.ErrTimeUp):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logger.Log(message+": "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetQuizHandler returns the whole quiz with answers to its teachers. Students get
+// the settings and their own attempts; questions come with an attempt.
+func GetQuizHandler(ctx *gin.Context) {
+	lesson, ok := loadLesson(ctx)
+	if !ok {
+		return
+	}
+
+	q, err := quiz.Load(db.DB, lesson.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "The lesson has no quiz"})
+		return
+	}
+
+	user := endpoints.SessionUser(ctx)
+	if endpoints.CanManageLesson(user, lesson) {
+		ctx.JSON(http.StatusOK, q)
+		return
+	}
+
+	var attempts []models.QuizAttempt
+	if err := db.DB.Where("quiz_id = ? AND user_id = ?", q.ID, user.ID).Order("number").Find(&attempts).Error; err != nil {
+		logger.Log("Failed to get attempts: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attempts"})
+		return
+	}
+
+	q.Questions = nil
+	ctx.JSON(http.StatusOK, gin.H{"quiz": q, "attempts": attempts})
+}
+
+// SaveQuizHandler creates the lesson's quiz or replaces it with its questions. Once
+// students have attempted it the questions are fixed; delete the quiz to start over.
+func SaveQuizHandler(ctx *gin.Context) {
+	var req quizRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if req.OpensAt != nil && req.ClosesAt != nil && !req.ClosesAt.After(*req.OpensAt) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The quiz must close after it opens"})
+		return
+	}
+
+	questions := make([]models.QuizQuestion, 0, len(req.Questions))
+	for i, r := range req.Questions {
+		question := models.QuizQuestion{
+			Position:      uint(i),
+			Kind:          r.Kind,
+			Text:          r.Text,
+			Code:          r.Code,
+			Points:        r.Points,
+			Options:       r.Options,
+			Answers:       r.Answers,
+			CaseSensitive: r.CaseSensitive,
+			Number:        r.Number,
+			Tolerance:     r.Tolerance,
+		}
+		if err := quiz.ValidateQuestion(&question); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		questions = append(questions, question)
+	}
+
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	q := models.Quiz{LessonID: lesson.ID}
+	errAttempted := errors.New("students have already attempted this quiz; delete it to change the questions")
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("lesson_id = ?", lesson.ID).FirstOrInit(&q).Error; err != nil {
+			return err
+		}
+
+		if q.ID == 0 {
+			hasWork, err := homework.HasWork(tx, lesson.ID)
+			if err != nil {
+				return err
+			}
+			if hasWork {
+				return quiz.ErrLessonHasHomework
+			}
+		} else {
+			var attempts int64
+			if err := tx.Model(&models.QuizAttempt{}).Where("quiz_id = ?", q.ID).Count(&attempts).Error; err != nil {
+				return err
+			}
+			if attempts > 0 {
+				return errAttempted
+			}
+		}
+
+		q.Title = req.Title
+		q.Description = req.Description
+		q.TimeLimitMinutes = req.TimeLimitMinutes
+		q.MaxAttempts = req.MaxAttempts
+		q.ShuffleQuestions = req.ShuffleQuestions
+		q.ShuffleOptions = req.ShuffleOptions
+		q.QuestionCount = req.QuestionCount
+		q.OpensAt = req.OpensAt
+		q.ClosesAt = req.ClosesAt
+
+		if err := tx.Omit("Questions").Save(&q).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("quiz_id = ?", q.ID).Delete(&models.QuizQuestion{}).Error; err != nil {
+			return err
+		}
+
+		for i := range questions {
+			questions[i].QuizID = q.ID
+		}
+		q.Questions = questions
+		return tx.Create(&q.Questions).Error
+	})
+
+	if errors.Is(err, errAttempted) || errors.Is(err, quiz.ErrLessonHasHomework) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Log("Failed to save quiz: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quiz"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, q)
+}
+
+// DeleteQuizHandler removes the quiz with its attempts. Grades already given stay.
+func DeleteQuizHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	if err := db.DB.Where("lesson_id = ?", lesson.ID).Delete(&models.Quiz{}).Error; err != nil {
+		logger.Log("Failed to delete quiz: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quiz"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Quiz deleted successfully"})
+}
+
+// GetQuizAttemptsHandler lists every attempt at the lesson's quiz for its teachers.
+func GetQuizAttemptsHandler(ctx *gin.Context) {
+	lesson, ok := endpoints.ManagedLesson(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	var attempts []models.QuizAttempt
+	err := db.DB.Preload("User").
+		Where("quiz_id IN (?)", db.DB.Model(&models.Quiz{}).Where("lesson_id = ?", lesson.ID).Select("id")).
+		Order("user_id, number").
+		Find(&attempts).Error
+	if err != nil {
+		logger.Log("Failed to get attempts: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attempts"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, attempts)
+}
+
+// StartQuizAttemptHandler starts an attempt, or resumes the one still running.
+func StartQuizAttemptHandler(ctx *gin.Context) {
+	lesson, ok := loadLesson(ctx)
+	if !ok {
+		return
+	}
+
+	q, err := quiz.Load(db.DB, lesson.ID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "The lesson has no quiz"})
+		return
+	}
+
+	var attempt models.QuizAttempt
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		attempt, err = quiz.Start(tx, q, lesson, endpoints.SessionUser(ctx).ID, time.Now())
+		return err
+	})
+	if err != nil {
+		respondQuizError(ctx, err, "Failed to start attempt")
+		return
+	}
+
+	attempt.Quiz = q
+	ctx.JSON(http.StatusOK, view(attempt))
+}
+
+// GetQuizAttemptHandler shows an attempt to its student or to the lesson's teachers.
+func GetQuizAttemptHandler(ctx *gin.Context) {
+	attempt, ok := loadAttempt(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, view(attempt))
+}
+
+// SaveQuizAnswersHandler autosaves the answers of a running attempt.
+func SaveQuizAnswersHandler(ctx *gin.Context) {
+	var req answersRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	attempt, ok := loadAttempt(ctx)
+	if !ok {
+		return
+	}
+
+	if attempt.UserID != endpoints.SessionUser(ctx).ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := quiz.SaveAnswers(db.DB, &attempt, req.Answers, time.Now()); err != nil {
+		respondQuizError(ctx, err, "Failed to save answers")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Answers saved"})
+}
+
+// SubmitQuizAttemptHandler finishes the attempt, scores it and records the grade.
+func SubmitQuizAttemptHandler(ctx *gin.Context) {
+	var req answersRequest
+
+	// тело можно не присылать: тогда оцениваются сохранённые ответы
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+	}
+
+	attempt, ok := loadAttempt(ctx)
+	if !ok {
+		return
+	}
+
+	if attempt.UserID != endpoints.SessionUser(ctx).ID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return quiz.Submit(tx, attempt.Quiz, attempt.Quiz.Lesson, &attempt, req.Answers, time.Now())
+	})
+	if err != nil {
+		respondQuizError(ctx, err, "Failed to submit attempt")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, view(attempt))
+}
@@ -0,0 +1,176 @@
+package report_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/dto"
+	"codev_erp/logger"
+	"codev_erp/money"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Administrator-specific handlers
+
+// GetRevenueHandler reports money collected (ledger entries) per month, course or teacher.
+func GetRevenueHandler(ctx *gin.Context) {
+	from, to, ok := parseRange(ctx)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from and to must look like 2026-01"})
+		return
+	}
+
+	var key string
+
+	switch ctx.DefaultQuery("groupBy", "month") {
+	case "month":
+		key = "to_char(ledger_entries.created_at, 'YYYY-MM')"
+	case "course":
+		key = "courses.name"
+	case "teacher":
+		key = "COALESCE(users.first_name || ' ' || users.last_name, 'No teacher')"
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "groupBy must be month, course or teacher"})
+		return
+	}
+
+	var rows []dto.RevenueRow
+
+	err := db.DB.Table("ledger_entries").
+		Joins("JOIN enrolled_courses ON enrolled_courses.id = ledger_entries.enrollment_id").
+		Joins("JOIN courses ON courses.id = enrolled_courses.course_id").
+		Joins("LEFT JOIN users ON users.id = courses.teacher_id").
+		Where("ledger_entries.created_at >= ? AND ledger_entries.created_at < ?", from, to).
+		Select(key + " AS \"group\", ledger_entries.currency, SUM(ledger_entries.amount) AS amount, COUNT(*) AS payments").
+		Group(key + ", ledger_entries.currency").
+		Order("\"group\"").
+		Scan(&rows).Error
+
+	if err != nil {
+		logger.Log("Failed to build revenue report: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build revenue report"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rows)
+}
+
+// GetReceivablesHandler reports what students still owe, aged by days past the due date.
+// Totals are per currency, AZN unless ?currency= is given.
+func GetReceivablesHandler(ctx *gin.Context) {
+	now := time.Now()
+	currency := ctx.DefaultQuery("currency", money.DefaultCurrency)
+
+	var rows []dto.ReceivableRow
+
+	err := db.DB.Table("installments").
+		Joins("JOIN enrolled_courses ON enrolled_courses.id = installments.enrollment_id").
+		Joins("JOIN users ON users.id = enrolled_courses.user_id").
+		Joins("JOIN courses ON courses.id = enrolled_courses.course_id").
+		Where("installments.paid = ? AND installments.due_date <= ?", false, now).
+		Where("installments.currency = ?", currency).
+		Select("enrolled_courses.id AS enrollment_id, users.id AS student_id, users.first_name, users.last_name, " +
+			"courses.name AS course_name, installments.currency, installments.due_date, " +
+			"installments.amount - installments.paid_amount AS amount").
+		Order("installments.due_date").
+		Scan(&rows).Error
+
+	if err != nil {
+		logger.Log("Failed to build receivables report: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build receivables report"})
+		return
+	}
+
+	var current money.Amount
+	db.DB.Table("installments").
+		Joins("JOIN enrolled_courses ON enrolled_courses.id = installments.enrollment_id").
+		Where("installments.paid = ? AND installments.due_date > ?", false, now).
+		Where("installments.currency = ?", currency).
+		Select("COALESCE(SUM(installments.amount - installments.paid_amount), 0)").
+		Scan(&current)
+
+	report := dto.ReceivablesResponse{Currency: currency, NotYetDue: current, Rows: rows}
+
+	for i, row := range rows {
+		days := int(now.Sub(row.DueDate).Hours() / 24)
+		rows[i].DaysPastDue = days
+
+		switch {
+		case days <= 30:
+			rows[i].Bucket = "0-30"
+			report.Days0To30 += row.Amount
+		case days <= 60:
+			rows[i].Bucket = "31-60"
+			report.Days31To60 += row.Amount
+		default:
+			rows[i].Bucket = "60+"
+			report.Days60Plus += row.Amount
+		}
+
+		report.TotalPastDue += row.Amount
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// GetProjectedRevenueHandler reports the unpaid installments of active enrollments
+// that fall due in the coming months.
+func GetProjectedRevenueHandler(ctx *gin.Context) {
+	months, err := strconv.Atoi(ctx.DefaultQuery("months", "6"))
+	if err != nil || months <= 0 || months > 36 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "months must be between 1 and 36"})
+		return
+	}
+
+	now := time.Now()
+	until := now.AddDate(0, months, 0)
+
+	var rows []dto.RevenueRow
+
+	err = db.DB.Table("installments").
+		Joins("JOIN enrolled_courses ON enrolled_courses.id = installments.enrollment_id").
+		Where("enrolled_courses.end_date >= ?", now).
+		Where("installments.paid = ? AND installments.due_date > ? AND installments.due_date <= ?", false, now, until).
+		Select("to_char(installments.due_date, 'YYYY-MM') AS \"group\", installments.currency, " +
+			"SUM(installments.amount - installments.paid_amount) AS amount, COUNT(*) AS payments").
+		Group("to_char(installments.due_date, 'YYYY-MM'), installments.currency").
+		Order("\"group\"").
+		Scan(&rows).Error
+
+	if err != nil {
+		logger.Log("Failed to build projected revenue report: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build projected revenue report"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rows)
+}
+
+// parseRange reads the from/to months of a report, defaulting to the current year.
+// The returned range is [first day of from, first day after to).
+func parseRange(ctx *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	from := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(1, 0, 0)
+
+	if value := ctx.Query("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01", value, time.Local)
+		if err != nil {
+			return from, to, false
+		}
+		from = parsed
+	}
+
+	if value := ctx.Query("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01", value, time.Local)
+		if err != nil {
+			return from, to, false
+		}
+		to = parsed.AddDate(0, 1, 0)
+	}
+
+	return from, to, true
+}
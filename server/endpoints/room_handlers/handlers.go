@@ -0,0 +1,361 @@
+package room_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/endpoints"
+	"codev_erp/logger"
+	"codev_erp/scheduling"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type roomRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Capacity uint   `json:"capacity"`
+	Location string `json:"location"`
+	Active   *bool  `json:"active"`
+}
+
+type resourceRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Kind   string `json:"kind" binding:"required"`
+	Active *bool  `json:"active"`
+}
+
+func GetRoomsHandler(ctx *gin.Context) {
+	var rooms []models.Room
+
+	if err := db.DB.Order("name").Find(&rooms).Error; err != nil {
+		logger.Log("Failed to get rooms: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rooms"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rooms)
+}
+
+func AddRoomHandler(ctx *gin.Context) {
+	var req roomRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	room := models.Room{Name: req.Name, Capacity: req.Capacity, Location: req.Location, Active: true}
+
+	if err := db.DB.Create(&room).Error; err != nil {
+		logger.Log("Failed to create room: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Room with this name already exists"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, room)
+}
+
+func UpdateRoomHandler(ctx *gin.Context) {
+	var req roomRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var room models.Room
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&room).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
+	room.Name = req.Name
+	room.Capacity = req.Capacity
+	room.Location = req.Location
+	if req.Active != nil {
+		room.Active = *req.Active
+	}
+
+	if err := db.DB.Save(&room).Error; err != nil {
+		logger.Log("Failed to update room: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, room)
+}
+
+func DeleteRoomHandler(ctx *gin.Context) {
+	if err := db.DB.Where("id = ?", ctx.Param("id")).Delete(&models.Room{}).Error; err != nil {
+		logger.Log("Failed to delete room: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Room deleted successfully"})
+}
+
+func GetResourcesHandler(ctx *gin.Context) {
+	var resources []models.Resource
+
+	if err := db.DB.Order("kind, name").Find(&resources).Error; err != nil {
+		logger.Log("Failed to get resources: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get resources"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resources)
+}
+
+func AddResourceHandler(ctx *gin.Context) {
+	var req resourceRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	resource := models.Resource{Name: req.Name, Kind: req.Kind, Active: true}
+
+	if err := db.DB.Create(&resource).Error; err != nil {
+		logger.Log("Failed to create resource: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Resource with this name already exists"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, resource)
+}
+
+func UpdateResourceHandler(ctx *gin.Context) {
+	var req resourceRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var resource models.Resource
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&resource).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		return
+	}
+
+	resource.Name = req.Name
+	resource.Kind = req.Kind
+	if req.Active != nil {
+		resource.Active = *req.Active
+	}
+
+	if err := db.DB.Save(&resource).Error; err != nil {
+		logger.Log("Failed to update resource: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update resource"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resource)
+}
+
+func DeleteResourceHandler(ctx *gin.Context) {
+	if err := db.DB.Where("id = ?", ctx.Param("id")).Delete(&models.Resource{}).Error; err != nil {
+		logger.Log("Failed to delete resource: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete resource"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Resource deleted successfully"})
+}
+
+// BookLessonHandler sets the room and resources of a lesson after checking that
+// nothing else holds them at that time.
+func BookLessonHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var req struct {
+		RoomID      *uint  `json:"roomID"`
+		ResourceIDs []uint `json:"resourceIDs"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var lesson models.Lesson
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(user, lesson.CourseID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return
+	}
+
+	var resources []models.Resource
+	if len(req.ResourceIDs) > 0 {
+		db.DB.Where("id IN ? AND active = ?", req.ResourceIDs, true).Find(&resources)
+		if len(resources) != len(req.ResourceIDs) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or inactive resource"})
+			return
+		}
+	}
+
+	if req.RoomID != nil {
+		var room models.Room
+		if err := db.DB.Where("id = ? AND active = ?", *req.RoomID, true).First(&room).Error; err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or inactive room"})
+			return
+		}
+	}
+
+	conflicts, err := scheduling.Conflicts(db.DB, scheduling.Booking{
+		LessonID:     lesson.ID,
+		CourseID:     lesson.CourseID,
+		Start:        lesson.StartDate,
+		Duration:     lesson.Duration,
+		RoomID:       req.RoomID,
+		ResourceIDs:  req.ResourceIDs,
+		SubstituteID: lesson.SubstituteID,
+	}, nil)
+	if err != nil {
+		logger.Log("Failed to check lesson conflicts: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to book lesson"})
+		return
+	}
+
+	// конфликты по преподавателю и группе не зависят от комнаты, их решают переносом
+	var blocking []scheduling.Conflict
+	for _, conflict := range conflicts {
+		if conflict.Kind == scheduling.ConflictRoom || conflict.Kind == scheduling.ConflictResource {
+			blocking = append(blocking, conflict)
+		}
+	}
+	if len(blocking) > 0 {
+		endpoints.RespondConflicts(ctx, blocking)
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&lesson).Update("room_id", req.RoomID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&lesson).Association("Resources").Replace(resources)
+	})
+	if err != nil {
+		logger.Log("Failed to book lesson: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to book lesson"})
+		return
+	}
+
+	db.DB.Preload("Room").Preload("Resources").Where("id = ?", lesson.ID).First(&lesson)
+	ctx.JSON(http.StatusOK, lesson)
+}
+
+// FreeBusyHandler lists the busy intervals of the requested rooms, resources and
+// teachers between from and to. Without filters it covers every active room.
+func FreeBusyHandler(ctx *gin.Context) {
+	from, errFrom := time.Parse(time.RFC3339, ctx.Query("from"))
+	to, errTo := time.Parse(time.RFC3339, ctx.Query("to"))
+	if errFrom != nil || errTo != nil || !to.After(from) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be RFC 3339 times, from before to"})
+		return
+	}
+
+	roomIDs := ids(ctx.QueryArray("roomId"))
+	resourceIDs := ids(ctx.QueryArray("resourceId"))
+	teacherIDs := ids(ctx.QueryArray("teacherId"))
+
+	if len(roomIDs)+len(resourceIDs)+len(teacherIDs) == 0 {
+		db.DB.Model(&models.Room{}).Where("active = ?", true).Order("name").Pluck("id", &roomIDs)
+	}
+
+	var rows []dto.FreeBusyRow
+
+	for _, id := range roomIDs {
+		var room models.Room
+		if db.DB.Where("id = ?", id).First(&room).Error != nil {
+			continue
+		}
+		busy, err := busySlots(scheduling.Overlapping(db.DB, from, to).Where("lessons.room_id = ?", id))
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		rows = append(rows, dto.FreeBusyRow{Kind: scheduling.ConflictRoom, ID: room.ID, Name: room.Name, Busy: busy})
+	}
+
+	for _, id := range resourceIDs {
+		var resource models.Resource
+		if db.DB.Where("id = ?", id).First(&resource).Error != nil {
+			continue
+		}
+		query := scheduling.Overlapping(db.DB, from, to).
+			Joins("JOIN lesson_resources ON lesson_resources.lesson_id = lessons.id").
+			Where("lesson_resources.resource_id = ?", id)
+		busy, err := busySlots(query)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		rows = append(rows, dto.FreeBusyRow{Kind: scheduling.ConflictResource, ID: resource.ID, Name: resource.Name, Busy: busy})
+	}
+
+	for _, id := range teacherIDs {
+		var teacher models.User
+		if db.DB.Where("id = ? AND role = ?", id, "teacher").First(&teacher).Error != nil {
+			continue
+		}
+		busy, err := busySlots(scheduling.TaughtBy(scheduling.Overlapping(db.DB, from, to), id))
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+
+		var leaves []models.TeacherLeave
+		db.DB.Where("teacher_id = ? AND start_date < ? AND end_date > ?", id, to, from).Find(&leaves)
+		for _, leave := range leaves {
+			busy = append(busy, dto.BusySlot{LessonName: "Leave: " + leave.Kind, Start: leave.StartDate, End: leave.EndDate})
+		}
+		rows = append(rows, dto.FreeBusyRow{Kind: scheduling.ConflictTeacher, ID: teacher.ID, Name: teacher.FirstName + " " + teacher.LastName, Busy: busy})
+	}
+
+	ctx.JSON(http.StatusOK, rows)
+}
+
+func busySlots(query *gorm.DB) ([]dto.BusySlot, error) {
+	var lessons []models.Lesson
+	if err := query.Select("lessons.*").Order("lessons.start_date").Find(&lessons).Error; err != nil {
+		return nil, err
+	}
+
+	slots := make([]dto.BusySlot, 0, len(lessons))
+	for _, lesson := range lessons {
+		slots = append(slots, dto.BusySlot{
+			LessonID:   lesson.ID,
+			LessonName: lesson.Name,
+			Start:      lesson.StartDate,
+			End:        lesson.StartDate.Add(time.Duration(lesson.Duration) * time.Minute),
+		})
+	}
+	return slots, nil
+}
+
+func ids(values []string) []uint {
+	var result []uint
+	for _, value := range values {
+		if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+			result = append(result, uint(id))
+		}
+	}
+	return result
+}
+
+func respondError(ctx *gin.Context, err error) {
+	logger.Log("Failed to get free/busy: "+err.Error(), slog.LevelError)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get free/busy"})
+}
@@ -0,0 +1,259 @@
+package rubric_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/endpoints"
+	"codev_erp/homework"
+	"codev_erp/logger"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type levelRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Points      uint   `json:"points"`
+}
+
+type criterionRequest struct {
+	Name        string         `json:"name" binding:"required"`
+	Description string         `json:"description"`
+	Levels      []levelRequest `json:"levels" binding:"required,min=1,dive"`
+}
+
+type rubricRequest struct {
+	Name        string             `json:"name" binding:"required"`
+	Description string             `json:"description"`
+	CourseID    *uint              `json:"courseID"` // без курса рубрика общая, её создаёт администратор
+	Criteria    []criterionRequest `json:"criteria" binding:"required,min=1,dive"`
+}
+
+type assignRequest struct {
+	RubricID *uint `json:"rubricID"` // null снимает рубрику
+}
+
+// canUseRubric reports whether the rubric may be attached to the course's lessons.
+func canUseRubric(rubric models.Rubric, courseID uint) bool {
+	return rubric.CourseID == nil || *rubric.CourseID == courseID
+}
+
+func canEditRubric(user dto.UserResponse, rubric models.Rubric) bool {
+	if rubric.CourseID == nil {
+		return user.Role == "admin"
+	}
+	return endpoints.CanManageCourse(user, *rubric.CourseID)
+}
+
+func GetRubricsHandler(ctx *gin.Context) {
+	query := db.DB.Model(&models.Rubric{})
+
+	if courseID := ctx.Query("courseId"); courseID != "" {
+		query = query.Where("course_id IS NULL OR course_id = ?", courseID)
+	}
+
+	var rubrics []models.Rubric
+
+	if err := query.Preload("Criteria.Levels").Order("name").Find(&rubrics).Error; err != nil {
+		logger.Log("Failed to get rubrics: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rubrics"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rubrics)
+}
+
+func GetRubricHandler(ctx *gin.Context) {
+	var rubricID uint
+	if err := db.DB.Model(&models.Rubric{}).Where("id = ?", ctx.Param("id")).Select("id").First(&rubricID).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Rubric not found"})
+		return
+	}
+
+	rubric, err := homework.LoadRubric(db.DB, rubricID)
+	if err != nil {
+		logger.Log("Failed to get rubric: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rubric"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"rubric": rubric, "maxPoints": homework.MaxPoints(rubric)})
+}
+
+func AddRubricHandler(ctx *gin.Context) {
+	var req rubricRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user := endpoints.SessionUser(ctx)
+	rubric := models.Rubric{Name: req.Name, Description: req.Description, CourseID: req.CourseID, CreatedBy: user.ID}
+
+	if !canEditRubric(user, rubric) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	for i, c := range req.Criteria {
+		criterion := models.RubricCriterion{Name: c.Name, Description: c.Description, Position: uint(i)}
+		for _, l := range c.Levels {
+			criterion.Levels = append(criterion.Levels, models.RubricLevel{Name: l.Name, Description: l.Description, Points: l.Points})
+		}
+		rubric.Criteria = append(rubric.Criteria, criterion)
+	}
+
+	if err := db.DB.Create(&rubric).Error; err != nil {
+		logger.Log("Failed to create rubric: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rubric"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, rubric)
+}
+
+// DeleteRubricHandler removes a template. Grades already given keep their breakdown,
+// which is a snapshot; lessons and courses using the rubric fall back to plain points.
+func DeleteRubricHandler(ctx *gin.Context) {
+	var rubric models.Rubric
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&rubric).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Rubric not found"})
+		return
+	}
+
+	if !canEditRubric(endpoints.SessionUser(ctx), rubric) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Lesson{}).Where("rubric_id = ?", rubric.ID).Update("rubric_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Course{}).Where("rubric_id = ?", rubric.ID).Update("rubric_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&rubric).Error
+	})
+
+	if err != nil {
+		logger.Log("Failed to delete rubric: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rubric"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Rubric deleted successfully"})
+}
+
+// loadAssignable checks that the rubric from the request may be used in the course.
+func loadAssignable(ctx *gin.Context, rubricID *uint, courseID uint) bool {
+	if rubricID == nil {
+		return true
+	}
+
+	var rubric models.Rubric
+	if err := db.DB.Where("id = ?", *rubricID).First(&rubric).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Rubric not found"})
+		return false
+	}
+
+	if !canUseRubric(rubric, courseID) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The rubric belongs to another course"})
+		return false
+	}
+
+	return true
+}
+
+func SetLessonRubricHandler(ctx *gin.Context) {
+	var req assignRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var lesson models.Lesson
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(endpoints.SessionUser(ctx), lesson.CourseID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if !loadAssignable(ctx, req.RubricID, lesson.CourseID) {
+		return
+	}
+
+	if err := db.DB.Model(&lesson).Update("rubric_id", req.RubricID).Error; err != nil {
+		logger.Log("Failed to set lesson rubric: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set lesson rubric"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Lesson rubric updated successfully"})
+}
+
+func SetCourseRubricHandler(ctx *gin.Context) {
+	var req assignRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var course models.Course
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&course).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(endpoints.SessionUser(ctx), course.ID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if !loadAssignable(ctx, req.RubricID, course.ID) {
+		return
+	}
+
+	if err := db.DB.Model(&course).Update("rubric_id", req.RubricID).Error; err != nil {
+		logger.Log("Failed to set course rubric: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set course rubric"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Course rubric updated successfully"})
+}
+
+// GetLessonRubricHandler returns the rubric graders and students see for the lesson,
+// or null when it is graded with plain points.
+func GetLessonRubricHandler(ctx *gin.Context) {
+	var lesson models.Lesson
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		return
+	}
+
+	rubric, err := homework.RubricFor(db.DB, lesson)
+	if err != nil {
+		logger.Log("Failed to get rubric: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rubric"})
+		return
+	}
+
+	if rubric == nil {
+		ctx.JSON(http.StatusOK, gin.H{"rubric": nil})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"rubric": rubric, "maxPoints": homework.MaxPoints(*rubric)})
+}
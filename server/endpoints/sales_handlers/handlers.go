@@ -1,9 +1,11 @@
 package sales_handlers
 
 import (
+	"codev_erp/billing"
 	"codev_erp/db"
 	"codev_erp/db/models"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,7 +13,7 @@ import (
 func GetSales(ctx *gin.Context) {
 
 	var Sales []models.Sales
-	db.DB.Preload("Lead").Preload("Course").Find(&Sales)
+	db.DB.Preload("Lead").Preload("Course").Preload("Discount").Find(&Sales)
 
 	ctx.JSON(http.StatusOK, Sales)
 }
@@ -24,6 +26,8 @@ func UpdateSales(ctx *gin.Context) {
 		Result   *string `json:"result"`
 		Payed    *bool   `json:"payed"`
 		Note     *string `json:"note"`
+
+		DiscountCode *string `json:"discountCode"`
 	}
 
 	var updateRequest UpdateRequest
@@ -57,6 +61,34 @@ func UpdateSales(ctx *gin.Context) {
 		db.DB.Model(&Sales).Where("id = ?", updateRequest.Id).
 			Update("note", *updateRequest.Note)
 
+	} else if updateRequest.DiscountCode != nil {
+
+		if *updateRequest.DiscountCode == "" {
+			db.DB.Model(&Sales).Where("id = ?", updateRequest.Id).
+				Update("discount_id", nil)
+
+			ctx.Status(http.StatusOK)
+			return
+		}
+
+		if err := db.DB.Preload("Course").Where("id = ?", updateRequest.Id).First(&Sales).Error; err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Sales record not found"})
+			return
+		}
+
+		discount, err := billing.FindDiscountByCode(db.DB, *updateRequest.DiscountCode)
+		if err == nil {
+			err = billing.CheckDiscount(discount, Sales.Course, time.Now())
+		}
+
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		db.DB.Model(&models.Sales{}).Where("id = ?", updateRequest.Id).
+			Update("discount_id", discount.ID)
+
 	}
 
 	ctx.Status(http.StatusOK)
@@ -0,0 +1,639 @@
+package schedule_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/endpoints"
+	"codev_erp/logger"
+	"codev_erp/notify"
+	"codev_erp/scheduling"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	scopeThis      = "this"
+	scopeFollowing = "following"
+)
+
+func GetSchedulesHandler(ctx *gin.Context) {
+	var schedules []models.CourseSchedule
+
+	err := db.DB.Preload("Exceptions").
+		Where("course_id = ?", ctx.Param("id")).
+		Order("weekday, start_time").
+		Find(&schedules).Error
+
+	if err != nil {
+		logger.Log("Failed to get schedules: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get schedules"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedules)
+}
+
+func AddScheduleHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	courseID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(user, uint(courseID)) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return
+	}
+
+	var req struct {
+		Weekday    int        `json:"weekday"`
+		StartTime  string     `json:"startTime"`
+		Duration   uint       `json:"duration"`
+		RoomID     *uint      `json:"roomID"`
+		Timezone   string     `json:"timezone"`
+		ValidFrom  *time.Time `json:"validFrom"`
+		ValidUntil *time.Time `json:"validUntil"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Weekday < 0 || req.Weekday > 6 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if req.Timezone == "" {
+		req.Timezone = "Asia/Baku"
+	}
+
+	if _, _, err := scheduling.ParseClock(req.StartTime); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := scheduling.Location(req.Timezone); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := models.CourseSchedule{
+		CourseID:   uint(courseID),
+		Weekday:    req.Weekday,
+		StartTime:  req.StartTime,
+		Duration:   req.Duration,
+		RoomID:     req.RoomID,
+		Timezone:   req.Timezone,
+		ValidFrom:  req.ValidFrom,
+		ValidUntil: req.ValidUntil,
+	}
+
+	if err := db.DB.Create(&schedule).Error; err != nil {
+		logger.Log("Failed to create schedule: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, schedule)
+}
+
+func DeleteScheduleHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var schedule models.CourseSchedule
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&schedule).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(user, schedule.CourseID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// будущие занятия по этому расписанию больше не нужны, прошедшие оставляем
+		err := tx.Where("schedule_id = ? AND start_date > ?", schedule.ID, time.Now()).
+			Delete(&models.Lesson{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&schedule).Error
+	})
+
+	if err != nil {
+		logger.Log("Failed to delete schedule: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Schedule deleted successfully"})
+}
+
+func AddExceptionHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var req struct {
+		Date   string `json:"date"`
+		Reason string `json:"reason"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+		return
+	}
+
+	var schedule models.CourseSchedule
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&schedule).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(user, schedule.CourseID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return
+	}
+
+	exception := models.ScheduleException{ScheduleID: schedule.ID, Date: date, Reason: req.Reason}
+
+	if err := db.DB.Create(&exception).Error; err != nil {
+		logger.Log("Failed to create schedule exception: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create exception"})
+		return
+	}
+
+	// занятие на эту дату могло быть уже сгенерировано
+	loc, _ := scheduling.Location(schedule.Timezone)
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+
+	var lessons []models.Lesson
+	db.DB.Where("schedule_id = ? AND original_start >= ? AND original_start < ? AND status = ?",
+		schedule.ID, dayStart, dayStart.AddDate(0, 0, 1), "scheduled").Find(&lessons)
+
+	if err := cancelLessons(lessons, req.Reason); err != nil {
+		logger.Log("Failed to cancel lessons on exception date: "+err.Error(), slog.LevelError)
+	}
+
+	ctx.JSON(http.StatusCreated, exception)
+}
+
+// GenerateLessonsHandler creates the future lesson occurrences of all course schedules.
+// Without an explicit range it covers the span of the course's enrollments.
+// Occurrences that were already generated (even if moved or cancelled since) are skipped.
+func GenerateLessonsHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	courseID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		return
+	}
+
+	if !endpoints.CanManageCourse(user, uint(courseID)) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return
+	}
+
+	var req struct {
+		From *time.Time `json:"from"`
+		To   *time.Time `json:"to"`
+	}
+	_ = ctx.ShouldBindJSON(&req)
+
+	from, to, ok := generationSpan(uint(courseID), req.From, req.To)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Course has no active enrollments, pass from and to"})
+		return
+	}
+
+	var course models.Course
+	if err := db.DB.Where("id = ?", courseID).First(&course).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return
+	}
+
+	var schedules []models.CourseSchedule
+	db.DB.Preload("Exceptions").Where("course_id = ?", courseID).Find(&schedules)
+
+	var holidays []models.Holiday
+	db.DB.Where("(course_id IS NULL OR course_id = ?) AND date >= ? AND date <= ?",
+		courseID, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1)).Find(&holidays)
+
+	var existing []models.Lesson
+	db.DB.Where("course_id = ?", courseID).Find(&existing)
+
+	generated := map[string]bool{}
+	for _, lesson := range existing {
+		if lesson.ScheduleID != nil && lesson.OriginalStart != nil {
+			generated[seriesKey(*lesson.ScheduleID, *lesson.OriginalStart)] = true
+		}
+	}
+
+	var lessons []models.Lesson
+	var bookings []scheduling.Booking
+	var conflicts []scheduling.Conflict
+
+	for _, schedule := range schedules {
+		var skip []time.Time
+		for _, holiday := range holidays {
+			skip = append(skip, holiday.Date)
+		}
+		for _, exception := range schedule.Exceptions {
+			skip = append(skip, exception.Date)
+		}
+
+		starts, err := scheduling.Occurrences(schedule, from, to, skip)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, start := range starts {
+			if generated[seriesKey(schedule.ID, start)] {
+				continue
+			}
+
+			booking := scheduling.Booking{CourseID: course.ID, Start: start, Duration: schedule.Duration, RoomID: schedule.RoomID}
+
+			// занятия с конфликтами не создаём, а возвращаем списком для ручного решения
+			clashes, err := scheduling.Conflicts(db.DB, booking, nil)
+			if err != nil {
+				logger.Log("Failed to check lesson conflicts: "+err.Error(), slog.LevelError)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate lessons"})
+				return
+			}
+			for _, other := range bookings {
+				if scheduling.Overlaps(booking, other) {
+					clashes = append(clashes, scheduling.Conflict{Kind: scheduling.ConflictGroup, CourseID: course.ID, Start: other.Start, End: other.End()})
+				}
+			}
+			if len(clashes) > 0 {
+				conflicts = append(conflicts, clashes...)
+				continue
+			}
+			bookings = append(bookings, booking)
+
+			scheduleID := schedule.ID
+			originalStart := start
+
+			lessons = append(lessons, models.Lesson{
+				CourseID:      course.ID,
+				Description:   "",
+				StartDate:     start,
+				Duration:      schedule.Duration,
+				RoomID:        schedule.RoomID,
+				Status:        "scheduled",
+				ScheduleID:    &scheduleID,
+				OriginalStart: &originalStart,
+			})
+		}
+	}
+
+	sort.Slice(lessons, func(i, j int) bool {
+		return lessons[i].StartDate.Before(lessons[j].StartDate)
+	})
+	for i := range lessons {
+		lessons[i].Name = fmt.Sprintf("%s — lesson %d", course.Name, len(existing)+i+1)
+	}
+
+	if len(lessons) > 0 {
+		if err := db.DB.Create(&lessons).Error; err != nil {
+			logger.Log("Failed to generate lessons: "+err.Error(), slog.LevelError)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate lessons"})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success":   fmt.Sprintf("%d lessons generated", len(lessons)),
+		"lessons":   lessons,
+		"conflicts": conflicts,
+	})
+}
+
+// RescheduleLessonHandler moves one occurrence, or it and all later ones of its series
+// by the same offset.
+func RescheduleLessonHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var req struct {
+		StartDate time.Time `json:"startDate"`
+		Duration  *uint     `json:"duration"`
+		RoomID    *uint     `json:"roomID"`
+		Scope     string    `json:"scope"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.StartDate.IsZero() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	lesson, ok := loadManagedLesson(ctx, user)
+	if !ok {
+		return
+	}
+
+	if lesson.Status == "cancelled" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Lesson is cancelled"})
+		return
+	}
+
+	lessons, ok := scopedLessons(ctx, lesson, req.Scope)
+	if !ok {
+		return
+	}
+
+	offset := req.StartDate.Sub(lesson.StartDate)
+
+	ids := make([]uint, 0, len(lessons))
+	for _, moved := range lessons {
+		ids = append(ids, moved.ID)
+	}
+
+	var conflicts []scheduling.Conflict
+	for _, moved := range lessons {
+		booking := scheduling.Booking{
+			LessonID:     moved.ID,
+			CourseID:     moved.CourseID,
+			Start:        moved.StartDate.Add(offset),
+			Duration:     moved.Duration,
+			RoomID:       moved.RoomID,
+			SubstituteID: moved.SubstituteID,
+		}
+		if req.Duration != nil {
+			booking.Duration = *req.Duration
+		}
+		if req.RoomID != nil {
+			booking.RoomID = req.RoomID
+		}
+
+		resourceIDs, err := scheduling.LessonResourceIDs(db.DB, moved.ID)
+		if err == nil {
+			booking.ResourceIDs = resourceIDs
+			var clashes []scheduling.Conflict
+			clashes, err = scheduling.Conflicts(db.DB, booking, ids)
+			conflicts = append(conflicts, clashes...)
+		}
+		if err != nil {
+			logger.Log("Failed to check lesson conflicts: "+err.Error(), slog.LevelError)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reschedule lessons"})
+			return
+		}
+	}
+
+	if len(conflicts) > 0 {
+		endpoints.RespondConflicts(ctx, conflicts)
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range lessons {
+			lessons[i].StartDate = lessons[i].StartDate.Add(offset)
+
+			updates := map[string]interface{}{"start_date": lessons[i].StartDate}
+			if req.Duration != nil {
+				updates["duration"] = *req.Duration
+			}
+			if req.RoomID != nil {
+				updates["room_id"] = *req.RoomID
+			}
+
+			if err := tx.Model(&models.Lesson{}).Where("id = ?", lessons[i].ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		logger.Log("Failed to reschedule lessons: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reschedule lessons"})
+		return
+	}
+
+	notifyStudents(lesson.CourseID, notify.Message{
+		Kind:  "lesson_rescheduled",
+		Title: "Lesson rescheduled",
+		Body: fmt.Sprintf("%s moved to %s (%d lesson(s) affected).",
+			lesson.Name, lessons[0].StartDate.Format("02.01.2006 15:04"), len(lessons)),
+	})
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Lessons rescheduled successfully", "lessons": lessons})
+}
+
+func CancelLessonHandler(ctx *gin.Context) {
+	user := endpoints.SessionUser(ctx)
+
+	var req struct {
+		Scope  string `json:"scope"`
+		Reason string `json:"reason"`
+	}
+	_ = ctx.ShouldBindJSON(&req)
+
+	lesson, ok := loadManagedLesson(ctx, user)
+	if !ok {
+		return
+	}
+
+	lessons, ok := scopedLessons(ctx, lesson, req.Scope)
+	if !ok {
+		return
+	}
+
+	if err := cancelLessons(lessons, req.Reason); err != nil {
+		logger.Log("Failed to cancel lessons: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel lessons"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": fmt.Sprintf("%d lessons cancelled", len(lessons))})
+}
+
+//Administrator-specific handlers
+
+func GetHolidaysHandler(ctx *gin.Context) {
+	var holidays []models.Holiday
+
+	if err := db.DB.Order("date").Find(&holidays).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get holidays"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, holidays)
+}
+
+func AddHolidayHandler(ctx *gin.Context) {
+	var req struct {
+		Date     string `json:"date"`
+		Name     string `json:"name"`
+		CourseID *uint  `json:"courseID"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+		return
+	}
+
+	holiday := models.Holiday{Date: date, Name: req.Name, CourseID: req.CourseID}
+
+	if err := db.DB.Create(&holiday).Error; err != nil {
+		logger.Log("Failed to create holiday: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create holiday"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, holiday)
+}
+
+func DeleteHolidayHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid holiday ID"})
+		return
+	}
+
+	if err := db.DB.Delete(&models.Holiday{}, id).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete holiday"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Holiday deleted successfully"})
+}
+
+// loadManagedLesson loads :id for the course's own teacher or an admin. Unlike
+// endpoints.ManagedLesson it does not let a substitute through: the timetable belongs
+// to the course, and a substitute covers a lesson without owning when it happens.
+func loadManagedLesson(ctx *gin.Context, user dto.UserResponse) (models.Lesson, bool) {
+	var lesson models.Lesson
+
+	if err := db.DB.Where("id = ?", ctx.Param("id")).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		return lesson, false
+	}
+
+	if !endpoints.CanManageCourse(user, lesson.CourseID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Course not found or you are not the teacher"})
+		return lesson, false
+	}
+
+	return lesson, true
+}
+
+// scopedLessons returns the lesson itself, or with scope "following" every later
+// scheduled lesson of the same series (of the same course for one-off lessons).
+func scopedLessons(ctx *gin.Context, lesson models.Lesson, scope string) ([]models.Lesson, bool) {
+	switch scope {
+	case "", scopeThis:
+		return []models.Lesson{lesson}, true
+	case scopeFollowing:
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Scope must be this or following"})
+		return nil, false
+	}
+
+	query := db.DB.Where("start_date >= ? AND status = ?", lesson.StartDate, "scheduled")
+
+	if lesson.ScheduleID != nil {
+		query = query.Where("schedule_id = ?", *lesson.ScheduleID)
+	} else {
+		query = query.Where("course_id = ?", lesson.CourseID)
+	}
+
+	var lessons []models.Lesson
+	if err := query.Order("start_date").Find(&lessons).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get lessons"})
+		return nil, false
+	}
+
+	return lessons, true
+}
+
+func cancelLessons(lessons []models.Lesson, reason string) error {
+	if len(lessons) == 0 {
+		return nil
+	}
+
+	var ids []uint
+	for _, lesson := range lessons {
+		ids = append(ids, lesson.ID)
+	}
+
+	if err := db.DB.Model(&models.Lesson{}).Where("id IN ?", ids).Update("status", "cancelled").Error; err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("%s on %s is cancelled.", lessons[0].Name, lessons[0].StartDate.Format("02.01.2006 15:04"))
+	if len(lessons) > 1 {
+		body = fmt.Sprintf("%s and %d later lesson(s) are cancelled.", lessons[0].Name, len(lessons)-1)
+	}
+	if reason != "" {
+		body += " Reason: " + reason
+	}
+
+	notifyStudents(lessons[0].CourseID, notify.Message{Kind: "lesson_cancelled", Title: "Lesson cancelled", Body: body})
+	return nil
+}
+
+func notifyStudents(courseID uint, msg notify.Message) {
+	var studentIDs []uint
+	db.DB.Model(&models.EnrolledCourse{}).Where("course_id = ? AND end_date >= ?", courseID, time.Now()).
+		Pluck("user_id", &studentIDs)
+
+	for _, id := range studentIDs {
+		notify.Send(id, msg)
+	}
+}
+
+// generationSpan defaults to the span of the course's active enrollments, never in the past.
+func generationSpan(courseID uint, from *time.Time, to *time.Time) (time.Time, time.Time, bool) {
+	now := time.Now()
+
+	var span struct {
+		Start *time.Time
+		End   *time.Time
+	}
+	db.DB.Model(&models.EnrolledCourse{}).
+		Where("course_id = ? AND end_date >= ?", courseID, now).
+		Select("MIN(start_date) AS start, MAX(end_date) AS \"end\"").
+		Scan(&span)
+
+	start, end := now, now
+	if span.Start != nil && span.Start.After(now) {
+		start = *span.Start
+	}
+	if span.End != nil {
+		end = *span.End
+	}
+
+	if from != nil {
+		start = *from
+	}
+	if to != nil {
+		end = *to
+	}
+
+	return start, end, end.After(start)
+}
+
+func seriesKey(scheduleID uint, start time.Time) string {
+	return strconv.FormatUint(uint64(scheduleID), 10) + "@" + strconv.FormatInt(start.Unix(), 10)
+}
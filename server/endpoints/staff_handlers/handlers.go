@@ -0,0 +1,395 @@
+package staff_handlers
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/dto"
+	"codev_erp/endpoints"
+	"codev_erp/logger"
+	"codev_erp/notify"
+	"codev_erp/scheduling"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type availabilityRequest struct {
+	Weekday   int    `json:"weekday"`
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+	Timezone  string `json:"timezone"`
+}
+
+func GetAvailabilityHandler(ctx *gin.Context) {
+	teacherID, ok := teacherParam(ctx)
+	if !ok {
+		return
+	}
+
+	var windows []models.TeacherAvailability
+	if err := db.DB.Where("teacher_id = ?", teacherID).Order("weekday, start_time").Find(&windows).Error; err != nil {
+		logger.Log("Failed to get availability: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get availability"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, windows)
+}
+
+// SetAvailabilityHandler replaces the teacher's weekly windows. An empty list means
+// the teacher is available at any time.
+func SetAvailabilityHandler(ctx *gin.Context) {
+	teacherID, ok := teacherParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req []availabilityRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	windows := make([]models.TeacherAvailability, 0, len(req))
+	for _, item := range req {
+		if item.Timezone == "" {
+			item.Timezone = "Asia/Baku"
+		}
+
+		fromHour, fromMinute, errFrom := scheduling.ParseClock(item.StartTime)
+		toHour, toMinute, errTo := scheduling.ParseClock(item.EndTime)
+		_, errZone := scheduling.Location(item.Timezone)
+
+		if item.Weekday < 0 || item.Weekday > 6 || errFrom != nil || errTo != nil || errZone != nil ||
+			fromHour*60+fromMinute >= toHour*60+toMinute {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Each window needs a weekday 0-6, startTime before endTime and a valid timezone"})
+			return
+		}
+
+		windows = append(windows, models.TeacherAvailability{
+			TeacherID: teacherID,
+			Weekday:   item.Weekday,
+			StartTime: item.StartTime,
+			EndTime:   item.EndTime,
+			Timezone:  item.Timezone,
+		})
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("teacher_id = ?", teacherID).Delete(&models.TeacherAvailability{}).Error; err != nil {
+			return err
+		}
+		if len(windows) == 0 {
+			return nil
+		}
+		return tx.Create(&windows).Error
+	})
+
+	if err != nil {
+		logger.Log("Failed to save availability: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save availability"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, windows)
+}
+
+func GetLeavesHandler(ctx *gin.Context) {
+	teacherID, ok := teacherParam(ctx)
+	if !ok {
+		return
+	}
+
+	var leaves []models.TeacherLeave
+	if err := db.DB.Where("teacher_id = ?", teacherID).Order("start_date DESC").Find(&leaves).Error; err != nil {
+		logger.Log("Failed to get leaves: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get leaves"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, leaves)
+}
+
+// AddLeaveHandler records a leave and returns the teacher's lessons in that period,
+// which the admin then hands over to substitutes.
+func AddLeaveHandler(ctx *gin.Context) {
+	teacherID, ok := teacherParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Kind      string    `json:"kind"`
+		StartDate time.Time `json:"startDate"`
+		EndDate   time.Time `json:"endDate"`
+		Reason    string    `json:"reason"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil || !req.EndDate.After(req.StartDate) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if req.Kind != "sick" && req.Kind != "vacation" && req.Kind != "other" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be sick, vacation or other"})
+		return
+	}
+
+	leave := models.TeacherLeave{
+		TeacherID: teacherID,
+		Kind:      req.Kind,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Reason:    req.Reason,
+		CreatedBy: endpoints.SessionUser(ctx).ID,
+	}
+
+	if err := db.DB.Create(&leave).Error; err != nil {
+		logger.Log("Failed to create leave: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create leave"})
+		return
+	}
+
+	var affected []models.Lesson
+	scheduling.TaughtBy(scheduling.Overlapping(db.DB, leave.StartDate, leave.EndDate), teacherID).
+		Select("lessons.*").
+		Order("lessons.start_date").
+		Find(&affected)
+
+	ctx.JSON(http.StatusCreated, gin.H{"leave": leave, "affectedLessons": affected})
+}
+
+func DeleteLeaveHandler(ctx *gin.Context) {
+	if err := db.DB.Where("id = ?", ctx.Param("id")).Delete(&models.TeacherLeave{}).Error; err != nil {
+		logger.Log("Failed to delete leave: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete leave"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Leave deleted successfully"})
+}
+
+// GetSubstituteCandidatesHandler lists the teachers who are free for the lesson.
+func GetSubstituteCandidatesHandler(ctx *gin.Context) {
+	lesson, ok := loadLesson(ctx)
+	if !ok {
+		return
+	}
+
+	var teachers []models.User
+	if err := db.DB.Where("role = ?", "teacher").Order("last_name, first_name").Find(&teachers).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get teachers"})
+		return
+	}
+
+	candidates := []dto.UserResponse{}
+	for _, teacher := range teachers {
+		if lesson.Course.TeacherID != nil && *lesson.Course.TeacherID == teacher.ID {
+			continue
+		}
+		if reason, err := unavailability(lesson, teacher.ID); err != nil || reason != "" {
+			continue
+		}
+
+		candidates = append(candidates, dto.UserResponse{
+			ID:        teacher.ID,
+			Email:     teacher.Email,
+			FirstName: teacher.FirstName,
+			LastName:  teacher.LastName,
+			Role:      teacher.Role,
+			Avatar:    teacher.Avatar,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, candidates)
+}
+
+// AssignSubstituteHandler hands one lesson to another teacher without touching the
+// course's owner. The substitute gets attendance and grading rights for it.
+func AssignSubstituteHandler(ctx *gin.Context) {
+	var req struct {
+		SubstituteID uint `json:"substituteID" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	lesson, ok := loadLesson(ctx)
+	if !ok {
+		return
+	}
+
+	var substitute models.User
+	if err := db.DB.Where("id = ? AND role = ?", req.SubstituteID, "teacher").First(&substitute).Error; err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Substitute must be a teacher"})
+		return
+	}
+
+	if lesson.Course.TeacherID != nil && *lesson.Course.TeacherID == substitute.ID {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The course teacher cannot substitute for themselves"})
+		return
+	}
+
+	reason, err := unavailability(lesson, substitute.ID)
+	if err != nil {
+		logger.Log("Failed to check substitute availability: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign substitute"})
+		return
+	}
+	if reason != "" {
+		ctx.JSON(http.StatusConflict, gin.H{"error": reason})
+		return
+	}
+
+	if err := db.DB.Model(&lesson).Update("substitute_id", substitute.ID).Error; err != nil {
+		logger.Log("Failed to assign substitute: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign substitute"})
+		return
+	}
+
+	when := lesson.StartDate.Format("02.01.2006 15:04")
+
+	notify.Send(substitute.ID, notify.Message{
+		Kind:  "substitute_assigned",
+		Title: "Substitute lesson",
+		Body:  fmt.Sprintf("You will teach %s: %s on %s.", lesson.Course.Name, lesson.Name, when),
+	})
+	notifyStudents(lesson, notify.Message{
+		Kind:  "substitute_assigned",
+		Title: "Substitute teacher",
+		Body:  fmt.Sprintf("%s on %s will be taught by %s %s.", lesson.Name, when, substitute.FirstName, substitute.LastName),
+	})
+
+	lesson.SubstituteID = &substitute.ID
+	lesson.Substitute = &substitute
+	ctx.JSON(http.StatusOK, lesson)
+}
+
+func RemoveSubstituteHandler(ctx *gin.Context) {
+	lesson, ok := loadLesson(ctx)
+	if !ok {
+		return
+	}
+
+	if lesson.SubstituteID == nil {
+		ctx.JSON(http.StatusOK, gin.H{"success": "Lesson has no substitute"})
+		return
+	}
+
+	if err := db.DB.Model(&lesson).Update("substitute_id", nil).Error; err != nil {
+		logger.Log("Failed to remove substitute: "+err.Error(), slog.LevelError)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove substitute"})
+		return
+	}
+
+	notify.Send(*lesson.SubstituteID, notify.Message{
+		Kind:  "substitute_removed",
+		Title: "Substitute lesson withdrawn",
+		Body:  fmt.Sprintf("You no longer teach %s: %s on %s.", lesson.Course.Name, lesson.Name, lesson.StartDate.Format("02.01.2006 15:04")),
+	})
+
+	ctx.JSON(http.StatusOK, gin.H{"success": "Substitute removed"})
+}
+
+// unavailability explains why the teacher cannot take the lesson, or returns "".
+func unavailability(lesson models.Lesson, teacherID uint) (string, error) {
+	end := lesson.StartDate.Add(time.Duration(lesson.Duration) * time.Minute)
+
+	leave, err := scheduling.OnLeave(db.DB, teacherID, lesson.StartDate, end)
+	if err != nil {
+		return "", err
+	}
+	if leave != nil {
+		return "Teacher is on leave at that time", nil
+	}
+
+	available, err := scheduling.WithinAvailability(db.DB, teacherID, lesson.StartDate, end)
+	if err != nil {
+		return "", err
+	}
+	if !available {
+		return "Lesson is outside the teacher's availability", nil
+	}
+
+	resourceIDs, err := scheduling.LessonResourceIDs(db.DB, lesson.ID)
+	if err != nil {
+		return "", err
+	}
+
+	conflicts, err := scheduling.Conflicts(db.DB, scheduling.Booking{
+		LessonID:     lesson.ID,
+		CourseID:     lesson.CourseID,
+		Start:        lesson.StartDate,
+		Duration:     lesson.Duration,
+		RoomID:       lesson.RoomID,
+		ResourceIDs:  resourceIDs,
+		SubstituteID: &teacherID,
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	for _, conflict := range conflicts {
+		if conflict.Kind == scheduling.ConflictTeacher {
+			return "Teacher has another lesson at that time: " + conflict.LessonName, nil
+		}
+	}
+
+	return "", nil
+}
+
+// teacherParam resolves :id and lets only admins and the teacher themselves through.
+func teacherParam(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid teacher ID"})
+		return 0, false
+	}
+
+	user := endpoints.SessionUser(ctx)
+	if user.Role != "admin" && user.ID != uint(id) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+func loadLesson(ctx *gin.Context) (models.Lesson, bool) {
+	var lesson models.Lesson
+
+	if err := db.DB.Preload("Course").Where("id = ?", ctx.Param("id")).First(&lesson).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		return lesson, false
+	}
+
+	if lesson.Status == "cancelled" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Lesson is cancelled"})
+		return lesson, false
+	}
+
+	if !endpoints.CanManageCourse(endpoints.SessionUser(ctx), lesson.CourseID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return lesson, false
+	}
+
+	return lesson, true
+}
+
+func notifyStudents(lesson models.Lesson, msg notify.Message) {
+	var studentIDs []uint
+	db.DB.Model(&models.EnrolledCourse{}).
+		Where("course_id = ? AND start_date <= ? AND end_date >= ?", lesson.CourseID, lesson.StartDate, lesson.StartDate).
+		Pluck("user_id", &studentIDs)
+
+	for _, id := range studentIDs {
+		notify.Send(id, msg)
+	}
+}
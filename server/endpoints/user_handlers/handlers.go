@@ -5,6 +5,7 @@ import (
 	"codev_erp/db/models"
 	"codev_erp/dto"
 	"codev_erp/endpoints"
+	"codev_erp/storage"
 	"net/http"
 
 	"github.com/gin-contrib/sessions"
@@ -52,7 +53,7 @@ func AvatarUpdateHandler(ctx *gin.Context) {
 		return
 	}
 
-	filename := endpoints.ProcessImageFile(file, ctx, false)
+	filename := endpoints.ProcessImageFile(file, ctx, storage.AvatarsDir)
 
 	if err := db.DB.Model(&models.User{}).Where("id = ?", el.ID).Update("avatar", filename).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
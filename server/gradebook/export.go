@@ -0,0 +1,148 @@
+package gradebook
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Table flattens the gradebook into a header row and one row per student. Empty
+// cells stand for ungraded work; "missing" for work not handed in by the deadline.
+func Table(book Book) [][]string {
+	header := []string{"Last name", "First name"}
+	for _, column := range book.Columns {
+		header = append(header, fmt.Sprintf("%s (%s, /%d)", column.Name, column.Category, column.MaxPoints))
+	}
+	for _, category := range Categories {
+		header = append(header, category+" %")
+	}
+	header = append(header, "Final %", "Grade")
+
+	table := [][]string{header}
+
+	for _, row := range book.Rows {
+		line := []string{row.LastName, row.FirstName}
+
+		for _, cell := range row.Cells {
+			switch {
+			case cell.Points != nil:
+				line = append(line, strconv.FormatUint(uint64(*cell.Points), 10))
+			case cell.Missing:
+				line = append(line, "missing")
+			default:
+				line = append(line, "")
+			}
+		}
+
+		for _, category := range Categories {
+			line = append(line, formatPercent(row.Categories[category]))
+		}
+		line = append(line, formatPercent(row.Percent), row.Grade)
+
+		table = append(table, line)
+	}
+
+	return table
+}
+
+func formatPercent(percent *float64) string {
+	if percent == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*percent, 'f', 1, 64)
+}
+
+func WriteCSV(w io.Writer, table [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(table); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// WriteXLSX writes the table as a single-sheet workbook. Numeric cells are stored as
+// numbers so spreadsheets can sum and sort them; everything else as inline strings.
+func WriteXLSX(w io.Writer, table [][]string) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/worksheets/sheet1.xml", xlsxSheet(table)},
+	}
+
+	for _, file := range files {
+		part, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(part, file.body); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func xlsxSheet(table [][]string) string {
+	var b strings.Builder
+
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	for r, line := range table {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, value := range line {
+			ref := columnName(c) + strconv.Itoa(r+1)
+			if value == "" {
+				continue
+			}
+			if _, err := strconv.ParseFloat(value, 64); err == nil && r > 0 && c >= 2 {
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, value)
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>`, ref)
+			xml.EscapeText(&b, []byte(value))
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// columnName turns a zero-based index into a spreadsheet column: 0 → A, 26 → AA.
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="Gradebook" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
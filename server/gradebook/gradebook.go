@@ -0,0 +1,377 @@
+package gradebook
+
+import (
+	"codev_erp/attendance"
+	"codev_erp/db/models"
+	"codev_erp/homework"
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CategoryHomework   = "homework"
+	CategoryClasswork  = "classwork"
+	CategoryExam       = "exam"
+	CategoryAttendance = "attendance"
+)
+
+// Categories lists the gradebook categories in display order.
+var Categories = []string{CategoryHomework, CategoryClasswork, CategoryExam, CategoryAttendance}
+
+var ErrInvalidScale = errors.New("the scale needs unique grades with percentages between 0 and 100")
+
+// DefaultScale is used until a course configures its own.
+var DefaultScale = []models.GradeBand{
+	{Grade: "A", MinPercent: 90},
+	{Grade: "B", MinPercent: 80},
+	{Grade: "C", MinPercent: 70},
+	{Grade: "D", MinPercent: 60},
+	{Grade: "F", MinPercent: 0},
+}
+
+// ValidLessonCategory reports whether a lesson may be filed under the category.
+// Attendance is computed from attendance marks and has no lessons of its own.
+func ValidLessonCategory(category string) bool {
+	return category == CategoryHomework || category == CategoryClasswork || category == CategoryExam
+}
+
+// Column is one graded lesson of the gradebook.
+type Column struct {
+	LessonID  uint       `json:"lessonID"`
+	Name      string     `json:"name"`
+	Category  string     `json:"category"`
+	Date      time.Time  `json:"date"`
+	MaxPoints uint       `json:"maxPoints"`
+	DueDate   *time.Time `json:"dueDate"`
+}
+
+// Cell is a student's result for one column. Points is nil until the work is graded;
+// Missing marks work that was never handed in although the deadline has passed.
+// NotEnrolled marks a lesson outside the student's enrollment, which doesn't count.
+type Cell struct {
+	Points      *uint `json:"points"`
+	Missing     bool  `json:"missing"`
+	Pending     bool  `json:"pending"` // сдано, но ещё не оценено
+	NotEnrolled bool  `json:"notEnrolled"`
+}
+
+// Row is one student of the gradebook. Category percentages are nil while the
+// category has nothing counted yet.
+type Row struct {
+	UserID     uint                `json:"userID"`
+	FirstName  string              `json:"firstName"`
+	LastName   string              `json:"lastName"`
+	Cells      []Cell              `json:"cells"`
+	Categories map[string]*float64 `json:"categories"`
+	Percent    *float64            `json:"percent"`
+	Grade      string              `json:"grade"`
+}
+
+type Book struct {
+	CourseID uint                 `json:"courseID"`
+	Scheme   models.GradingScheme `json:"scheme"`
+	Columns  []Column             `json:"columns"`
+	Rows     []Row                `json:"rows"`
+}
+
+// Scheme returns the course's grading scheme, or the defaults when none was saved.
+func Scheme(tx *gorm.DB, courseID uint) (models.GradingScheme, error) {
+	scheme := models.GradingScheme{CourseID: courseID, HomeworkWeight: 40, ClassworkWeight: 20, ExamWeight: 30, AttendanceWeight: 10}
+
+	err := tx.Where("course_id = ?", courseID).First(&scheme).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return scheme, err
+	}
+
+	if len(scheme.Scale) == 0 {
+		scheme.Scale = DefaultScale
+	}
+	return scheme, nil
+}
+
+// Weight is the weight of a category in the scheme.
+func Weight(scheme models.GradingScheme, category string) uint {
+	switch category {
+	case CategoryHomework:
+		return scheme.HomeworkWeight
+	case CategoryClasswork:
+		return scheme.ClassworkWeight
+	case CategoryExam:
+		return scheme.ExamWeight
+	case CategoryAttendance:
+		return scheme.AttendanceWeight
+	}
+	return 0
+}
+
+// NormalizeScale validates a scale and sorts it from the highest band down.
+// A band starting at 0 is required so every percentage maps to a grade.
+func NormalizeScale(scale []models.GradeBand) ([]models.GradeBand, error) {
+	seen := map[string]bool{}
+	hasFloor := false
+
+	for _, band := range scale {
+		if band.Grade == "" || seen[band.Grade] || band.MinPercent < 0 || band.MinPercent > 100 {
+			return nil, ErrInvalidScale
+		}
+		seen[band.Grade] = true
+		hasFloor = hasFloor || band.MinPercent == 0
+	}
+
+	if !hasFloor {
+		return nil, ErrInvalidScale
+	}
+
+	sorted := append([]models.GradeBand(nil), scale...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].MinPercent > sorted[j].MinPercent })
+	return sorted, nil
+}
+
+// GradeFor maps a percentage onto the scale, which must be sorted from the top.
+func GradeFor(scale []models.GradeBand, percent float64) string {
+	for _, band := range scale {
+		if percent >= band.MinPercent {
+			return band.Grade
+		}
+	}
+	return ""
+}
+
+// Build computes the gradebook of a course as of now. Only lessons that have already
+// started are included, so the percentages are running averages. A graded lesson
+// counts with its effective points; work missing after the deadline counts as zero;
+// everything else is left out until it is graded. A student only answers for the
+// lessons held while they were enrolled.
+func Build(tx *gorm.DB, courseID uint, now time.Time) (Book, error) {
+	book := Book{CourseID: courseID}
+
+	// курс нужен только ради его рубрики
+	var course models.Course
+	if err := tx.Where("id = ?", courseID).Limit(1).Find(&course).Error; err != nil {
+		return book, err
+	}
+
+	scheme, err := Scheme(tx, courseID)
+	if err != nil {
+		return book, err
+	}
+	book.Scheme = scheme
+
+	var lessons []models.Lesson
+	err = tx.Where("course_id = ? AND status = 'scheduled' AND start_date <= ?", courseID, now).
+		Order("start_date, id").
+		Find(&lessons).Error
+	if err != nil {
+		return book, err
+	}
+
+	// в журнал попадают записи на курс, пересекающиеся с уже прошедшей частью курса
+	termStart := now
+	if len(lessons) > 0 {
+		termStart = lessons[0].StartDate
+	}
+
+	var enrollments []models.EnrolledCourse
+	err = tx.Where("course_id = ? AND start_date <= ? AND end_date >= ?", courseID, now, termStart).
+		Find(&enrollments).Error
+	if err != nil {
+		return book, err
+	}
+
+	spans := map[uint][]models.EnrolledCourse{}
+	studentIDs := make([]uint, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		if spans[enrollment.UserID] == nil {
+			studentIDs = append(studentIDs, enrollment.UserID)
+		}
+		spans[enrollment.UserID] = append(spans[enrollment.UserID], enrollment)
+	}
+
+	var students []models.User
+	if len(studentIDs) > 0 {
+		err = tx.Where("id IN ?", studentIDs).Order("last_name, first_name").Find(&students).Error
+		if err != nil {
+			return book, err
+		}
+	}
+
+	lessonIDs := make([]uint, 0, len(lessons))
+	for _, lesson := range lessons {
+		lessonIDs = append(lessonIDs, lesson.ID)
+	}
+
+	rubrics, err := homework.RubricsFor(tx, course, lessons)
+	if err != nil {
+		return book, err
+	}
+
+	for _, lesson := range lessons {
+		book.Columns = append(book.Columns, columnFor(lesson, rubrics[lesson.ID]))
+	}
+
+	type key struct{ lesson, user uint }
+	submissions := map[key]models.UsersHomework{}
+
+	if len(lessonIDs) > 0 {
+		var rows []models.UsersHomework
+		if err := tx.Where("lesson_id IN ?", lessonIDs).Find(&rows).Error; err != nil {
+			return book, err
+		}
+		for _, row := range rows {
+			submissions[key{row.LessonID, row.UserID}] = row
+		}
+	}
+
+	rates, err := attendanceRates(tx, courseID, now)
+	if err != nil {
+		return book, err
+	}
+
+	for _, student := range students {
+		row := Row{UserID: student.ID, FirstName: student.FirstName, LastName: student.LastName, Categories: map[string]*float64{}}
+
+		earned := map[string]uint{}
+		possible := map[string]uint{}
+
+		for _, column := range book.Columns {
+			var cell Cell
+			if !enrolledOn(spans[student.ID], column.Date) {
+				cell.NotEnrolled = true
+				row.Cells = append(row.Cells, cell)
+				continue
+			}
+
+			submission, ok := submissions[key{column.LessonID, student.ID}]
+
+			switch {
+			case ok && submission.Status == homework.StatusGraded:
+				points := submission.EffectivePoints
+				cell.Points = &points
+				earned[column.Category] += min(points, column.MaxPoints)
+				possible[column.Category] += column.MaxPoints
+			case ok:
+				cell.Pending = true
+			case column.DueDate != nil && now.After(*column.DueDate):
+				cell.Missing = true
+				possible[column.Category] += column.MaxPoints
+			}
+
+			row.Cells = append(row.Cells, cell)
+		}
+
+		for category, outOf := range possible {
+			if outOf > 0 {
+				percent := float64(earned[category]) * 100 / float64(outOf)
+				row.Categories[category] = &percent
+			}
+		}
+
+		if rate, ok := rates[student.ID]; ok {
+			row.Categories[CategoryAttendance] = &rate
+		}
+
+		row.Percent = weighted(scheme, row.Categories)
+		if row.Percent != nil {
+			row.Grade = GradeFor(scheme.Scale, *row.Percent)
+		}
+
+		book.Rows = append(book.Rows, row)
+	}
+
+	return book, nil
+}
+
+// weighted averages the categories that have data, spreading the weight of empty
+// categories over the rest so that an early-term grade is not dragged down.
+func weighted(scheme models.GradingScheme, categories map[string]*float64) *float64 {
+	var sum float64
+	var weights uint
+
+	for _, category := range Categories {
+		percent := categories[category]
+		weight := Weight(scheme, category)
+		if percent == nil || weight == 0 {
+			continue
+		}
+		sum += *percent * float64(weight)
+		weights += weight
+	}
+
+	if weights == 0 {
+		return nil
+	}
+
+	total := sum / float64(weights)
+	return &total
+}
+
+// enrolledOn reports whether one of the enrollments covers the date, the way
+// attendance.EnrolledStudents decides who belongs to a lesson.
+func enrolledOn(enrollments []models.EnrolledCourse, date time.Time) bool {
+	for _, enrollment := range enrollments {
+		if !enrollment.StartDate.After(date) && !enrollment.EndDate.Before(date) {
+			return true
+		}
+	}
+	return false
+}
+
+func columnFor(lesson models.Lesson, rubric *models.Rubric) Column {
+	column := Column{
+		LessonID:  lesson.ID,
+		Name:      lesson.Name,
+		Category:  lesson.Category,
+		Date:      lesson.StartDate,
+		MaxPoints: lesson.MaxPoints,
+		DueDate:   lesson.DueDate,
+	}
+
+	// при рубрике максимум задаёт она, а не настройка занятия
+	if rubric != nil {
+		if best := homework.MaxPoints(*rubric); best > 0 {
+			column.MaxPoints = best
+		}
+	}
+
+	return column
+}
+
+func attendanceRates(tx *gorm.DB, courseID uint, now time.Time) (map[uint]float64, error) {
+	var rows []struct {
+		UserID uint
+		Status string
+		Total  int64
+	}
+
+	err := tx.Model(&models.Attendance{}).
+		Joins("JOIN lessons ON lessons.id = attendances.lesson_id").
+		Where("lessons.course_id = ? AND lessons.start_date <= ? AND lessons.status <> ?", courseID, now, "cancelled").
+		Select("attendances.user_id, attendances.status, COUNT(*) AS total").
+		Group("attendances.user_id, attendances.status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := map[uint]map[string]int64{}
+	for _, row := range rows {
+		if byUser[row.UserID] == nil {
+			byUser[row.UserID] = map[string]int64{}
+		}
+		byUser[row.UserID][row.Status] = row.Total
+	}
+
+	rates := map[uint]float64{}
+	for userID, byStatus := range byUser {
+		counts := attendance.Summarize(byStatus)
+		// одни уважительные пропуски не дают процента посещаемости
+		if counts.Present+counts.Late+counts.Absent > 0 {
+			rates[userID] = counts.Rate
+		}
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,32 @@
+package homework
+
+import (
+	"codev_erp/db/models"
+	"errors"
+)
+
+const (
+	AnchorLines  = "lines"
+	AnchorRegion = "region"
+)
+
+var ErrInvalidAnchor = errors.New("an anchor needs a line range, or a region inside the image or page")
+
+// ValidateAnchor checks that the anchor is well formed for its kind.
+func ValidateAnchor(anchor models.AnnotationAnchor) error {
+	switch anchor.Kind {
+	case AnchorLines:
+		if anchor.StartLine < 1 || anchor.EndLine < anchor.StartLine {
+			return ErrInvalidAnchor
+		}
+	case AnchorRegion:
+		inside := anchor.X >= 0 && anchor.Y >= 0 && anchor.Width > 0 && anchor.Height > 0 &&
+			anchor.X+anchor.Width <= 1 && anchor.Y+anchor.Height <= 1
+		if !inside || anchor.Page < 0 {
+			return ErrInvalidAnchor
+		}
+	default:
+		return ErrInvalidAnchor
+	}
+	return nil
+}
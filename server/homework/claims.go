@@ -0,0 +1,65 @@
+package homework
+
+import (
+	"codev_erp/db/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ClaimTTL is how long a claim keeps other teachers away from a homework; a teacher
+// who walks away without grading doesn't block the work for good.
+const ClaimTTL = 30 * time.Minute
+
+var ErrClaimed = errors.New("another teacher is already grading this homework")
+
+// Claim reserves the homework for the teacher. Claiming it again renews the claim;
+// a live claim of another teacher makes it fail with ErrClaimed.
+func Claim(tx *gorm.DB, homeworkID uint, teacherID uint, now time.Time) error {
+	result := tx.Model(&models.UsersHomework{}).
+		Where("id = ? AND (claimed_by IS NULL OR claimed_by = ? OR claimed_at < ?)", homeworkID, teacherID, now.Add(-ClaimTTL)).
+		Updates(map[string]interface{}{"claimed_by": teacherID, "claimed_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrClaimed
+	}
+	return nil
+}
+
+// SaveGrade writes the grade (or the request for changes) set on the submission and
+// drops the claim, unless another teacher holds a live claim, which makes it fail with
+// ErrClaimed. The check is part of the update, so a claim taken meanwhile is respected.
+func SaveGrade(tx *gorm.DB, submission *models.UsersHomework, teacherID uint, now time.Time) error {
+	submission.ClaimedBy = nil
+	submission.ClaimedAt = nil
+
+	result := tx.Model(submission).
+		Where("claimed_by IS NULL OR claimed_by = ? OR claimed_at < ?", teacherID, now.Add(-ClaimTTL)).
+		Select("Points", "Breakdown", "EffectivePoints", "Comment", "Checked", "Status", "ClaimedBy", "ClaimedAt").
+		Updates(submission)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrClaimed
+	}
+	return nil
+}
+
+// Release drops the teacher's own claim; other teachers' claims are left alone.
+func Release(tx *gorm.DB, homeworkID uint, teacherID uint) error {
+	return tx.Model(&models.UsersHomework{}).
+		Where("id = ? AND claimed_by = ?", homeworkID, teacherID).
+		Updates(map[string]interface{}{"claimed_by": nil, "claimed_at": nil}).Error
+}
+
+// ClaimHolder returns the teacher holding a live claim on the homework, if any.
+func ClaimHolder(submission models.UsersHomework, now time.Time) *uint {
+	if submission.ClaimedBy == nil || submission.ClaimedAt == nil || submission.ClaimedAt.Add(ClaimTTL).Before(now) {
+		return nil
+	}
+	return submission.ClaimedBy
+}
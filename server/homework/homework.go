@@ -0,0 +1,80 @@
+package homework
+
+import (
+	"codev_erp/db/models"
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PolicyAccept   = "accept"
+	PolicyPenalize = "penalize"
+	PolicyReject   = "reject"
+)
+
+var ErrPastCutoff = errors.New("the deadline has passed and late submissions are not accepted")
+
+// Lateness describes a submission relative to the lesson's due date.
+type Lateness struct {
+	Late           bool
+	DaysLate       uint
+	PenaltyPercent uint
+}
+
+func ValidPolicy(policy string) bool {
+	return policy == PolicyAccept || policy == PolicyPenalize || policy == PolicyReject
+}
+
+// HasWork reports whether a lesson hands out homework files or has homework handed
+// in. Such a lesson is graded through its submissions and cannot also have a quiz.
+func HasWork(tx *gorm.DB, lessonID uint) (bool, error) {
+	var count int64
+	err := tx.Table("lesson_task_homework_files").
+		Joins("JOIN lesson_tasks ON lesson_tasks.id = lesson_task_homework_files.lesson_tasks_id").
+		Where("lesson_tasks.lesson_id = ?", lessonID).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	err = tx.Model(&models.HomeworkVersion{}).
+		Joins("JOIN users_homeworks ON users_homeworks.id = homework_versions.homework_id").
+		Where("users_homeworks.lesson_id = ?", lessonID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Evaluate applies the course's late policy to a submission. Every started day after
+// the deadline counts as a day late.
+func Evaluate(course models.Course, due *time.Time, submittedAt time.Time) (Lateness, error) {
+	var lateness Lateness
+
+	if due == nil || !submittedAt.After(*due) {
+		return lateness, nil
+	}
+
+	lateness.Late = true
+	lateness.DaysLate = uint(math.Ceil(submittedAt.Sub(*due).Hours() / 24))
+
+	switch course.LatePolicy {
+	case PolicyReject:
+		if lateness.DaysLate > course.LateCutoffDays {
+			return lateness, ErrPastCutoff
+		}
+	case PolicyPenalize:
+		if course.LateCutoffDays > 0 && lateness.DaysLate > course.LateCutoffDays {
+			return lateness, ErrPastCutoff
+		}
+		lateness.PenaltyPercent = min(lateness.DaysLate*course.LatePenaltyPercent, 100)
+	}
+
+	return lateness, nil
+}
+
+// EffectivePoints applies the late penalty to the grade.
+func EffectivePoints(points uint, penaltyPercent uint) uint {
+	return uint(math.Round(float64(points) * float64(100-min(penaltyPercent, 100)) / 100))
+}
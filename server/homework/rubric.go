@@ -0,0 +1,150 @@
+package homework
+
+import (
+	"codev_erp/db/models"
+	"errors"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+var ErrIncompleteScores = errors.New("every rubric criterion needs a level")
+
+// ScoreInput is the level a grader picked for one criterion.
+type ScoreInput struct {
+	CriterionID uint   `json:"criterionID"`
+	LevelID     uint   `json:"levelID"`
+	Comment     string `json:"comment"`
+}
+
+// LoadRubric fetches a rubric with its criteria and levels in display order.
+func LoadRubric(tx *gorm.DB, rubricID uint) (models.Rubric, error) {
+	var rubric models.Rubric
+	err := withCriteria(tx).Where("id = ?", rubricID).First(&rubric).Error
+	return rubric, err
+}
+
+func withCriteria(tx *gorm.DB) *gorm.DB {
+	return tx.
+		Preload("Criteria", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("Criteria.Levels", func(db *gorm.DB) *gorm.DB { return db.Order("points, id") })
+}
+
+// RubricFor returns the rubric that applies to the lesson: its own, or the course's.
+// It returns nil when the lesson is graded with plain points.
+func RubricFor(tx *gorm.DB, lesson models.Lesson) (*models.Rubric, error) {
+	rubricID := lesson.RubricID
+	if rubricID == nil {
+		var course models.Course
+		if err := tx.Where("id = ?", lesson.CourseID).First(&course).Error; err != nil {
+			return nil, err
+		}
+		rubricID = course.RubricID
+	}
+
+	if rubricID == nil {
+		return nil, nil
+	}
+
+	rubric, err := LoadRubric(tx, *rubricID)
+	if err != nil {
+		return nil, err
+	}
+	return &rubric, nil
+}
+
+// RubricsFor is RubricFor for lessons of one course, keyed by lesson ID; every rubric
+// is loaded once. Lessons graded with plain points are left out.
+func RubricsFor(tx *gorm.DB, course models.Course, lessons []models.Lesson) (map[uint]*models.Rubric, error) {
+	rubricOf := map[uint]uint{}
+	var ids []uint
+
+	for _, lesson := range lessons {
+		rubricID := lesson.RubricID
+		if rubricID == nil {
+			rubricID = course.RubricID
+		}
+		if rubricID == nil {
+			continue
+		}
+		rubricOf[lesson.ID] = *rubricID
+		if !slices.Contains(ids, *rubricID) {
+			ids = append(ids, *rubricID)
+		}
+	}
+
+	byLesson := map[uint]*models.Rubric{}
+	if len(ids) == 0 {
+		return byLesson, nil
+	}
+
+	var rubrics []models.Rubric
+	if err := withCriteria(tx).Where("id IN ?", ids).Find(&rubrics).Error; err != nil {
+		return nil, err
+	}
+
+	byID := map[uint]*models.Rubric{}
+	for i := range rubrics {
+		byID[rubrics[i].ID] = &rubrics[i]
+	}
+	for lessonID, rubricID := range rubricOf {
+		if rubric, ok := byID[rubricID]; ok {
+			byLesson[lessonID] = rubric
+		}
+	}
+	return byLesson, nil
+}
+
+// Score turns the chosen levels into a breakdown and its total.
+func Score(rubric models.Rubric, inputs []ScoreInput) ([]models.CriterionScore, uint, error) {
+	chosen := map[uint]ScoreInput{}
+	for _, input := range inputs {
+		chosen[input.CriterionID] = input
+	}
+
+	var breakdown []models.CriterionScore
+	var total uint
+
+	for _, criterion := range rubric.Criteria {
+		input, ok := chosen[criterion.ID]
+		if !ok {
+			return nil, 0, ErrIncompleteScores
+		}
+
+		score := models.CriterionScore{CriterionID: criterion.ID, Criterion: criterion.Name, Comment: input.Comment}
+		found := false
+
+		for _, level := range criterion.Levels {
+			score.MaxPoints = max(score.MaxPoints, level.Points)
+			if level.ID == input.LevelID {
+				score.LevelID = level.ID
+				score.Level = level.Name
+				score.Points = level.Points
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, 0, fmt.Errorf("level %d does not belong to criterion %q", input.LevelID, criterion.Name)
+		}
+
+		total += score.Points
+		breakdown = append(breakdown, score)
+	}
+
+	return breakdown, total, nil
+}
+
+// MaxPoints is the best total the rubric allows.
+func MaxPoints(rubric models.Rubric) uint {
+	var total uint
+	for _, criterion := range rubric.Criteria {
+		var best uint
+		for _, level := range criterion.Levels {
+			best = max(best, level.Points)
+		}
+		total += best
+	}
+	return total
+}
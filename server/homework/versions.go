@@ -0,0 +1,233 @@
+package homework
+
+import (
+	"bytes"
+	"codev_erp/db/models"
+	"codev_erp/storage"
+	"crypto/sha256"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	StatusSubmitted        = "submitted"
+	StatusChangesRequested = "changes_requested"
+	StatusGraded           = "graded"
+
+	GradeKindGrade            = "grade"
+	GradeKindChangesRequested = "changes_requested"
+	GradeKindQuiz             = "quiz"
+
+	// maxDiffBytes and maxDiffLines bound the time of the line diff; larger files are
+	// only compared by checksum.
+	maxDiffBytes = 256 * 1024
+	maxDiffLines = 3000
+)
+
+// DiffLine is one line of a unified line diff: Op is " ", "+" or "-".
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// FileDiff compares one file between two versions.
+type FileDiff struct {
+	Name   string     `json:"name"`
+	Status string     `json:"status"` // added, removed, changed, unchanged
+	Binary bool       `json:"binary"`
+	Lines  []DiffLine `json:"lines,omitempty"`
+}
+
+// Compare matches the files of two versions by original name and diffs text files line by line.
+func Compare(from models.HomeworkVersion, to models.HomeworkVersion) []FileDiff {
+	before := map[string]models.Attachment{}
+	for _, file := range from.Files {
+		before[file.OriginalName] = file
+	}
+
+	var diffs []FileDiff
+	seen := map[string]bool{}
+
+	for _, file := range to.Files {
+		seen[file.OriginalName] = true
+
+		old, ok := before[file.OriginalName]
+		if !ok {
+			diffs = append(diffs, FileDiff{Name: file.OriginalName, Status: "added"})
+			continue
+		}
+		diffs = append(diffs, compareFile(old, file))
+	}
+
+	for _, file := range from.Files {
+		if !seen[file.OriginalName] {
+			diffs = append(diffs, FileDiff{Name: file.OriginalName, Status: "removed"})
+		}
+	}
+
+	return diffs
+}
+
+func compareFile(oldFile models.Attachment, newFile models.Attachment) FileDiff {
+	diff := FileDiff{Name: newFile.OriginalName, Status: "changed"}
+
+	// контрольные суммы записаны при загрузке, одинаковые файлы не читаем
+	if oldFile.Checksum != "" && oldFile.Checksum == newFile.Checksum {
+		diff.Status = "unchanged"
+		return diff
+	}
+
+	oldData, errOld := storage.ReadFile(oldFile.Key)
+	newData, errNew := storage.ReadFile(newFile.Key)
+	if errOld != nil || errNew != nil {
+		diff.Binary = true
+		return diff
+	}
+
+	if sha256.Sum256(oldData) == sha256.Sum256(newData) {
+		diff.Status = "unchanged"
+		return diff
+	}
+
+	if !isText(oldData) || !isText(newData) {
+		diff.Binary = true
+		return diff
+	}
+
+	oldLines := strings.Split(string(oldData), "\n")
+	newLines := strings.Split(string(newData), "\n")
+	if len(oldLines) > maxDiffLines || len(newLines) > maxDiffLines {
+		diff.Binary = true
+		return diff
+	}
+
+	diff.Lines = diffLines(oldLines, newLines)
+	return diff
+}
+
+func isText(data []byte) bool {
+	return len(data) <= maxDiffBytes && utf8.Valid(data) && !bytes.Contains(data, []byte{0})
+}
+
+// diffLines is Myers' diff in its linear-space form: it splits the files where the
+// forward and backward searches meet and diffs both halves, so memory stays O(n+m).
+func diffLines(a []string, b []string) []DiffLine {
+	var lines []DiffLine
+	diffRange(a, b, &lines)
+	return lines
+}
+
+func diffRange(a []string, b []string, lines *[]DiffLine) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		*lines = append(*lines, DiffLine{Op: " ", Text: a[0]})
+		a, b = a[1:], b[1:]
+	}
+
+	common := 0
+	for common < len(a) && common < len(b) && a[len(a)-1-common] == b[len(b)-1-common] {
+		common++
+	}
+	suffix := a[len(a)-common:]
+	a, b = a[:len(a)-common], b[:len(b)-common]
+
+	x, y := -1, -1
+	if len(a) > 0 && len(b) > 0 {
+		x, y = middleSnake(a, b)
+	}
+
+	// без общей части (или без точки раздела) остаются только удаления и вставки
+	if x <= 0 && y <= 0 || x >= len(a) && y >= len(b) {
+		for _, line := range a {
+			*lines = append(*lines, DiffLine{Op: "-", Text: line})
+		}
+		for _, line := range b {
+			*lines = append(*lines, DiffLine{Op: "+", Text: line})
+		}
+	} else {
+		diffRange(a[:x], b[:y], lines)
+		diffRange(a[x:], b[y:], lines)
+	}
+
+	for _, line := range suffix {
+		*lines = append(*lines, DiffLine{Op: " ", Text: line})
+	}
+}
+
+// middleSnake runs the shortest edit search from both ends at once and returns the
+// point where the paths meet, or -1, -1 if they don't.
+func middleSnake(a []string, b []string) (int, int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	size := 2*maxD + 3
+
+	forward := make([]int, size)
+	backward := make([]int, size)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	odd := delta%2 != 0
+	// диагонали, ушедшие за край графа, дальше не проверяются
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			x := forward[i-1] + 1
+			if k == -d || k != d && forward[i-1] < forward[i+1] {
+				x = forward[i+1]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[i] = x
+
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				j := offset + delta - k
+				if j >= 0 && j < size && backward[j] != -1 && x >= n-backward[j] {
+					return x, y
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			i := offset + k
+			x := backward[i-1] + 1
+			if k == -d || k != d && backward[i-1] < backward[i+1] {
+				x = backward[i+1]
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[i] = x
+
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				j := offset + delta - k
+				if j >= 0 && j < size && forward[j] != -1 && forward[j] >= n-x {
+					return forward[j], forward[j] - (j - offset)
+				}
+			}
+		}
+	}
+
+	return -1, -1
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"codev_erp/billing"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/logger"
+	"codev_erp/notify"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RunDunning marks overdue installments and sends one reminder per escalation step:
+// due soon, overdue, final notice. Admins are copied from the overdue step on.
+func RunDunning(now time.Time) {
+	var installments []models.Installment
+
+	err := db.DB.
+		Preload("Enrollment.Course").
+		Preload("Enrollment.User").
+		Where("paid = ? AND due_date <= ?", false, now.Add(billing.DueSoonWindow)).
+		Find(&installments).Error
+
+	if err != nil {
+		logger.Log("Dunning: failed to load installments: "+err.Error(), slog.LevelError)
+		return
+	}
+
+	for _, installment := range installments {
+		stage := billing.DunningStage(installment, now)
+		overdue := billing.IsOverdueStage(stage)
+
+		updates := map[string]interface{}{}
+		if overdue != installment.Overdue {
+			updates["overdue"] = overdue
+		}
+
+		// если джоб долго не запускался, отправляем только самое позднее напоминание
+		escalate := billing.Escalates(stage, installment.ReminderStage)
+		if escalate {
+			updates["reminder_stage"] = stage
+			updates["last_reminder_at"] = now
+		}
+
+		if len(updates) == 0 {
+			continue
+		}
+
+		// условие на прежнюю стадию: параллельный запуск не отправит напоминание дважды
+		result := db.DB.Model(&models.Installment{}).
+			Where("id = ? AND reminder_stage = ?", installment.ID, installment.ReminderStage).
+			Updates(updates)
+		if result.Error != nil {
+			logger.Log(fmt.Sprintf("Dunning: failed to update installment %d: %s", installment.ID, result.Error.Error()), slog.LevelError)
+			continue
+		}
+
+		if escalate && result.RowsAffected == 1 {
+			sendReminder(installment, stage, now)
+		}
+	}
+
+	err = db.DB.Exec(`UPDATE enrolled_courses SET overdue = EXISTS (
+		SELECT 1 FROM installments
+		WHERE installments.enrollment_id = enrolled_courses.id AND installments.overdue AND NOT installments.paid)`).Error
+
+	if err != nil {
+		logger.Log("Dunning: failed to update enrollments: "+err.Error(), slog.LevelError)
+	}
+}
+
+func sendReminder(installment models.Installment, stage string, now time.Time) {
+	course := installment.Enrollment.Course.Name
+	amount := installment.Amount.String() + " " + installment.Currency
+	due := installment.DueDate.Format("02.01.2006")
+
+	var msg notify.Message
+
+	switch stage {
+	case billing.StageDueSoon:
+		msg = notify.Message{
+			Kind:  "payment_due_soon",
+			Title: "Payment due soon: " + course,
+			Body:  fmt.Sprintf("Installment #%d of %s for %s is due on %s.", installment.Number, amount, course, due),
+		}
+	case billing.StageOverdue:
+		msg = notify.Message{
+			Kind:  "payment_overdue",
+			Title: "Payment overdue: " + course,
+			Body: fmt.Sprintf("Installment #%d of %s for %s was due on %s and is now overdue. Please pay as soon as possible.",
+				installment.Number, amount, course, due),
+		}
+	case billing.StageFinalNotice:
+		msg = notify.Message{
+			Kind:  "payment_final_notice",
+			Title: "Final notice: " + course,
+			Body: fmt.Sprintf("Installment #%d of %s for %s is %d days overdue. Please pay immediately or contact the administration.",
+				installment.Number, amount, course, billing.DaysOverdue(installment, now)),
+		}
+	default:
+		return
+	}
+
+	notify.Send(installment.Enrollment.UserID, msg)
+
+	if billing.IsOverdueStage(stage) {
+		student := installment.Enrollment.User
+		msg.Body = fmt.Sprintf("%s %s (%s): %s", student.FirstName, student.LastName, student.Email, msg.Body)
+		notify.SendToRole("admin", msg)
+	}
+}
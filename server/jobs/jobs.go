@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"codev_erp/autograde"
+	"codev_erp/logger"
+	"codev_erp/peerreview"
+	"codev_erp/quiz"
+	"codev_erp/similarity"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Start launches the periodic background jobs. Each job runs once right away
+// and then on its own interval for the lifetime of the process.
+func Start() {
+	every(time.Hour, "dunning", RunDunning)
+	every(5*time.Second, "autograde", autograde.ProcessQueue)
+	every(time.Minute, "similarity", similarity.ProcessQueue)
+	every(10*time.Minute, "peer_review", peerreview.AssignDue)
+	every(time.Minute, "quiz", quiz.FinishExpired)
+}
+
+func every(interval time.Duration, name string, job func(now time.Time)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(name, job)
+			<-ticker.C
+		}
+	}()
+}
+
+// run keeps one failing job from taking down the server.
+func run(name string, job func(now time.Time)) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Log(fmt.Sprintf("Job %s panicked: %v", name, r), slog.LevelError)
+		}
+	}()
+
+	job(time.Now())
+}
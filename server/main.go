@@ -3,11 +3,17 @@ package main
 import (
 	"codev_erp/db"
 	"codev_erp/dto"
+	"codev_erp/endpoints"
+	"codev_erp/jobs"
 	"codev_erp/logger"
+	"codev_erp/payments"
 	"codev_erp/routes"
+	"codev_erp/storage"
 	"encoding/gob"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -30,10 +36,25 @@ func main() {
 	db.Connect()
 	db.GenerateTables()
 
+	//uploads: local ./static or an S3-compatible bucket; without it nothing can be saved
+	if err := storage.Setup(); err != nil {
+		logger.Log("Failed to set up storage: "+err.Error(), slog.LevelError)
+		os.Exit(1)
+	}
+
+	//payment providers; a misconfigured fake provider must not go live
+	if err := payments.Setup(); err != nil {
+		logger.Log("Failed to set up payments: "+err.Error(), slog.LevelError)
+		os.Exit(1)
+	}
+
+	//background jobs: overdue payments and reminders
+	jobs.Start()
+
 	gob.Register(dto.UserResponse{})
 
 	r := gin.Default()
-	r.Static("/static", "./static")
+	r.GET("/static/*name", endpoints.StaticHandler)
 	r.Static("/assets", "./templates")
 
 	gin.SetMode(gin.DebugMode)
@@ -72,12 +93,29 @@ func main() {
 	routes.LessonRoutes(r)
 	routes.LeadRoutes(r)
 	routes.SalesRoutes(r)
+	routes.DiscountRoutes(r)
+	routes.DocumentRoutes(r)
+	routes.BillingRoutes(r)
+	routes.NotificationRoutes(r)
+	routes.PaymentRoutes(r)
+	routes.PayrollRoutes(r)
+	routes.ReportRoutes(r)
+	routes.ScheduleRoutes(r)
+	routes.AttendanceRoutes(r)
+	routes.CalendarRoutes(r)
+	routes.RoomRoutes(r)
+	routes.StaffRoutes(r)
+	routes.RubricRoutes(r)
+	routes.GradebookRoutes(r)
+	routes.AutogradeRoutes(r)
+	routes.AnnotationRoutes(r)
+	routes.PeerReviewRoutes(r)
+	routes.QuizRoutes(r)
 
 	err := r.Run(":8080")
 
 	if err != nil {
 		panic(err)
-		return
 	}
 
 }
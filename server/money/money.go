@@ -0,0 +1,126 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used for courses created without an explicit currency.
+const DefaultCurrency = "AZN"
+
+// Amount is a monetary value kept in minor units (qəpik, cents) so that
+// billing arithmetic stays exact. It is serialized to JSON as a decimal number.
+type Amount int64
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// amountPattern is an optional minus, whole units and up to two decimals.
+var amountPattern = regexp.MustCompile(`^-?\d+([.,]\d{1,2})?$`)
+
+// Parse reads a decimal string such as "200", "199.9" or "199,99". Amounts that
+// don't fit into minor units are rejected.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if !amountPattern.MatchString(s) {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.ReplaceAll(s, ",", "."), "-")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	minor, _ := strconv.ParseInt(fraction, 10, 64)
+
+	if major > (math.MaxInt64-minor)/100 {
+		return 0, ErrInvalidAmount
+	}
+
+	amount := Amount(major*100 + minor)
+	if negative {
+		amount = -amount
+	}
+
+	return amount, nil
+}
+
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	minor := strconv.FormatInt(v%100, 10)
+	if len(minor) == 1 {
+		minor = "0" + minor
+	}
+
+	return sign + strconv.FormatInt(v/100, 10) + "." + minor
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and quoted decimal strings.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+	return nil
+}
+
+// Mul multiplies the amount by a whole quantity.
+func (a Amount) Mul(n int64) Amount {
+	return Amount(int64(a) * n)
+}
+
+// Split divides the amount into n parts that add up to the original value.
+// The remainder of the division goes to the first part.
+func (a Amount) Split(n int) []Amount {
+	if n <= 0 {
+		return nil
+	}
+
+	part := int64(a) / int64(n)
+	remainder := int64(a) - part*int64(n)
+
+	parts := make([]Amount, n)
+	for i := range parts {
+		parts[i] = Amount(part)
+	}
+	parts[0] += Amount(remainder)
+
+	return parts
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code.
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
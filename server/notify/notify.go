@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/logger"
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Message is delivered to a user over every configured channel.
+type Message struct {
+	Kind  string
+	Title string
+	Body  string
+}
+
+// Channel delivers a message to one user.
+type Channel interface {
+	Name() string
+	Send(user models.User, msg Message) error
+}
+
+var channels = defaultChannels()
+
+func defaultChannels() []Channel {
+	list := []Channel{inAppChannel{}}
+
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		list = append(list, emailChannel{
+			host:     host,
+			port:     envOr("SMTP_PORT", "587"),
+			user:     os.Getenv("SMTP_USER"),
+			password: os.Getenv("SMTP_PASSWORD"),
+			from:     envOr("SMTP_FROM", os.Getenv("SMTP_USER")),
+		})
+	}
+
+	return list
+}
+
+// Send delivers the message to a user. Failures are logged, not returned, so that
+// a broken mail server never blocks the action that triggered the notification.
+func Send(userID uint, msg Message) {
+	var user models.User
+	if err := db.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		logger.Log(fmt.Sprintf("Failed to notify user %d: %s", userID, err.Error()), slog.LevelError)
+		return
+	}
+
+	for _, channel := range channels {
+		if err := channel.Send(user, msg); err != nil {
+			logger.Log("Failed to send "+msg.Kind+" over "+channel.Name()+" to "+user.Email+": "+err.Error(), slog.LevelError)
+		}
+	}
+}
+
+// SendToRole delivers the message to every user with the role, e.g. all admins.
+func SendToRole(role string, msg Message) {
+	var ids []uint
+	db.DB.Model(&models.User{}).Where("role = ?", role).Pluck("id", &ids)
+
+	for _, id := range ids {
+		Send(id, msg)
+	}
+}
+
+type inAppChannel struct{}
+
+func (inAppChannel) Name() string { return "in-app" }
+
+func (inAppChannel) Send(user models.User, msg Message) error {
+	return db.DB.Create(&models.Notification{
+		UserID: user.ID,
+		Kind:   msg.Kind,
+		Title:  msg.Title,
+		Body:   msg.Body,
+	}).Error
+}
+
+type emailChannel struct {
+	host, port, user, password, from string
+}
+
+func (emailChannel) Name() string { return "email" }
+
+func (c emailChannel) Send(user models.User, msg Message) error {
+	var auth smtp.Auth
+	if c.user != "" {
+		auth = smtp.PlainAuth("", c.user, c.password, c.host)
+	}
+
+	body := strings.Join([]string{
+		"From: " + c.from,
+		"To: " + user.Email,
+		"Subject: " + msg.Title,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		msg.Body,
+	}, "\r\n")
+
+	return smtp.SendMail(c.host+":"+c.port, auth, c.from, []string{user.Email}, []byte(body))
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
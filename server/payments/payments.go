@@ -0,0 +1,93 @@
+package payments
+
+import (
+	"codev_erp/money"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// Event types delivered by provider webhooks.
+const (
+	EventSucceeded = "payment.succeeded"
+	EventFailed    = "payment.failed"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown payment provider")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrFakeSecret       = errors.New("PAYMENT_FAKE_ENABLED is set but PAYMENT_FAKE_SECRET is empty")
+)
+
+// Checkout describes what the student is asked to pay.
+type Checkout struct {
+	Reference   string
+	Amount      money.Amount
+	Currency    string
+	Description string
+	Email       string
+}
+
+// Session is the provider-side checkout the student is redirected to.
+type Session struct {
+	ProviderRef string
+	URL         string
+}
+
+// Event is a verified webhook notification about a checkout.
+type Event struct {
+	ID          string       `json:"id"`
+	Type        string       `json:"type"`
+	Reference   string       `json:"reference"`
+	ProviderRef string       `json:"providerRef"`
+	Amount      money.Amount `json:"amount"`
+	Currency    string       `json:"currency"`
+}
+
+// Provider is a payment gateway. ParseWebhook must reject requests whose
+// signature does not verify.
+type Provider interface {
+	Name() string
+	CreateCheckout(checkout Checkout) (Session, error)
+	ParseWebhook(r *http.Request) (Event, error)
+}
+
+var providers = map[string]Provider{}
+
+func Register(provider Provider) {
+	providers[provider.Name()] = provider
+}
+
+func Get(name string) (Provider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Setup registers the providers enabled by the environment. The fake provider lets
+// anyone complete a checkout, so it is only registered with PAYMENT_FAKE_ENABLED=1
+// and its own PAYMENT_FAKE_SECRET.
+func Setup() error {
+	if !FakeEnabled() {
+		return nil
+	}
+
+	secret := os.Getenv("PAYMENT_FAKE_SECRET")
+	if secret == "" {
+		return ErrFakeSecret
+	}
+	Register(&FakeProvider{Secret: secret})
+	return nil
+}
+
+// FakeEnabled reports whether the development provider and its checkout pages are on.
+func FakeEnabled() bool {
+	return os.Getenv("PAYMENT_FAKE_ENABLED") == "1"
+}
+
+// Default returns the provider selected with PAYMENT_PROVIDER; there is no fallback.
+func Default() (Provider, error) {
+	return Get(os.Getenv("PAYMENT_PROVIDER"))
+}
@@ -0,0 +1,288 @@
+package payroll
+
+import (
+	"codev_erp/attendance"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/money"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RatePerLesson  = "per_lesson"
+	RatePerHour    = "per_hour"
+	RatePerStudent = "per_student"
+
+	StatusDraft  = "draft"
+	StatusLocked = "locked"
+)
+
+var (
+	ErrInvalidPeriod = errors.New("period must look like 2026-10")
+	ErrLocked        = errors.New("statement is locked")
+	// Суммы статей складываются в один Gross, поэтому валюта у ставок преподавателя одна
+	ErrMixedCurrency = errors.New("all pay rates of a teacher must be in the same currency")
+)
+
+func ValidRateKind(kind string) bool {
+	return kind == RatePerLesson || kind == RatePerHour || kind == RatePerStudent
+}
+
+// CheckCurrency fails with ErrMixedCurrency when the teacher already has a pay rate
+// in another currency.
+func CheckCurrency(tx *gorm.DB, teacherID uint, currency string) error {
+	var count int64
+	err := tx.Model(&models.PayRate{}).Where("teacher_id = ? AND currency <> ?", teacherID, currency).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrMixedCurrency
+	}
+	return nil
+}
+
+// PeriodRange returns the first moment of the month and of the following month.
+func PeriodRange(period string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidPeriod
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// Run computes (or recomputes) the draft statement of every teacher for the period.
+// Locked statements are left untouched.
+func Run(period string) ([]models.PayrollStatement, error) {
+	if _, _, err := PeriodRange(period); err != nil {
+		return nil, err
+	}
+
+	var teacherIDs []uint
+	if err := db.DB.Model(&models.User{}).Where("role = ?", "teacher").Pluck("id", &teacherIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var statements []models.PayrollStatement
+	for _, teacherID := range teacherIDs {
+		statement, err := Compute(teacherID, period)
+		if errors.Is(err, ErrLocked) {
+			continue
+		}
+		if err != nil {
+			return statements, err
+		}
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+// Compute rebuilds the lines of a teacher's draft statement from the lessons held in
+// the period. Manual adjustments are kept across recomputations. Rates in several
+// currencies can't make up one statement and fail with ErrMixedCurrency.
+func Compute(teacherID uint, period string) (models.PayrollStatement, error) {
+	var statement models.PayrollStatement
+
+	start, end, err := PeriodRange(period)
+	if err != nil {
+		return statement, err
+	}
+
+	now := time.Now()
+	if end.After(now) {
+		end = now
+	}
+
+	lessons, err := heldLessons(teacherID, start, end)
+	if err != nil {
+		return statement, err
+	}
+
+	var rates []models.PayRate
+	if err := db.DB.Where("teacher_id = ?", teacherID).Find(&rates).Error; err != nil {
+		return statement, err
+	}
+	for _, rate := range rates {
+		if rate.Currency != rates[0].Currency {
+			return statement, fmt.Errorf("teacher %d: %w", teacherID, ErrMixedCurrency)
+		}
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(models.PayrollStatement{TeacherID: teacherID, Period: period}).
+			Attrs(models.PayrollStatement{Status: StatusDraft, Currency: money.DefaultCurrency}).
+			FirstOrCreate(&statement).Error
+		if err != nil {
+			return err
+		}
+
+		if statement.Status == StatusLocked {
+			return ErrLocked
+		}
+
+		if err := tx.Where("statement_id = ?", statement.ID).Delete(&models.PayrollLine{}).Error; err != nil {
+			return err
+		}
+
+		var lines []models.PayrollLine
+		for _, lesson := range lessons {
+			rate, ok := rateFor(rates, lesson)
+			if !ok {
+				continue
+			}
+
+			line, err := lessonLine(tx, statement.ID, lesson, rate)
+			if err != nil {
+				return err
+			}
+			statement.Currency = rate.Currency
+			lines = append(lines, line)
+		}
+
+		if len(lines) > 0 {
+			if err := tx.Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+
+		return refreshTotals(tx, &statement, lines, now)
+	})
+
+	return statement, err
+}
+
+// Recalculate refreshes a statement's totals after an adjustment changed.
+func Recalculate(tx *gorm.DB, statement *models.PayrollStatement) error {
+	var lines []models.PayrollLine
+	if err := tx.Where("statement_id = ?", statement.ID).Find(&lines).Error; err != nil {
+		return err
+	}
+	return refreshTotals(tx, statement, lines, statement.ComputedAt)
+}
+
+func refreshTotals(tx *gorm.DB, statement *models.PayrollStatement, lines []models.PayrollLine, computedAt time.Time) error {
+	var adjustments []models.PayrollAdjustment
+	if err := tx.Where("statement_id = ?", statement.ID).Find(&adjustments).Error; err != nil {
+		return err
+	}
+
+	statement.Gross = 0
+	for _, line := range lines {
+		statement.Gross += line.Amount
+	}
+
+	statement.Adjustments = 0
+	for _, adjustment := range adjustments {
+		statement.Adjustments += adjustment.Amount
+	}
+
+	statement.Net = statement.Gross + statement.Adjustments
+	statement.ComputedAt = computedAt
+
+	return tx.Model(statement).Select("gross", "adjustments", "net", "currency", "computed_at").Updates(statement).Error
+}
+
+// heldLessons lists the lessons the teacher gave in [start, end): their courses' lessons
+// unless a substitute took them, plus the lessons they substituted in.
+func heldLessons(teacherID uint, start time.Time, end time.Time) ([]models.Lesson, error) {
+	var lessons []models.Lesson
+
+	err := db.DB.
+		Preload("Course").
+		Joins("JOIN courses ON courses.id = lessons.course_id").
+		Where("COALESCE(lessons.substitute_id, courses.teacher_id) = ?", teacherID).
+		Where("lessons.start_date >= ? AND lessons.start_date < ?", start, end).
+		Where("lessons.status <> ?", "cancelled").
+		Order("lessons.start_date").
+		Find(&lessons).Error
+
+	return lessons, err
+}
+
+// rateFor picks the rate in effect on the lesson date, preferring a course-specific one.
+func rateFor(rates []models.PayRate, lesson models.Lesson) (models.PayRate, bool) {
+	var best models.PayRate
+	found := false
+
+	for _, rate := range rates {
+		if lesson.StartDate.Before(rate.ValidFrom) || (rate.ValidUntil != nil && !lesson.StartDate.Before(*rate.ValidUntil)) {
+			continue
+		}
+		if rate.CourseID != nil && *rate.CourseID != lesson.CourseID {
+			continue
+		}
+
+		specific := rate.CourseID != nil
+		bestSpecific := found && best.CourseID != nil
+
+		if !found || (specific && !bestSpecific) || (specific == bestSpecific && rate.ValidFrom.After(best.ValidFrom)) {
+			best = rate
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+func lessonLine(tx *gorm.DB, statementID uint, lesson models.Lesson, rate models.PayRate) (models.PayrollLine, error) {
+	description := fmt.Sprintf("%s: %s (%s)", lesson.Course.Name, lesson.Name, lesson.StartDate.Format("02.01.2006"))
+	if lesson.SubstituteID != nil {
+		description += ", substitute"
+	}
+
+	lessonID := lesson.ID
+	line := models.PayrollLine{
+		StatementID: statementID,
+		LessonID:    &lessonID,
+		CourseID:    lesson.CourseID,
+		Description: description,
+		RateKind:    rate.Kind,
+		Rate:        rate.Amount,
+	}
+
+	switch rate.Kind {
+	case RatePerLesson:
+		line.Quantity = 1
+	case RatePerHour:
+		line.Quantity = float64(lesson.Duration) / 60
+	case RatePerStudent:
+		students, err := studentsInLesson(tx, lesson)
+		if err != nil {
+			return line, err
+		}
+		line.Quantity = float64(students)
+	}
+
+	line.Amount = money.Amount(math.Round(float64(rate.Amount) * line.Quantity))
+	return line, nil
+}
+
+// studentsInLesson counts the students who attended the lesson. Lessons without any
+// attendance marks fall back to the students enrolled on the lesson date.
+func studentsInLesson(tx *gorm.DB, lesson models.Lesson) (int64, error) {
+	var marked, attended int64
+
+	err := tx.Model(&models.Attendance{}).Where("lesson_id = ?", lesson.ID).Count(&marked).Error
+	if err != nil {
+		return 0, err
+	}
+
+	if marked > 0 {
+		err := tx.Model(&models.Attendance{}).
+			Where("lesson_id = ? AND status IN ?", lesson.ID, []string{attendance.StatusPresent, attendance.StatusLate}).
+			Count(&attended).Error
+		return attended, err
+	}
+
+	var count int64
+	err = tx.Model(&models.EnrolledCourse{}).
+		Where("course_id = ? AND start_date <= ? AND end_date >= ?", lesson.CourseID, lesson.StartDate, lesson.StartDate).
+		Count(&count).Error
+	return count, err
+}
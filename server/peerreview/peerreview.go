@@ -0,0 +1,262 @@
+package peerreview
+
+import (
+	"codev_erp/attendance"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/homework"
+	"codev_erp/logger"
+	"codev_erp/notify"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatusAssigned  = "assigned"
+	StatusSubmitted = "submitted"
+)
+
+var (
+	ErrAlreadyAssigned = errors.New("peer reviews for this lesson have already been assigned")
+	ErrNotEnabled      = errors.New("peer review is not enabled for this lesson")
+)
+
+// Assign hands every submission of the lesson to PeerReviewCount other enrolled
+// students. Reviewers are picked by lowest load first, in random order, so the work
+// is spread evenly and nobody can predict whose homework they get.
+func Assign(tx *gorm.DB, lesson models.Lesson, now time.Time) ([]models.PeerReview, error) {
+	if lesson.PeerReviewCount == 0 {
+		return nil, ErrNotEnabled
+	}
+
+	// помечаем занятие первым, чтобы ручной запуск и джоб не распределили работы дважды
+	claim := tx.Model(&models.Lesson{}).
+		Where("id = ? AND peer_reviews_assigned_at IS NULL", lesson.ID).
+		Update("peer_reviews_assigned_at", now)
+	if claim.Error != nil {
+		return nil, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil, ErrAlreadyAssigned
+	}
+
+	reviewers, err := attendance.EnrolledStudents(tx, lesson)
+	if err != nil {
+		return nil, err
+	}
+
+	var submissions []models.UsersHomework
+	if err := tx.Where("lesson_id = ?", lesson.ID).Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+
+	rand.Shuffle(len(reviewers), func(i, j int) { reviewers[i], reviewers[j] = reviewers[j], reviewers[i] })
+	rand.Shuffle(len(submissions), func(i, j int) { submissions[i], submissions[j] = submissions[j], submissions[i] })
+
+	load := map[uint]int{}
+	var reviews []models.PeerReview
+
+	for _, submission := range submissions {
+		candidates := make([]uint, 0, len(reviewers))
+		for _, reviewer := range reviewers {
+			if reviewer != submission.UserID {
+				candidates = append(candidates, reviewer)
+			}
+		}
+
+		// стабильная сортировка сохраняет случайный порядок среди равных по нагрузке
+		sort.SliceStable(candidates, func(i, j int) bool { return load[candidates[i]] < load[candidates[j]] })
+
+		for _, reviewer := range candidates[:min(int(lesson.PeerReviewCount), len(candidates))] {
+			load[reviewer]++
+			reviews = append(reviews, models.PeerReview{
+				HomeworkID: submission.ID,
+				ReviewerID: reviewer,
+				Status:     StatusAssigned,
+				AssignedAt: now,
+			})
+		}
+	}
+
+	if len(reviews) > 0 {
+		if err := tx.Create(&reviews).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return reviews, nil
+}
+
+// AssignDue distributes peer reviews for lessons whose homework deadline has passed.
+func AssignDue(now time.Time) {
+	var lessons []models.Lesson
+
+	err := db.DB.
+		Where("peer_review_count > 0 AND peer_reviews_assigned_at IS NULL AND status = 'scheduled' AND due_date <= ?", now).
+		Find(&lessons).Error
+	if err != nil {
+		logger.Log("Peer review: failed to load lessons: "+err.Error(), slog.LevelError)
+		return
+	}
+
+	for _, lesson := range lessons {
+		var reviews []models.PeerReview
+
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			reviews, err = Assign(tx, lesson, now)
+			return err
+		})
+		if err != nil {
+			logger.Log(fmt.Sprintf("Peer review: failed to assign lesson %d: %s", lesson.ID, err.Error()), slog.LevelError)
+			continue
+		}
+
+		NotifyReviewers(lesson, reviews)
+	}
+}
+
+// NotifyReviewers sends each reviewer one message with the number of works to review.
+func NotifyReviewers(lesson models.Lesson, reviews []models.PeerReview) {
+	counts := map[uint]int{}
+	for _, review := range reviews {
+		counts[review.ReviewerID]++
+	}
+
+	for reviewer, count := range counts {
+		notify.Send(reviewer, notify.Message{
+			Kind:  "peer_review_assigned",
+			Title: "Peer review: " + lesson.Name,
+			Body:  fmt.Sprintf("You have %d classmates' homework to review for %s.", count, lesson.Name),
+		})
+	}
+}
+
+// Grade validates a review against the lesson's rubric, or caps plain points at the
+// lesson's maximum, and returns the points and breakdown to store.
+func Grade(tx *gorm.DB, lesson models.Lesson, points uint, scores []homework.ScoreInput) (uint, []models.CriterionScore, error) {
+	rubric, err := homework.RubricFor(tx, lesson)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if rubric == nil {
+		if points > lesson.MaxPoints {
+			return 0, nil, fmt.Errorf("points cannot exceed %d", lesson.MaxPoints)
+		}
+		return points, nil, nil
+	}
+
+	breakdown, total, err := homework.Score(*rubric, scores)
+	return total, breakdown, err
+}
+
+// CriterionAverage is the mean peer score for one rubric criterion.
+type CriterionAverage struct {
+	CriterionID uint    `json:"criterionID"`
+	Criterion   string  `json:"criterion"`
+	Average     float64 `json:"average"`
+	MaxPoints   uint    `json:"maxPoints"`
+}
+
+// Summary aggregates the submitted peer reviews of one submission.
+type Summary struct {
+	Assigned      int                `json:"assigned"`
+	Submitted     int                `json:"submitted"`
+	AveragePoints *float64           `json:"averagePoints"`
+	MedianPoints  *float64           `json:"medianPoints"`
+	Criteria      []CriterionAverage `json:"criteria,omitempty"`
+}
+
+// Summaries aggregates peer reviews per homework for the given submissions.
+func Summaries(tx *gorm.DB, homeworkIDs []uint) (map[uint]Summary, error) {
+	summaries := map[uint]Summary{}
+	if len(homeworkIDs) == 0 {
+		return summaries, nil
+	}
+
+	var reviews []models.PeerReview
+	if err := tx.Where("homework_id IN ?", homeworkIDs).Order("id").Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+
+	byHomework := map[uint][]models.PeerReview{}
+	for _, review := range reviews {
+		byHomework[review.HomeworkID] = append(byHomework[review.HomeworkID], review)
+	}
+
+	for homeworkID, reviews := range byHomework {
+		summaries[homeworkID] = summarize(reviews)
+	}
+
+	return summaries, nil
+}
+
+func summarize(reviews []models.PeerReview) Summary {
+	summary := Summary{Assigned: len(reviews)}
+
+	var points []float64
+	type criterionTotal struct {
+		name  string
+		sum   uint
+		count int
+		max   uint
+	}
+	criteria := map[uint]*criterionTotal{}
+	var order []uint
+
+	for _, review := range reviews {
+		if review.Status != StatusSubmitted {
+			continue
+		}
+		summary.Submitted++
+		points = append(points, float64(review.Points))
+
+		for _, score := range review.Breakdown {
+			total, ok := criteria[score.CriterionID]
+			if !ok {
+				total = &criterionTotal{name: score.Criterion, max: score.MaxPoints}
+				criteria[score.CriterionID] = total
+				order = append(order, score.CriterionID)
+			}
+			total.sum += score.Points
+			total.count++
+		}
+	}
+
+	if len(points) == 0 {
+		return summary
+	}
+
+	var sum float64
+	for _, p := range points {
+		sum += p
+	}
+	average := sum / float64(len(points))
+	summary.AveragePoints = &average
+
+	sort.Float64s(points)
+	median := points[len(points)/2]
+	if len(points)%2 == 0 {
+		median = (points[len(points)/2-1] + points[len(points)/2]) / 2
+	}
+	summary.MedianPoints = &median
+
+	for _, id := range order {
+		total := criteria[id]
+		summary.Criteria = append(summary.Criteria, CriterionAverage{
+			CriterionID: id,
+			Criterion:   total.name,
+			Average:     float64(total.sum) / float64(total.count),
+			MaxPoints:   total.max,
+		})
+	}
+
+	return summary
+}
@@ -0,0 +1,339 @@
+package quiz
+
+import (
+	"codev_erp/attendance"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/homework"
+	"codev_erp/logger"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AttemptInProgress = "in_progress"
+	AttemptSubmitted  = "submitted"
+
+	// grace absorbs network delay: answers sent right at the deadline are still taken.
+	grace = 30 * time.Second
+)
+
+var (
+	ErrNotEnrolled     = errors.New("you are not enrolled in this course")
+	ErrNotOpen         = errors.New("the quiz is not open")
+	ErrNoAttemptsLeft  = errors.New("no attempts left")
+	ErrAttemptFinished = errors.New("the attempt has already been submitted")
+	ErrTimeUp          = errors.New("the time for this attempt is up")
+	// оценка теста пишется в ту же запись занятия, что и оценка ДЗ, поэтому совмещать их нельзя
+	ErrLessonHasHomework = errors.New("the lesson has homework; a quiz would overwrite its grades")
+	ErrLessonHasQuiz     = errors.New("the lesson is graded by its quiz; delete the quiz to hand out homework")
+)
+
+// PaperQuestion is a question as the student sees it: no correct options or answers.
+type PaperQuestion struct {
+	ID      uint          `json:"id"`
+	Kind    string        `json:"kind"`
+	Text    string        `json:"text"`
+	Code    string        `json:"code,omitempty"`
+	Points  uint          `json:"points"`
+	Options []PaperOption `json:"options,omitempty"`
+}
+
+type PaperOption struct {
+	ID   uint   `json:"id"`
+	Text string `json:"text"`
+}
+
+// Load returns a quiz with its questions in order.
+func Load(tx *gorm.DB, lessonID interface{}) (models.Quiz, error) {
+	var quiz models.Quiz
+	err := tx.Preload("Questions", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Where("lesson_id = ?", lessonID).
+		First(&quiz).Error
+	return quiz, err
+}
+
+// Exists reports whether the lesson has a quiz.
+func Exists(tx *gorm.DB, lessonID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.Quiz{}).Where("lesson_id = ?", lessonID).Count(&count).Error
+	return count > 0, err
+}
+
+// Start opens a new attempt, or returns the student's attempt that is still running.
+// Questions are drawn, and their options ordered, once per attempt.
+func Start(tx *gorm.DB, quiz models.Quiz, lesson models.Lesson, userID uint, now time.Time) (models.QuizAttempt, error) {
+	enrolled, err := attendance.EnrolledStudents(tx, lesson)
+	if err != nil {
+		return models.QuizAttempt{}, err
+	}
+	if !slices.Contains(enrolled, userID) {
+		return models.QuizAttempt{}, ErrNotEnrolled
+	}
+
+	var attempts []models.QuizAttempt
+	if err := tx.Where("quiz_id = ? AND user_id = ?", quiz.ID, userID).Order("number").Find(&attempts).Error; err != nil {
+		return models.QuizAttempt{}, err
+	}
+
+	if len(attempts) > 0 {
+		last := attempts[len(attempts)-1]
+		if last.Status == AttemptInProgress {
+			if !expired(last, now) {
+				return last, nil
+			}
+			if err := Submit(tx, quiz, lesson, &last, nil, now); err != nil && !errors.Is(err, ErrAttemptFinished) {
+				return models.QuizAttempt{}, err
+			}
+		}
+	}
+
+	if (quiz.OpensAt != nil && now.Before(*quiz.OpensAt)) || (quiz.ClosesAt != nil && !now.Before(*quiz.ClosesAt)) {
+		return models.QuizAttempt{}, ErrNotOpen
+	}
+	if quiz.MaxAttempts > 0 && uint(len(attempts)) >= quiz.MaxAttempts {
+		return models.QuizAttempt{}, ErrNoAttemptsLeft
+	}
+
+	attempt := models.QuizAttempt{
+		QuizID:    quiz.ID,
+		UserID:    userID,
+		Number:    uint(len(attempts)) + 1,
+		Status:    AttemptInProgress,
+		Drawn:     draw(quiz),
+		StartedAt: now,
+	}
+
+	if quiz.TimeLimitMinutes > 0 {
+		deadline := now.Add(time.Duration(quiz.TimeLimitMinutes) * time.Minute)
+		attempt.Deadline = &deadline
+	}
+	if quiz.ClosesAt != nil && (attempt.Deadline == nil || quiz.ClosesAt.Before(*attempt.Deadline)) {
+		attempt.Deadline = quiz.ClosesAt
+	}
+
+	// уникальный индекс (quiz, user, number) не даст открыть две попытки одновременно
+	if err := tx.Omit("Quiz", "User").Create(&attempt).Error; err != nil {
+		return models.QuizAttempt{}, err
+	}
+
+	return attempt, nil
+}
+
+// draw picks the questions of a new attempt: a random subset when QuestionCount is
+// set, in random order when ShuffleQuestions is set, with options shuffled if asked.
+func draw(quiz models.Quiz) []models.QuizDrawnQuestion {
+	questions := slices.Clone(quiz.Questions)
+
+	if quiz.ShuffleQuestions || (quiz.QuestionCount > 0 && quiz.QuestionCount < uint(len(questions))) {
+		rand.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	}
+	if quiz.QuestionCount > 0 && quiz.QuestionCount < uint(len(questions)) {
+		questions = questions[:quiz.QuestionCount]
+		if !quiz.ShuffleQuestions {
+			sort.SliceStable(questions, func(i, j int) bool { return questions[i].Position < questions[j].Position })
+		}
+	}
+
+	drawn := make([]models.QuizDrawnQuestion, 0, len(questions))
+	for _, question := range questions {
+		d := models.QuizDrawnQuestion{QuestionID: question.ID}
+		for _, option := range question.Options {
+			d.OptionIDs = append(d.OptionIDs, option.ID)
+		}
+		if quiz.ShuffleOptions {
+			rand.Shuffle(len(d.OptionIDs), func(i, j int) { d.OptionIDs[i], d.OptionIDs[j] = d.OptionIDs[j], d.OptionIDs[i] })
+		}
+		drawn = append(drawn, d)
+	}
+
+	return drawn
+}
+
+// Paper lays out the attempt's questions for the student, in the drawn order.
+func Paper(quiz models.Quiz, attempt models.QuizAttempt) []PaperQuestion {
+	byID := map[uint]models.QuizQuestion{}
+	for _, question := range quiz.Questions {
+		byID[question.ID] = question
+	}
+
+	paper := make([]PaperQuestion, 0, len(attempt.Drawn))
+	for _, d := range attempt.Drawn {
+		question, ok := byID[d.QuestionID]
+		if !ok {
+			continue
+		}
+
+		options := map[uint]string{}
+		for _, option := range question.Options {
+			options[option.ID] = option.Text
+		}
+
+		p := PaperQuestion{ID: question.ID, Kind: question.Kind, Text: question.Text, Code: question.Code, Points: question.Points}
+		for _, id := range d.OptionIDs {
+			p.Options = append(p.Options, PaperOption{ID: id, Text: options[id]})
+		}
+		paper = append(paper, p)
+	}
+
+	return paper
+}
+
+// SaveAnswers stores the answers given so far, so a dropped connection loses nothing.
+func SaveAnswers(tx *gorm.DB, attempt *models.QuizAttempt, answers []models.QuizAnswer, now time.Time) error {
+	if attempt.Status != AttemptInProgress {
+		return ErrAttemptFinished
+	}
+	if expired(*attempt, now) {
+		return ErrTimeUp
+	}
+
+	attempt.Answers = keepDrawn(*attempt, answers)
+	return tx.Model(attempt).Select("answers").Updates(models.QuizAttempt{Answers: attempt.Answers}).Error
+}
+
+// Submit scores the attempt and records the grade. Answers, if given, replace the saved
+// ones while time remains; after the deadline the saved answers are scored.
+func Submit(tx *gorm.DB, quiz models.Quiz, lesson models.Lesson, attempt *models.QuizAttempt, answers []models.QuizAnswer, now time.Time) error {
+	if attempt.Status != AttemptInProgress {
+		return ErrAttemptFinished
+	}
+	if answers != nil && !expired(*attempt, now) {
+		attempt.Answers = keepDrawn(*attempt, answers)
+	}
+
+	questions := map[uint]models.QuizQuestion{}
+	for _, question := range quiz.Questions {
+		questions[question.ID] = question
+	}
+
+	attempt.Results, attempt.Score, attempt.MaxScore = Score(attempt.Drawn, questions, attempt.Answers)
+	attempt.Status = AttemptSubmitted
+	attempt.SubmittedAt = &now
+
+	// попытку закрывает только тот, кто застал её открытой: повторная отправка и джоб не посчитают её дважды
+	result := tx.Model(&models.QuizAttempt{}).
+		Where("id = ? AND status = ?", attempt.ID, AttemptInProgress).
+		Select("status", "answers", "results", "score", "max_score", "submitted_at").
+		Updates(models.QuizAttempt{
+			Status:      attempt.Status,
+			Answers:     attempt.Answers,
+			Results:     attempt.Results,
+			Score:       attempt.Score,
+			MaxScore:    attempt.MaxScore,
+			SubmittedAt: attempt.SubmittedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAttemptFinished
+	}
+
+	return recordGrade(tx, lesson, *attempt, now)
+}
+
+// FinishExpired submits the attempts whose time ran out while the student was away.
+func FinishExpired(now time.Time) {
+	var attempts []models.QuizAttempt
+	err := db.DB.Where("status = ? AND deadline < ?", AttemptInProgress, now.Add(-grace)).Find(&attempts).Error
+	if err != nil {
+		logger.Log("Quiz: failed to load expired attempts: "+err.Error(), slog.LevelError)
+		return
+	}
+
+	for _, attempt := range attempts {
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			var quiz models.Quiz
+			err := tx.Preload("Questions").Preload("Lesson").Where("id = ?", attempt.QuizID).First(&quiz).Error
+			if err != nil {
+				return err
+			}
+			return Submit(tx, quiz, quiz.Lesson, &attempt, nil, now)
+		})
+		if err != nil && !errors.Is(err, ErrAttemptFinished) {
+			logger.Log(fmt.Sprintf("Quiz: failed to submit attempt %d: %s", attempt.ID, err.Error()), slog.LevelError)
+		}
+	}
+}
+
+// recordGrade makes the best attempt the student's grade for the lesson and adds the
+// attempt to the grade history. A quiz lesson has no homework (see ErrLessonHasHomework),
+// so the lesson's record belongs to the quiz alone.
+func recordGrade(tx *gorm.DB, lesson models.Lesson, attempt models.QuizAttempt, now time.Time) error {
+	var best models.QuizAttempt
+	err := tx.Where("quiz_id = ? AND user_id = ? AND status = ? AND max_score > 0", attempt.QuizID, attempt.UserID, AttemptSubmitted).
+		Order("score::float / max_score DESC, number").
+		First(&best).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// в попытке не оказалось вопросов — оценивать нечего
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	submission := models.UsersHomework{UserID: attempt.UserID, LessonID: lesson.ID, Version: 1}
+	if err := tx.Where("lesson_id = ? AND user_id = ?", lesson.ID, attempt.UserID).FirstOrCreate(&submission).Error; err != nil {
+		return err
+	}
+
+	submission.Points = scale(best, lesson.MaxPoints)
+	submission.EffectivePoints = submission.Points
+	submission.Breakdown = nil
+	submission.Comment = fmt.Sprintf("Quiz: %d/%d (attempt %d)", best.Score, best.MaxScore, best.Number)
+	submission.Checked = true
+	submission.Status = homework.StatusGraded
+
+	if err := tx.Omit("User", "Lesson").Save(&submission).Error; err != nil {
+		return err
+	}
+
+	points := scale(attempt, lesson.MaxPoints)
+	return tx.Create(&models.GradeRecord{
+		HomeworkID:      submission.ID,
+		Version:         submission.Version,
+		Kind:            homework.GradeKindQuiz,
+		Points:          points,
+		EffectivePoints: points,
+		Comment:         fmt.Sprintf("Quiz attempt %d: %d/%d", attempt.Number, attempt.Score, attempt.MaxScore),
+		GradedAt:        now,
+	}).Error
+}
+
+// scale converts an attempt's score to the lesson's points.
+func scale(attempt models.QuizAttempt, maxPoints uint) uint {
+	if attempt.MaxScore == 0 {
+		return 0
+	}
+	return uint(math.Round(float64(attempt.Score) / float64(attempt.MaxScore) * float64(maxPoints)))
+}
+
+func expired(attempt models.QuizAttempt, now time.Time) bool {
+	return attempt.Deadline != nil && now.After(attempt.Deadline.Add(grace))
+}
+
+// keepDrawn drops answers to questions that are not part of the attempt.
+func keepDrawn(attempt models.QuizAttempt, answers []models.QuizAnswer) []models.QuizAnswer {
+	drawn := map[uint]bool{}
+	for _, d := range attempt.Drawn {
+		drawn[d.QuestionID] = true
+	}
+
+	kept := []models.QuizAnswer{}
+	for _, answer := range answers {
+		if drawn[answer.QuestionID] {
+			kept = append(kept, answer)
+			drawn[answer.QuestionID] = false // один ответ на вопрос
+		}
+	}
+	return kept
+}
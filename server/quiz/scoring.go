@@ -0,0 +1,137 @@
+package quiz
+
+import (
+	"codev_erp/autograde"
+	"codev_erp/db/models"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	KindSingle     = "single"
+	KindMultiple   = "multiple"
+	KindShort      = "short"
+	KindNumeric    = "numeric"
+	KindCodeOutput = "code_output"
+)
+
+// ValidateQuestion checks that a question can be scored and numbers its options.
+func ValidateQuestion(question *models.QuizQuestion) error {
+	if strings.TrimSpace(question.Text) == "" {
+		return errors.New("every question needs a text")
+	}
+	if question.Points == 0 {
+		question.Points = 1
+	}
+
+	switch question.Kind {
+	case KindSingle, KindMultiple:
+		correct := 0
+		for i := range question.Options {
+			question.Options[i].ID = uint(i + 1)
+			if question.Options[i].Correct {
+				correct++
+			}
+		}
+		if len(question.Options) < 2 {
+			return fmt.Errorf("%q needs at least two options", question.Text)
+		}
+		if question.Kind == KindSingle && correct != 1 {
+			return fmt.Errorf("%q must have exactly one correct option", question.Text)
+		}
+		if correct == 0 {
+			return fmt.Errorf("%q must have a correct option", question.Text)
+		}
+	case KindShort, KindCodeOutput:
+		if len(question.Answers) == 0 {
+			return fmt.Errorf("%q needs at least one accepted answer", question.Text)
+		}
+	case KindNumeric:
+		if question.Number == nil || question.Tolerance < 0 {
+			return fmt.Errorf("%q needs a number and a non-negative tolerance", question.Text)
+		}
+	default:
+		return fmt.Errorf("unknown question kind %q", question.Kind)
+	}
+
+	return nil
+}
+
+// Check tells whether the answer to the question is correct. Choice questions are
+// all-or-nothing: every correct option and no other must be chosen.
+func Check(question models.QuizQuestion, answer models.QuizAnswer) bool {
+	switch question.Kind {
+	case KindSingle, KindMultiple:
+		var correct []uint
+		for _, option := range question.Options {
+			if option.Correct {
+				correct = append(correct, option.ID)
+			}
+		}
+		chosen := slices.Clone(answer.OptionIDs)
+		slices.Sort(chosen)
+		return slices.Equal(slices.Compact(chosen), correct)
+	case KindShort:
+		given := normalizeText(answer.Text, question.CaseSensitive)
+		for _, accepted := range question.Answers {
+			if given != "" && given == normalizeText(accepted, question.CaseSensitive) {
+				return true
+			}
+		}
+	case KindCodeOutput:
+		// вывод программы сравниваем так же, как автопроверка
+		given := autograde.NormalizeOutput(answer.Text)
+		for _, accepted := range question.Answers {
+			if given == autograde.NormalizeOutput(accepted) {
+				return true
+			}
+		}
+	case KindNumeric:
+		value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(answer.Text), ",", "."), 64)
+		return err == nil && question.Number != nil && math.Abs(value-*question.Number) <= question.Tolerance
+	}
+	return false
+}
+
+// Score checks every drawn question of an attempt. Unanswered questions score nothing.
+func Score(drawn []models.QuizDrawnQuestion, questions map[uint]models.QuizQuestion, answers []models.QuizAnswer) ([]models.QuizQuestionResult, uint, uint) {
+	given := map[uint]models.QuizAnswer{}
+	for _, answer := range answers {
+		given[answer.QuestionID] = answer
+	}
+
+	var results []models.QuizQuestionResult
+	var score, maxScore uint
+
+	for _, d := range drawn {
+		question, ok := questions[d.QuestionID]
+		if !ok {
+			continue
+		}
+
+		result := models.QuizQuestionResult{QuestionID: question.ID, MaxPoints: question.Points}
+		if answer, answered := given[question.ID]; answered && Check(question, answer) {
+			result.Correct = true
+			result.Points = question.Points
+		}
+
+		score += result.Points
+		maxScore += result.MaxPoints
+		results = append(results, result)
+	}
+
+	return results, score, maxScore
+}
+
+// normalizeText ignores surrounding and repeated whitespace, and case unless asked not to.
+func normalizeText(text string, caseSensitive bool) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if !caseSensitive {
+		text = strings.ToLower(text)
+	}
+	return text
+}
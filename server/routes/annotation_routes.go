@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"codev_erp/endpoints/annotation_handlers"
+	"codev_erp/endpoints/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AnnotationRoutes(r *gin.Engine) {
+
+	participants := middleware.ValidateAnyUser("student", "teacher", "admin")
+
+	r.GET("/lesson_tasks/submissions/:id/annotations", participants, annotation_handlers.GetAnnotationsHandler)
+	r.POST("/lesson_tasks/submissions/:id/annotations", middleware.ValidateAnyUser("teacher", "admin"), annotation_handlers.AddAnnotationHandler)
+
+	r.PUT("/annotations/:id", participants, annotation_handlers.UpdateAnnotationHandler)
+	r.DELETE("/annotations/:id", participants, annotation_handlers.DeleteAnnotationHandler)
+	r.POST("/annotations/:id/resolve", participants, annotation_handlers.ResolveAnnotationHandler)
+	r.POST("/annotations/:id/reopen", participants, annotation_handlers.ReopenAnnotationHandler)
+	r.POST("/annotations/:id/replies", participants, annotation_handlers.AddReplyHandler)
+
+	r.PUT("/annotation_replies/:id", participants, annotation_handlers.UpdateReplyHandler)
+	r.DELETE("/annotation_replies/:id", participants, annotation_handlers.DeleteReplyHandler)
+
+}
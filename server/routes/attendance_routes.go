@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"codev_erp/endpoints/attendance_handlers"
+	"codev_erp/endpoints/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AttendanceRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+
+	r.GET("/lessons/:id/attendance", staff, attendance_handlers.GetLessonAttendanceHandler)
+	r.PUT("/lessons/:id/attendance", staff, attendance_handlers.MarkAttendanceHandler)
+
+	r.POST("/lessons/:id/check-in", staff, attendance_handlers.OpenCheckInHandler)
+	r.GET("/lessons/:id/check-in", staff, attendance_handlers.GetCheckInCodeHandler)
+	r.DELETE("/lessons/:id/check-in", staff, attendance_handlers.CloseCheckInHandler)
+
+	// попытки ограничены для каждого студента и занятия (attendance.CheckIn)
+	r.POST("/check-in", middleware.ValidateUser("student"), attendance_handlers.CheckInHandler)
+
+	r.GET("/attendance/students/:id", middleware.ValidateAnyUser("student", "teacher", "admin"), attendance_handlers.GetStudentStatsHandler)
+	r.GET("/attendance/courses/:id", staff, attendance_handlers.GetCourseStatsHandler)
+
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"codev_erp/endpoints/autograde_handlers"
+	"codev_erp/endpoints/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AutogradeRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+
+	r.GET("/lessons/:id/test_suite", staff, autograde_handlers.GetTestSuiteHandler)
+	r.PUT("/lessons/:id/test_suite", staff, autograde_handlers.SaveTestSuiteHandler)
+	r.DELETE("/lessons/:id/test_suite", staff, autograde_handlers.DeleteTestSuiteHandler)
+
+	r.GET("/lesson_tasks/submissions/:id/test_runs", middleware.ValidateAnyUser("student", "teacher", "admin"), autograde_handlers.GetTestRunsHandler)
+	r.POST("/lesson_tasks/submissions/:id/test_runs", staff, autograde_handlers.RerunTestsHandler)
+
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"codev_erp/endpoints/billing_handlers"
+	"codev_erp/endpoints/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func BillingRoutes(r *gin.Engine) {
+
+	r.GET("/billing/overdue", middleware.ValidateUser("admin"), billing_handlers.GetOverdueReportHandler)
+
+}
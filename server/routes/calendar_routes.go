@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"codev_erp/endpoints/calendar_handlers"
+	"codev_erp/endpoints/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CalendarRoutes(r *gin.Engine) {
+
+	// без сессии: календарные клиенты передают только токен в ссылке
+	r.GET("/calendar/:token", calendar_handlers.GetFeedHandler)
+
+	anyone := middleware.ValidateAnyUser("student", "teacher", "admin")
+
+	r.GET("/calendar-token", anyone, calendar_handlers.GetTokenHandler)
+	r.POST("/calendar-token", anyone, calendar_handlers.RegenerateTokenHandler)
+	r.DELETE("/calendar-token", anyone, calendar_handlers.RevokeTokenHandler)
+
+}
@@ -17,8 +17,10 @@ func CourseRoutes(r *gin.Engine) {
 
 	r.POST("/courses", middleware.ValidateUser("admin"), course_handlers.AddCourseHandler)
 	r.DELETE("/courses/:id", middleware.ValidateUser("admin"), course_handlers.DeleteCourseHandler)
+	r.PUT("/courses/:id/late_policy", middleware.ValidateAnyUser("teacher", "admin"), course_handlers.UpdateLatePolicyHandler)
 	r.GET("/courses/:id/participants", middleware.ValidateUser("admin"), course_handlers.GetCourseParticipantsHandler)
 	r.POST("/courses/:id/participants", middleware.ValidateUser("admin"), course_handlers.AddParticipantHandler)
 	r.DELETE("/courses/:id/participants/:studentId", middleware.ValidateUser("admin"), course_handlers.RemoveParticipantHandler)
+	r.GET("/courses/:id/participants/:studentId/installments", middleware.ValidateUser("admin"), course_handlers.GetInstallmentsHandler)
 
 }
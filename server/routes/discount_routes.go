@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"codev_erp/endpoints/discount_handlers"
+	"codev_erp/endpoints/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DiscountRoutes(r *gin.Engine) {
+
+	r.GET("/discounts", middleware.ValidateUser("admin"), discount_handlers.GetDiscountsHandler)
+	r.POST("/discounts", middleware.ValidateUser("admin"), discount_handlers.AddDiscountHandler)
+	r.PUT("/discounts/:id", middleware.ValidateUser("admin"), discount_handlers.UpdateDiscountHandler)
+	r.DELETE("/discounts/:id", middleware.ValidateUser("admin"), discount_handlers.DeleteDiscountHandler)
+
+}
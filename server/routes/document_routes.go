@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"codev_erp/endpoints/document_handlers"
+	"codev_erp/endpoints/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DocumentRoutes(r *gin.Engine) {
+
+	r.GET("/documents", document_handlers.GetDocumentsHandler)
+	r.GET("/documents/:id/download", document_handlers.DownloadDocumentHandler)
+
+	r.POST("/documents", middleware.ValidateUser("admin"), document_handlers.IssueDocumentHandler)
+	r.GET("/documents/branding", middleware.ValidateUser("admin"), document_handlers.GetBrandingHandler)
+	r.PUT("/documents/branding", middleware.ValidateUser("admin"), document_handlers.UpdateBrandingHandler)
+
+}
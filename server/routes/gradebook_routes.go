@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"codev_erp/endpoints/gradebook_handlers"
+	"codev_erp/endpoints/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GradebookRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+
+	r.GET("/courses/:id/gradebook", staff, gradebook_handlers.GetGradebookHandler)
+	r.GET("/courses/:id/gradebook/export", staff, gradebook_handlers.ExportGradebookHandler)
+	r.GET("/courses/:id/grading_scheme", staff, gradebook_handlers.GetGradingSchemeHandler)
+	r.PUT("/courses/:id/grading_scheme", staff, gradebook_handlers.UpdateGradingSchemeHandler)
+	r.GET("/courses/:id/my_grade", middleware.ValidateUser("student"), gradebook_handlers.GetMyGradeHandler)
+
+	r.PUT("/lessons/:id/grading", staff, gradebook_handlers.SetLessonGradingHandler)
+
+}
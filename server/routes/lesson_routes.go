@@ -16,11 +16,24 @@ func LessonRoutes(r *gin.Engine) {
 	r.POST("/lesson_tasks", middleware.ValidateUser("teacher"), lesson_handlers.AddTasksHandler)
 	r.POST("/lesson_tasks/screenrecord", middleware.ValidateUser("teacher"), lesson_handlers.ScreenRecordHandler)
 	r.GET("/lesson_tasks/:id", lesson_handlers.GetLessonTasksHandler)
+	r.PUT("/lesson_tasks/:id/due_date", middleware.ValidateAnyUser("teacher", "admin"), lesson_handlers.SetDueDateHandler)
 	r.GET("/lesson_tasks/download/:file", lesson_handlers.FileDownloadHandler)
 
-	r.POST("/lesson_tasks/homework", lesson_handlers.SubmitHomeworkHandler)
+	r.POST("/lesson_tasks/homework", middleware.ValidateUser("student"), lesson_handlers.SubmitHomeworkHandler)
 	r.GET("/lesson_tasks/list_homeworks/:id", middleware.ValidateUser("teacher"), lesson_handlers.ListHomeworkHandler)
+	r.GET("/lesson_tasks/list_homeworks/:id/similarity", middleware.ValidateUser("teacher"), lesson_handlers.GetSimilarityReportHandler)
+	r.POST("/lesson_tasks/list_homeworks/:id/similarity", middleware.ValidateUser("teacher"), lesson_handlers.RequestSimilarityReportHandler)
 	r.POST("/lesson_tasks/submissions/:id", middleware.ValidateUser("teacher"), lesson_handlers.GradeHomeworkHandler)
+	r.POST("/lessons/:id/scores", middleware.ValidateUser("teacher"), lesson_handlers.ScoreLessonHandler)
+	r.POST("/lesson_tasks/submissions/:id/request_changes", middleware.ValidateUser("teacher"), lesson_handlers.RequestChangesHandler)
+	r.GET("/grading_queue", middleware.ValidateAnyUser("teacher", "admin"), lesson_handlers.GetGradingQueueHandler)
+	r.POST("/grading_queue/grade", middleware.ValidateAnyUser("teacher", "admin"), lesson_handlers.BatchGradeHandler)
+	r.POST("/grading_queue/:id/claim", middleware.ValidateAnyUser("teacher", "admin"), lesson_handlers.ClaimHomeworkHandler)
+	r.DELETE("/grading_queue/:id/claim", middleware.ValidateAnyUser("teacher", "admin"), lesson_handlers.ReleaseHomeworkHandler)
+	r.GET("/lesson_tasks/submissions/:id/versions", lesson_handlers.GetHomeworkVersionsHandler)
+	r.GET("/lesson_tasks/submissions/:id/files/:key", lesson_handlers.DownloadHomeworkFileHandler)
+	r.GET("/lesson_tasks/submissions/:id/compare", lesson_handlers.CompareHomeworkVersionsHandler)
+	r.GET("/lesson_tasks/submissions/:id/history", lesson_handlers.GetGradeHistoryHandler)
 
 	r.GET("/lesson_tasks/get_grades", lesson_handlers.ViewGradesHandler)
 }
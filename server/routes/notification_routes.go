@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"codev_erp/endpoints/notification_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NotificationRoutes(r *gin.Engine) {
+
+	r.GET("/notifications", notification_handlers.GetNotificationsHandler)
+	r.PUT("/notifications/:id/read", notification_handlers.MarkNotificationReadHandler)
+
+}
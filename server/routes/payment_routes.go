@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/payment_handlers"
+	"codev_erp/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PaymentRoutes(r *gin.Engine) {
+
+	r.POST("/payments/checkout", payment_handlers.CreateCheckoutHandler)
+
+	//called by payment providers, authenticated by webhook signature instead of session
+	r.POST("/payments/webhook/:provider", payment_handlers.WebhookHandler)
+
+	r.GET("/payments/ledger", middleware.ValidateUser("admin"), payment_handlers.GetLedgerHandler)
+
+	//development checkout; PAYMENT_FAKE_ENABLED=1 only
+	if payments.FakeEnabled() {
+		r.GET("/payments/fake/checkout/:reference", payment_handlers.FakeCheckoutPageHandler)
+		r.POST("/payments/fake/checkout/:reference", payment_handlers.FakeCheckoutCompleteHandler)
+	}
+
+}
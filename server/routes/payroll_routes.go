@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/payroll_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PayrollRoutes(r *gin.Engine) {
+
+	r.GET("/payroll/statements", payroll_handlers.GetStatementsHandler)
+	r.GET("/payroll/statements/:id", payroll_handlers.GetStatementHandler)
+
+	r.GET("/payroll/rates", middleware.ValidateUser("admin"), payroll_handlers.GetRatesHandler)
+	r.POST("/payroll/rates", middleware.ValidateUser("admin"), payroll_handlers.AddRateHandler)
+	r.DELETE("/payroll/rates/:id", middleware.ValidateUser("admin"), payroll_handlers.DeleteRateHandler)
+	r.POST("/payroll/runs", middleware.ValidateUser("admin"), payroll_handlers.RunPayrollHandler)
+	r.POST("/payroll/statements/:id/adjustments", middleware.ValidateUser("admin"), payroll_handlers.AddAdjustmentHandler)
+	r.POST("/payroll/statements/:id/lock", middleware.ValidateUser("admin"), payroll_handlers.LockStatementHandler)
+
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/peer_review_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PeerReviewRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+	student := middleware.ValidateUser("student")
+
+	r.PUT("/lessons/:id/peer_review", staff, peer_review_handlers.SetPeerReviewHandler)
+	r.POST("/lessons/:id/peer_review/assign", staff, peer_review_handlers.AssignPeerReviewsHandler)
+
+	r.GET("/peer_reviews", student, peer_review_handlers.GetMyPeerReviewsHandler)
+	r.GET("/peer_reviews/:id", student, peer_review_handlers.GetPeerReviewHandler)
+	r.GET("/peer_reviews/:id/files/:name", student, peer_review_handlers.DownloadPeerReviewFileHandler)
+	r.POST("/peer_reviews/:id", student, peer_review_handlers.SubmitPeerReviewHandler)
+
+	r.GET("/lesson_tasks/submissions/:id/peer_reviews", middleware.ValidateAnyUser("student", "teacher", "admin"), peer_review_handlers.GetReceivedPeerReviewsHandler)
+
+}
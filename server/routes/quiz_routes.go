@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/quiz_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func QuizRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+	student := middleware.ValidateUser("student")
+	anyone := middleware.ValidateAnyUser("student", "teacher", "admin")
+
+	r.GET("/lessons/:id/quiz", anyone, quiz_handlers.GetQuizHandler)
+	r.PUT("/lessons/:id/quiz", staff, quiz_handlers.SaveQuizHandler)
+	r.DELETE("/lessons/:id/quiz", staff, quiz_handlers.DeleteQuizHandler)
+
+	r.GET("/lessons/:id/quiz/attempts", staff, quiz_handlers.GetQuizAttemptsHandler)
+	r.POST("/lessons/:id/quiz/attempts", student, quiz_handlers.StartQuizAttemptHandler)
+
+	r.GET("/quiz_attempts/:id", anyone, quiz_handlers.GetQuizAttemptHandler)
+	r.PUT("/quiz_attempts/:id/answers", student, quiz_handlers.SaveQuizAnswersHandler)
+	r.POST("/quiz_attempts/:id/submit", student, quiz_handlers.SubmitQuizAttemptHandler)
+
+}
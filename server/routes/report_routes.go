@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/report_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReportRoutes(r *gin.Engine) {
+
+	r.GET("/reports/revenue", middleware.ValidateUser("admin"), report_handlers.GetRevenueHandler)
+	r.GET("/reports/receivables", middleware.ValidateUser("admin"), report_handlers.GetReceivablesHandler)
+	r.GET("/reports/projected", middleware.ValidateUser("admin"), report_handlers.GetProjectedRevenueHandler)
+
+}
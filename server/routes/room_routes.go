@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/room_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RoomRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+
+	r.GET("/rooms", staff, room_handlers.GetRoomsHandler)
+	r.POST("/rooms", middleware.ValidateUser("admin"), room_handlers.AddRoomHandler)
+	r.PUT("/rooms/:id", middleware.ValidateUser("admin"), room_handlers.UpdateRoomHandler)
+	r.DELETE("/rooms/:id", middleware.ValidateUser("admin"), room_handlers.DeleteRoomHandler)
+
+	r.GET("/resources", staff, room_handlers.GetResourcesHandler)
+	r.POST("/resources", middleware.ValidateUser("admin"), room_handlers.AddResourceHandler)
+	r.PUT("/resources/:id", middleware.ValidateUser("admin"), room_handlers.UpdateResourceHandler)
+	r.DELETE("/resources/:id", middleware.ValidateUser("admin"), room_handlers.DeleteResourceHandler)
+
+	r.PUT("/lessons/:id/booking", staff, room_handlers.BookLessonHandler)
+	r.GET("/freebusy", staff, room_handlers.FreeBusyHandler)
+
+}
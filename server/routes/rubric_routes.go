@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/rubric_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RubricRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+
+	r.GET("/rubrics", staff, rubric_handlers.GetRubricsHandler)
+	r.GET("/rubrics/:id", staff, rubric_handlers.GetRubricHandler)
+	r.POST("/rubrics", staff, rubric_handlers.AddRubricHandler)
+	r.DELETE("/rubrics/:id", staff, rubric_handlers.DeleteRubricHandler)
+
+	r.GET("/lessons/:id/rubric", middleware.ValidateAnyUser("student", "teacher", "admin"), rubric_handlers.GetLessonRubricHandler)
+	r.PUT("/lessons/:id/rubric", staff, rubric_handlers.SetLessonRubricHandler)
+	r.PUT("/courses/:id/rubric", staff, rubric_handlers.SetCourseRubricHandler)
+
+}
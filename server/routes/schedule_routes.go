@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/schedule_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ScheduleRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+
+	r.GET("/courses/:id/schedules", staff, schedule_handlers.GetSchedulesHandler)
+	r.POST("/courses/:id/schedules", staff, schedule_handlers.AddScheduleHandler)
+	r.POST("/courses/:id/schedules/generate", staff, schedule_handlers.GenerateLessonsHandler)
+	r.DELETE("/schedules/:id", staff, schedule_handlers.DeleteScheduleHandler)
+	r.POST("/schedules/:id/exceptions", staff, schedule_handlers.AddExceptionHandler)
+
+	r.PUT("/lessons/:id/reschedule", staff, schedule_handlers.RescheduleLessonHandler)
+	r.POST("/lessons/:id/cancel", staff, schedule_handlers.CancelLessonHandler)
+
+	r.GET("/holidays", staff, schedule_handlers.GetHolidaysHandler)
+	r.POST("/holidays", middleware.ValidateUser("admin"), schedule_handlers.AddHolidayHandler)
+	r.DELETE("/holidays/:id", middleware.ValidateUser("admin"), schedule_handlers.DeleteHolidayHandler)
+
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"codev_erp/endpoints/middleware"
+	"codev_erp/endpoints/staff_handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func StaffRoutes(r *gin.Engine) {
+
+	staff := middleware.ValidateAnyUser("teacher", "admin")
+	admin := middleware.ValidateUser("admin")
+
+	r.GET("/teachers/:id/availability", staff, staff_handlers.GetAvailabilityHandler)
+	r.PUT("/teachers/:id/availability", staff, staff_handlers.SetAvailabilityHandler)
+
+	r.GET("/teachers/:id/leaves", staff, staff_handlers.GetLeavesHandler)
+	r.POST("/teachers/:id/leaves", admin, staff_handlers.AddLeaveHandler)
+	r.DELETE("/leaves/:id", admin, staff_handlers.DeleteLeaveHandler)
+
+	r.GET("/lessons/:id/substitute/candidates", admin, staff_handlers.GetSubstituteCandidatesHandler)
+	r.PUT("/lessons/:id/substitute", admin, staff_handlers.AssignSubstituteHandler)
+	r.DELETE("/lessons/:id/substitute", admin, staff_handlers.RemoveSubstituteHandler)
+
+}
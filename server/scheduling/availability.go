@@ -0,0 +1,73 @@
+package scheduling
+
+import (
+	"codev_erp/db/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TeacherOf returns who teaches a lesson: the substitute if one is assigned,
+// otherwise the course's teacher.
+func TeacherOf(lesson models.Lesson) *uint {
+	if lesson.SubstituteID != nil {
+		return lesson.SubstituteID
+	}
+	return lesson.Course.TeacherID
+}
+
+// OnLeave returns the teacher's leave that intersects [start, end), if any.
+func OnLeave(tx *gorm.DB, teacherID uint, start time.Time, end time.Time) (*models.TeacherLeave, error) {
+	var leaves []models.TeacherLeave
+
+	err := tx.Where("teacher_id = ? AND start_date < ? AND end_date > ?", teacherID, end, start).
+		Limit(1).
+		Find(&leaves).Error
+	if err != nil || len(leaves) == 0 {
+		return nil, err
+	}
+	return &leaves[0], nil
+}
+
+// WithinAvailability reports whether [start, end) fits into one of the teacher's
+// weekly windows. Teachers who never set windows are always available.
+func WithinAvailability(tx *gorm.DB, teacherID uint, start time.Time, end time.Time) (bool, error) {
+	var windows []models.TeacherAvailability
+	if err := tx.Where("teacher_id = ?", teacherID).Find(&windows).Error; err != nil {
+		return false, err
+	}
+
+	if len(windows) == 0 {
+		return true, nil
+	}
+
+	for _, window := range windows {
+		loc, err := Location(window.Timezone)
+		if err != nil {
+			continue
+		}
+
+		local := start.In(loc)
+		if int(local.Weekday()) != window.Weekday {
+			continue
+		}
+
+		fromHour, fromMinute, err := ParseClock(window.StartTime)
+		if err != nil {
+			continue
+		}
+		toHour, toMinute, err := ParseClock(window.EndTime)
+		if err != nil {
+			continue
+		}
+
+		from := time.Date(local.Year(), local.Month(), local.Day(), fromHour, fromMinute, 0, 0, loc)
+		to := time.Date(local.Year(), local.Month(), local.Day(), toHour, toMinute, 0, 0, loc)
+
+		if !start.Before(from) && !end.After(to) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,165 @@
+package scheduling
+
+import (
+	"codev_erp/db/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ConflictRoom     = "room"
+	ConflictTeacher  = "teacher"
+	ConflictGroup    = "group"
+	ConflictResource = "resource"
+)
+
+// Booking is a lesson slot to be checked before it is created or moved.
+type Booking struct {
+	LessonID     uint // 0 для нового занятия
+	CourseID     uint
+	Start        time.Time
+	Duration     uint
+	RoomID       *uint
+	ResourceIDs  []uint
+	SubstituteID *uint
+}
+
+func (b Booking) End() time.Time {
+	return b.Start.Add(time.Duration(b.Duration) * time.Minute)
+}
+
+// Conflict is a scheduled lesson that overlaps the booking.
+type Conflict struct {
+	Kind       string    `json:"kind"`
+	LessonID   uint      `json:"lessonID"`
+	LessonName string    `json:"lessonName"`
+	CourseID   uint      `json:"courseID"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+}
+
+// Conflicts lists the scheduled lessons that would clash with the booking: the same
+// room, the same teacher, shared students, or a shared resource. Lessons in ignore
+// (e.g. a series being moved together) are not reported.
+func Conflicts(tx *gorm.DB, booking Booking, ignore []uint) ([]Conflict, error) {
+	if booking.LessonID != 0 {
+		ignore = append(ignore, booking.LessonID)
+	}
+
+	overlapping := func() *gorm.DB {
+		query := Overlapping(tx, booking.Start, booking.End())
+		if len(ignore) > 0 {
+			query = query.Where("lessons.id NOT IN ?", ignore)
+		}
+		return query
+	}
+
+	var conflicts []Conflict
+	type seenKey struct {
+		kind     string
+		lessonID uint
+	}
+	seen := map[seenKey]bool{}
+
+	collect := func(kind string, query *gorm.DB) error {
+		var lessons []models.Lesson
+		if err := query.Select("lessons.*").Find(&lessons).Error; err != nil {
+			return err
+		}
+
+		for _, lesson := range lessons {
+			key := seenKey{kind, lesson.ID}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			conflicts = append(conflicts, conflictWith(kind, lesson))
+		}
+		return nil
+	}
+
+	if booking.RoomID != nil {
+		if err := collect(ConflictRoom, overlapping().Where("lessons.room_id = ?", *booking.RoomID)); err != nil {
+			return nil, err
+		}
+	}
+
+	var course models.Course
+	if err := tx.Where("id = ?", booking.CourseID).First(&course).Error; err != nil {
+		return nil, err
+	}
+
+	teacherID := course.TeacherID
+	if booking.SubstituteID != nil {
+		teacherID = booking.SubstituteID
+	}
+
+	if teacherID != nil {
+		query := TaughtBy(overlapping(), *teacherID)
+		if err := collect(ConflictTeacher, query); err != nil {
+			return nil, err
+		}
+	}
+
+	// курсы, у которых на дату занятия есть общие с этим курсом студенты
+	sharedStudents := tx.Table("enrolled_courses AS mine").
+		Select("theirs.course_id").
+		Joins("JOIN enrolled_courses AS theirs ON theirs.user_id = mine.user_id").
+		Where("mine.course_id = ? AND mine.start_date <= ? AND mine.end_date >= ?", booking.CourseID, booking.Start, booking.Start).
+		Where("theirs.start_date <= ? AND theirs.end_date >= ?", booking.Start, booking.Start)
+
+	query := overlapping().Where("lessons.course_id = ? OR lessons.course_id IN (?)", booking.CourseID, sharedStudents)
+	if err := collect(ConflictGroup, query); err != nil {
+		return nil, err
+	}
+
+	if len(booking.ResourceIDs) > 0 {
+		query := overlapping().
+			Joins("JOIN lesson_resources ON lesson_resources.lesson_id = lessons.id").
+			Where("lesson_resources.resource_id IN ?", booking.ResourceIDs)
+		if err := collect(ConflictResource, query); err != nil {
+			return nil, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+// Overlapping selects the scheduled lessons that intersect [start, end).
+func Overlapping(tx *gorm.DB, start time.Time, end time.Time) *gorm.DB {
+	return tx.Model(&models.Lesson{}).
+		Where("lessons.status = ?", "scheduled").
+		Where("lessons.start_date < ? AND lessons.start_date + lessons.duration * interval '1 minute' > ?", end, start)
+}
+
+// TaughtBy narrows a lesson query to the lessons the teacher actually gives: their
+// own courses' lessons without a substitute, and lessons they substitute in.
+func TaughtBy(query *gorm.DB, teacherID uint) *gorm.DB {
+	return query.Joins("JOIN courses ON courses.id = lessons.course_id").
+		Where("COALESCE(lessons.substitute_id, courses.teacher_id) = ?", teacherID)
+}
+
+// LessonResourceIDs lists the resources booked for a lesson.
+func LessonResourceIDs(tx *gorm.DB, lessonID uint) ([]uint, error) {
+	var ids []uint
+	err := tx.Table("lesson_resources").Where("lesson_id = ?", lessonID).Pluck("resource_id", &ids).Error
+	return ids, err
+}
+
+// Overlaps reports whether two bookings intersect in time.
+func Overlaps(a Booking, b Booking) bool {
+	return a.Start.Before(b.End()) && b.Start.Before(a.End())
+}
+
+func conflictWith(kind string, lesson models.Lesson) Conflict {
+	return Conflict{
+		Kind:       kind,
+		LessonID:   lesson.ID,
+		LessonName: lesson.Name,
+		CourseID:   lesson.CourseID,
+		Start:      lesson.StartDate,
+		End:        lesson.StartDate.Add(time.Duration(lesson.Duration) * time.Minute),
+	}
+}
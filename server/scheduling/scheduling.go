@@ -0,0 +1,77 @@
+package scheduling
+
+import (
+	"codev_erp/db/models"
+	"errors"
+	"time"
+
+	// the server may run without system zoneinfo
+	_ "time/tzdata"
+)
+
+var (
+	ErrInvalidTime     = errors.New("startTime must look like 18:30")
+	ErrInvalidTimezone = errors.New("unknown timezone")
+)
+
+// Location resolves the schedule's timezone.
+func Location(timezone string) (*time.Location, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// ParseClock validates a wall-clock time such as "18:30".
+func ParseClock(value string) (int, int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, ErrInvalidTime
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// Occurrences lists the start times of a weekly schedule within [from, to), skipping
+// holidays and exception dates. Times are built in the schedule's timezone so that a
+// lesson stays at 18:30 local time across DST changes.
+func Occurrences(schedule models.CourseSchedule, from time.Time, to time.Time, skip []time.Time) ([]time.Time, error) {
+	loc, err := Location(schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	hour, minute, err := ParseClock(schedule.StartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule.ValidFrom != nil && schedule.ValidFrom.After(from) {
+		from = *schedule.ValidFrom
+	}
+	if schedule.ValidUntil != nil && schedule.ValidUntil.Before(to) {
+		to = *schedule.ValidUntil
+	}
+
+	skipped := map[string]bool{}
+	for _, day := range skip {
+		skipped[day.Format("2006-01-02")] = true
+	}
+
+	local := from.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	day = day.AddDate(0, 0, (schedule.Weekday-int(day.Weekday())+7)%7)
+
+	var starts []time.Time
+	for ; day.Before(to); day = day.AddDate(0, 0, 7) {
+		start := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+
+		if start.Before(from) || !start.Before(to) || skipped[day.Format("2006-01-02")] {
+			continue
+		}
+
+		starts = append(starts, start)
+	}
+
+	return starts, nil
+}
@@ -0,0 +1,146 @@
+package similarity
+
+import (
+	"codev_erp/db/models"
+	"sort"
+	"strings"
+)
+
+// maxMatches caps the spans stored per pair; the biggest ones come first.
+const maxMatches = 50
+
+// Submission is one student's files prepared for comparison.
+type Submission struct {
+	HomeworkID uint
+	UserID     uint
+	Files      map[string]string
+	Prints     []Fingerprint
+}
+
+func NewSubmission(homeworkID uint, userID uint, files map[string]string, ignore map[uint64]bool) Submission {
+	submission := Submission{HomeworkID: homeworkID, UserID: userID, Files: files}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, fp := range Fingerprints(name, files[name]) {
+			if !ignore[fp.Hash] {
+				submission.Prints = append(submission.Prints, fp)
+			}
+		}
+	}
+
+	return submission
+}
+
+// Ignored collects the fingerprints of starter files handed out with the task, so
+// code every student was given does not count as copied.
+func Ignored(files map[string]string) map[uint64]bool {
+	ignore := map[uint64]bool{}
+	for name, content := range files {
+		for _, fp := range Fingerprints(name, content) {
+			ignore[fp.Hash] = true
+		}
+	}
+	return ignore
+}
+
+// Compare reports how much two submissions share. It returns ok=false when they
+// share no fingerprints at all.
+func Compare(a Submission, b Submission) (models.SimilarityPair, bool) {
+	pair := models.SimilarityPair{HomeworkA: a.HomeworkID, HomeworkB: b.HomeworkID, UserA: a.UserID, UserB: b.UserID}
+
+	firstA := firstByHash(a.Prints)
+	firstB := firstByHash(b.Prints)
+	if len(firstA) == 0 || len(firstB) == 0 {
+		return pair, false
+	}
+
+	var spans [][2]Fingerprint
+	for hash, printA := range firstA {
+		if printB, ok := firstB[hash]; ok {
+			spans = append(spans, [2]Fingerprint{printA, printB})
+		}
+	}
+	if len(spans) == 0 {
+		return pair, false
+	}
+
+	pair.PercentA = float64(len(spans)) * 100 / float64(len(firstA))
+	pair.PercentB = float64(len(spans)) * 100 / float64(len(firstB))
+	pair.Score = max(pair.PercentA, pair.PercentB)
+	pair.Matches = merge(spans, a.Files, b.Files)
+
+	return pair, true
+}
+
+func firstByHash(prints []Fingerprint) map[uint64]Fingerprint {
+	first := map[uint64]Fingerprint{}
+	for _, fp := range prints {
+		if _, ok := first[fp.Hash]; !ok {
+			first[fp.Hash] = fp
+		}
+	}
+	return first
+}
+
+// merge joins fingerprint matches that overlap or touch on both sides into spans.
+func merge(spans [][2]Fingerprint, filesA map[string]string, filesB map[string]string) []models.SimilarityMatch {
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i][0].File != spans[j][0].File {
+			return spans[i][0].File < spans[j][0].File
+		}
+		return spans[i][0].Start < spans[j][0].Start
+	})
+
+	var merged [][2]Fingerprint
+	for _, span := range spans {
+		if n := len(merged); n > 0 && adjacent(merged[n-1], span) {
+			merged[n-1][0].End = max(merged[n-1][0].End, span[0].End)
+			merged[n-1][1].End = max(merged[n-1][1].End, span[1].End)
+			continue
+		}
+		merged = append(merged, span)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i][0].End-merged[i][0].Start > merged[j][0].End-merged[j][0].Start
+	})
+	if len(merged) > maxMatches {
+		merged = merged[:maxMatches]
+	}
+
+	matches := make([]models.SimilarityMatch, 0, len(merged))
+	for _, span := range merged {
+		a, b := span[0], span[1]
+		matches = append(matches, models.SimilarityMatch{
+			FileA:      a.File,
+			StartLineA: lineAt(filesA[a.File], a.Start),
+			EndLineA:   lineAt(filesA[a.File], a.End),
+			StartA:     a.Start,
+			EndA:       a.End,
+			FileB:      b.File,
+			StartLineB: lineAt(filesB[b.File], b.Start),
+			EndLineB:   lineAt(filesB[b.File], b.End),
+			StartB:     b.Start,
+			EndB:       b.End,
+		})
+	}
+
+	return matches
+}
+
+func adjacent(prev [2]Fingerprint, next [2]Fingerprint) bool {
+	return prev[0].File == next[0].File && prev[1].File == next[1].File &&
+		next[0].Start <= prev[0].End+1 &&
+		next[1].Start >= prev[1].Start && next[1].Start <= prev[1].End+1
+}
+
+// lineAt converts a byte offset to a 1-based line number.
+func lineAt(content string, offset int) int {
+	return strings.Count(content[:min(offset, len(content))], "\n") + 1
+}
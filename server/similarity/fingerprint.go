@@ -0,0 +1,196 @@
+package similarity
+
+import (
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// k is the k-gram length in tokens and window the winnowing window: any shared
+	// run of at least k+window-1 tokens is guaranteed to be detected.
+	k      = 5
+	window = 4
+)
+
+var codeExtensions = map[string]bool{
+	".py": true, ".js": true, ".ts": true, ".jsx": true, ".tsx": true, ".java": true,
+	".c": true, ".h": true, ".cpp": true, ".hpp": true, ".cs": true, ".go": true,
+	".rb": true, ".php": true, ".kt": true, ".swift": true,
+}
+
+// keywords survive normalization, so renaming variables does not hide a copy but
+// the structure of the code still counts.
+var keywords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		and as assert async await break case catch class const continue def default del delete do elif else
+		except export extends finally for from function global if import in instanceof is lambda let new
+		nonlocal not or pass raise return static super switch this throw try typeof var void while with yield
+		func package struct interface map chan go defer range type public private protected int float double
+		char bool boolean string true false null nil None True False print println printf console log`) {
+		keywords[word] = true
+	}
+}
+
+type token struct {
+	text       string
+	start, end int
+}
+
+// Fingerprint is a selected k-gram hash and the byte range of the k-gram in its file.
+type Fingerprint struct {
+	Hash       uint64
+	File       string
+	Start, End int
+}
+
+// Fingerprints winnows the normalized token stream of a file.
+func Fingerprints(name string, content string) []Fingerprint {
+	var tokens []token
+	if codeExtensions[strings.ToLower(filepath.Ext(name))] {
+		tokens = codeTokens(content)
+	} else {
+		tokens = textTokens(content)
+	}
+
+	if len(tokens) < k {
+		return nil
+	}
+
+	hashes := make([]uint64, len(tokens)-k+1)
+	for i := range hashes {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+k] {
+			h.Write([]byte(t.text))
+			h.Write([]byte{0})
+		}
+		hashes[i] = h.Sum64()
+	}
+
+	var prints []Fingerprint
+	last := -1
+	size := min(window, len(hashes))
+
+	for start := 0; start+size <= len(hashes); start++ {
+		// самый правый минимум окна, чтобы соседние окна выбирали тот же k-грам
+		chosen := start
+		for i := start; i < start+size; i++ {
+			if hashes[i] <= hashes[chosen] {
+				chosen = i
+			}
+		}
+
+		if chosen != last {
+			prints = append(prints, Fingerprint{
+				Hash:  hashes[chosen],
+				File:  name,
+				Start: tokens[chosen].start,
+				End:   tokens[chosen+k-1].end,
+			})
+			last = chosen
+		}
+	}
+
+	return prints
+}
+
+// codeTokens drops comments and whitespace and replaces identifiers, numbers and
+// string literals with placeholders.
+func codeTokens(src string) []token {
+	var tokens []token
+
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 4
+			}
+
+		case r == '"' || r == '\'' || r == '`':
+			start := i
+			i += size
+			for i < len(src) && rune(src[i]) != r && src[i] != '\n' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i = min(i+1, len(src))
+			tokens = append(tokens, token{"S", start, i})
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{"N", start, i})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				i += size
+			}
+			word := src[start:i]
+			if !keywords[word] {
+				word = "I"
+			}
+			tokens = append(tokens, token{word, start, i})
+
+		default:
+			tokens = append(tokens, token{src[i : i+size], i, i + size})
+			i += size
+		}
+	}
+
+	return tokens
+}
+
+// textTokens are lower-cased words; punctuation and spacing are ignored.
+func textTokens(src string) []token {
+	var tokens []token
+
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			i += size
+			continue
+		}
+
+		start := i
+		for i < len(src) {
+			r, size := utf8.DecodeRuneInString(src[i:])
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			i += size
+		}
+		tokens = append(tokens, token{strings.ToLower(src[start:i]), start, i})
+	}
+
+	return tokens
+}
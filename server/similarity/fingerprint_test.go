@@ -0,0 +1,33 @@
+package similarity
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCodeTokensNonASCIIDigits(t *testing.T) {
+	tests := map[string][]string{
+		"x = ١٢":      {"I", "=", "N"},
+		"y = １２３ + 4": {"I", "=", "N", "+", "N"},
+		"z = 7٣":      {"I", "=", "N"},
+	}
+
+	for src, want := range tests {
+		done := make(chan []token, 1)
+		go func() { done <- codeTokens(src) }()
+
+		select {
+		case tokens := <-done:
+			var got []string
+			for _, tok := range tokens {
+				got = append(got, tok.text)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("codeTokens(%q) = %v, want %v", src, got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("codeTokens(%q) does not return", src)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package similarity
+
+import (
+	"bytes"
+	"codev_erp/db"
+	"codev_erp/db/models"
+	"codev_erp/logger"
+	"codev_erp/storage"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusError   = "error"
+
+	// MinScore is the lowest similarity kept in a report; below it pairs are noise.
+	MinScore = 20.0
+
+	maxFileBytes = 512 * 1024
+	staleAfter   = 30 * time.Minute
+)
+
+// Request queues the lesson's report to be recomputed.
+func Request(tx *gorm.DB, lessonID uint) error {
+	report := models.SimilarityReport{LessonID: lessonID, Status: StatusQueued, RequestedAt: time.Now()}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "lesson_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "requested_at"}),
+	}).Create(&report).Error
+}
+
+// ProcessQueue recomputes every queued report.
+func ProcessQueue(now time.Time) {
+	err := db.DB.Model(&models.SimilarityReport{}).
+		Where("status = ? AND started_at < ?", StatusRunning, now.Add(-staleAfter)).
+		Update("status", StatusQueued).Error
+	if err != nil {
+		logger.Log("Similarity: failed to requeue stale reports: "+err.Error(), slog.LevelError)
+	}
+
+	for {
+		var report models.SimilarityReport
+
+		err := db.DB.Raw(`UPDATE similarity_reports SET status = ?, started_at = ?
+			WHERE id = (SELECT id FROM similarity_reports WHERE status = ? ORDER BY requested_at LIMIT 1 FOR UPDATE SKIP LOCKED)
+			RETURNING *`, StatusRunning, time.Now(), StatusQueued).
+			Scan(&report).Error
+		if err != nil {
+			logger.Log("Similarity: failed to claim a report: "+err.Error(), slog.LevelError)
+			return
+		}
+		if report.ID == 0 {
+			return
+		}
+
+		pairs, err := analyze(report.LessonID)
+		finish(report, pairs, err)
+	}
+}
+
+// finish stores the pairs unless the report was queued again while it ran; the new
+// request then recomputes it with the latest submissions.
+func finish(report models.SimilarityReport, pairs []models.SimilarityPair, runErr error) {
+	finished := time.Now()
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		update := models.SimilarityReport{Status: StatusDone, FinishedAt: &finished}
+		if runErr != nil {
+			update.Status = StatusError
+			update.Error = runErr.Error()
+		}
+
+		result := tx.Model(&models.SimilarityReport{}).
+			Where("id = ? AND status = ?", report.ID, StatusRunning).
+			Select("status", "error", "finished_at").
+			Updates(update)
+		if result.Error != nil || result.RowsAffected == 0 || runErr != nil {
+			return result.Error
+		}
+
+		if err := tx.Where("report_id = ?", report.ID).Delete(&models.SimilarityPair{}).Error; err != nil {
+			return err
+		}
+		for i := range pairs {
+			pairs[i].ReportID = report.ID
+		}
+		if len(pairs) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&pairs, 100).Error
+	})
+
+	if err != nil {
+		logger.Log(fmt.Sprintf("Similarity: failed to save report %d: %s", report.ID, err.Error()), slog.LevelError)
+	}
+}
+
+// analyze compares every pair of the lesson's current submissions.
+func analyze(lessonID uint) ([]models.SimilarityPair, error) {
+	var tasks []models.LessonTasks
+	if err := db.DB.Preload("HomeworkFiles").Where("lesson_id = ?", lessonID).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	starter := map[string]string{}
+	for _, task := range tasks {
+		for _, file := range task.HomeworkFiles {
+			if content, ok := readText(file.Key); ok {
+				starter[file.Key] = content
+			}
+		}
+	}
+	ignore := Ignored(starter)
+
+	var homeworks []models.UsersHomework
+	if err := db.DB.Preload("Files").Where("lesson_id = ?", lessonID).Order("id").Find(&homeworks).Error; err != nil {
+		return nil, err
+	}
+
+	submissions := make([]Submission, 0, len(homeworks))
+	for _, hw := range homeworks {
+		files := map[string]string{}
+		for _, file := range hw.Files {
+			if content, ok := readText(file.Key); ok {
+				files[file.OriginalName] = content
+			}
+		}
+		submissions = append(submissions, NewSubmission(hw.ID, hw.UserID, files, ignore))
+	}
+
+	var pairs []models.SimilarityPair
+	for i := range submissions {
+		for j := i + 1; j < len(submissions); j++ {
+			if submissions[i].UserID == submissions[j].UserID {
+				continue
+			}
+			if pair, ok := Compare(submissions[i], submissions[j]); ok && pair.Score >= MinScore {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+// readText loads an uploaded file if it is reasonably small UTF-8 text.
+func readText(stored string) (string, bool) {
+	data, err := storage.ReadFile(stored)
+	if err != nil || len(data) > maxFileBytes || !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", false
+	}
+	return string(data), true
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local keeps files in a directory on the app host.
+type Local struct {
+	Dir string
+}
+
+func (l *Local) path(name string) (string, error) {
+	cleaned, err := clean(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(cleaned)), nil
+}
+
+// Put writes through a temporary file, so readers never see half a file.
+func (l *Local) Put(name string, r io.Reader, size int64) error {
+	full, err := l.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), full)
+}
+
+func (l *Local) Open(name string) (io.ReadCloser, error) {
+	full, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(full)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		f.Close()
+		return nil, ErrNotFound
+	}
+
+	return f, nil
+}
+
+func (l *Local) Delete(name string) error {
+	full, err := l.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(full); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Exists(name string) (bool, error) {
+	full, err := l.path(name)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(full)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+// Walk calls fn with the name and size of every file under Dir; the migration
+// command uses it to copy the local upload area to another backend.
+func (l *Local) Walk(fn func(name string, size int64) error) error {
+	return filepath.WalkDir(l.Dir, func(full string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// недописанные загрузки не копируем
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(l.Dir, full)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info.Size())
+	})
+}
@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// unsignedPayload lets uploads stream without hashing the body first; the signature
+// still covers the method, key and headers.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3 keeps files in a bucket of an S3-compatible service. Requests use path-style
+// addressing (endpoint/bucket/key), which MinIO and AWS both accept, and are signed
+// with AWS Signature Version 4.
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func NewS3(endpoint string, bucket string, region string, accessKey string, secretKey string) (*S3, error) {
+	if endpoint == "" || bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, errors.New("S3 storage needs S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY")
+	}
+
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", endpoint)
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3{
+		endpoint:  u,
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *S3) Put(name string, r io.Reader, size int64) error {
+	key, err := clean(name)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.request(http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Open(name string) (io.ReadCloser, error) {
+	key, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := s.request(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(name string) error {
+	key, err := clean(name)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.request(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Exists(name string) (bool, error) {
+	key, err := clean(name)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := s.request(http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := s.do(req)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// EnsureBucket creates the bucket if it does not exist yet.
+func (s *S3) EnsureBucket() error {
+	req, err := s.request(http.MethodHead, "", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err == nil {
+		resp.Body.Close()
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	var body io.Reader
+	var size int64
+	if s.region != "us-east-1" {
+		config := `<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><LocationConstraint>` +
+			s.region + `</LocationConstraint></CreateBucketConfiguration>`
+		body = strings.NewReader(config)
+		size = int64(len(config))
+	}
+
+	req, err = s.request(http.MethodPut, "", body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	resp, err = s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// request builds a request for the key, or for the bucket itself when key is empty.
+func (s *S3) request(method string, key string, body io.Reader) (*http.Request, error) {
+	escaped := "/" + uriEncode(s.bucket, false)
+	if key != "" {
+		escaped += "/" + uriEncode(key, true)
+	}
+
+	u := *s.endpoint
+	u.Path, _ = url.PathUnescape(escaped)
+	u.RawPath = escaped
+
+	return http.NewRequest(method, u.String(), body)
+}
+
+// do signs and sends the request. A missing key or bucket comes back as ErrNotFound,
+// any other non-2xx answer as an error carrying the service's message.
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("S3 %s %s: %s %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(message)))
+}
+
+// sign adds the AWS Signature Version 4 headers.
+func (s *S3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", unsignedPayload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + unsignedPayload + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // запросов с параметрами не делаем
+		canonicalHeaders,
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	req.Header.Set("Authorization", s.authorization(now, signedHeaders, canonicalRequest))
+}
+
+// authorization is the Authorization header value for a canonical request made at now.
+func (s *S3) authorization(now time.Time, signedHeaders string, canonicalRequest string) string {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	signature := hex.EncodeToString(hmacSHA256(signingKey(s.secretKey, date, s.region, "s3"), stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature)
+}
+
+// signingKey derives the key that signs a day's requests to a service in a region.
+func signingKey(secretKey string, date string, region string, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent-encodes everything but the unreserved characters, as SigV4
+// requires; slashes are kept when encoding a key.
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+
+	// LocalDir is the upload area of the local backend.
+	LocalDir = "./static"
+
+	// Pictures shown to anyone live in these directories; only they are served
+	// under /static. Everything else goes through handlers that check access.
+	AvatarsDir  = "avatars"
+	PreviewsDir = "previews"
+	BrandingDir = "branding"
+)
+
+var (
+	ErrNotFound    = errors.New("file not found")
+	ErrInvalidName = errors.New("invalid file name")
+)
+
+// Storage keeps uploaded and generated files under flat names, the same names that
+// are stored in the database ("<uuid>_<name>" attachment keys, "documents/<uuid>.pdf").
+type Storage interface {
+	Put(name string, r io.Reader, size int64) error
+	// Open returns ErrNotFound when there is no such file.
+	Open(name string) (io.ReadCloser, error)
+	// Delete does nothing when there is no such file.
+	Delete(name string) error
+	Exists(name string) (bool, error)
+}
+
+// Files is the storage every upload goes through. It points at the local upload area
+// until Setup picks the configured backend.
+var Files Storage = &Local{Dir: LocalDir}
+
+// Setup switches Files to the backend chosen by the environment.
+func Setup() error {
+	files, err := FromEnv()
+	if err != nil {
+		return err
+	}
+	Files = files
+	return nil
+}
+
+// FromEnv picks the backend from STORAGE_BACKEND: "local" (default) keeps files in
+// STORAGE_DIR or ./static; "s3" talks to an S3-compatible service such as MinIO,
+// configured with S3_ENDPOINT, S3_BUCKET, S3_REGION, S3_ACCESS_KEY and S3_SECRET_KEY.
+func FromEnv() (Storage, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", BackendLocal:
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = LocalDir
+		}
+		return &Local{Dir: dir}, nil
+	case BackendS3:
+		return NewS3(os.Getenv("S3_ENDPOINT"), os.Getenv("S3_BUCKET"), os.Getenv("S3_REGION"),
+			os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"))
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// ReadFile reads a whole file from Files.
+func ReadFile(name string) ([]byte, error) {
+	r, err := Files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// WriteFile stores data in Files under name.
+func WriteFile(name string, data []byte) error {
+	return Files.Put(name, bytes.NewReader(data), int64(len(data)))
+}
+
+// IsPublic reports whether name lies in one of the public directories.
+func IsPublic(name string) bool {
+	cleaned, err := clean(name)
+	if err != nil {
+		return false
+	}
+
+	dir, _, nested := strings.Cut(cleaned, "/")
+	return nested && (dir == AvatarsDir || dir == PreviewsDir || dir == BrandingDir)
+}
+
+// clean turns a name into a relative slash path that cannot leave the storage root.
+func clean(name string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	if cleaned == "" || cleaned == "." {
+		return "", ErrInvalidName
+	}
+	return cleaned, nil
+}